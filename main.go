@@ -7,6 +7,7 @@ import (
 	"html/template"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"time"
@@ -16,10 +17,22 @@ import (
 
 	"http_proxy_tool_test_web_demo/routes"
 	"http_proxy_tool_test_web_demo/routes/api"
+	"http_proxy_tool_test_web_demo/routes/auth"
+	"http_proxy_tool_test_web_demo/routes/cache"
+	"http_proxy_tool_test_web_demo/routes/chaos"
+	"http_proxy_tool_test_web_demo/routes/compression"
+	"http_proxy_tool_test_web_demo/routes/cookies"
 	"http_proxy_tool_test_web_demo/routes/format"
+	"http_proxy_tool_test_web_demo/routes/https"
+	"http_proxy_tool_test_web_demo/routes/inspect"
+	"http_proxy_tool_test_web_demo/routes/integrity"
+	"http_proxy_tool_test_web_demo/routes/malformed"
+	"http_proxy_tool_test_web_demo/routes/mock"
+	"http_proxy_tool_test_web_demo/routes/raw"
 	"http_proxy_tool_test_web_demo/routes/test/performance"
 	"http_proxy_tool_test_web_demo/routes/test/system"
 	"http_proxy_tool_test_web_demo/routes/transfer"
+	"http_proxy_tool_test_web_demo/routes/websocket"
 )
 
 //go:embed templates/*.html
@@ -29,12 +42,20 @@ var templatesFS embed.FS
 var staticFS embed.FS
 
 var (
-	version     string = "dev"
-	buildTime   string = "unknown"
-	port               = flag.String("port", "8080", "服务器端口")
-	logDir             = flag.String("log-dir", "logs", "日志目录")
-	showVersion        = flag.Bool("version", false, "显示版本信息")
-	showHelp           = flag.Bool("help", false, "显示帮助信息")
+	version       string = "dev"
+	buildTime     string = "unknown"
+	port                 = flag.String("port", "8080", "服务器端口")
+	logDir               = flag.String("log-dir", "logs", "日志目录")
+	showVersion          = flag.Bool("version", false, "显示版本信息")
+	showHelp             = flag.Bool("help", false, "显示帮助信息")
+	authUser             = flag.String("auth-user", auth.DefaultUsername, "认证测试的用户名")
+	authPass             = flag.String("auth-pass", auth.DefaultPassword, "认证测试的密码")
+	authToken            = flag.String("auth-token", auth.DefaultToken, "Bearer认证测试的令牌")
+	mockConfig           = flag.String("mock-config", "", "模拟接口配置文件（YAML或JSON）")
+	chaosSpec            = flag.String("chaos", "", "全局故障注入参数，如 latency=100ms&jitter=50ms&error_rate=0.05")
+	tlsPort              = flag.String("tls-port", "", "HTTPS端口，为空时不启用")
+	tlsCADir             = flag.String("tls-ca-dir", "ca", "本地CA目录（ca.pem、ca-key.pem），不存在时自动生成")
+	tlsClientAuth        = flag.String("tls-client-auth", "none", "HTTPS客户端证书策略: none、request、require、verify")
 )
 
 func main() {
@@ -57,9 +78,53 @@ func main() {
 	// 初始化日志系统
 	initLogger(*logDir)
 
+	// 加载模拟接口配置，配置错误时拒绝启动
+	var mocks *mock.Config
+	if *mockConfig != "" {
+		var err error
+		mocks, err = mock.Load(*mockConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("已加载%d个模拟接口: %s", len(mocks.Routes), *mockConfig)
+	}
+
+	// 全局故障注入参数，运行时可通过/api/chaos修改
+	if *chaosSpec != "" {
+		options, err := chaos.Parse(*chaosSpec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		chaos.SetGlobal(options)
+		log.Printf("已启用全局故障注入: %s", *chaosSpec)
+	}
+
+	// HTTPS使用本地CA按SNI签发证书，证书缺陷场景使用的CA在启动时生成
+	var hosts *https.VirtualHosts
+	if *tlsPort != "" {
+		ca, err := https.LoadOrCreateCA(*tlsCADir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("本地CA（%s）: %s，SHA-256指纹 %s", ca.Source, ca.Dir, ca.Fingerprint())
+		hosts, err = https.NewVirtualHosts(ca, *port)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if hosts.ClientAuth, err = https.ParseClientAuth(*tlsClientAuth); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	// 创建Gin引擎
 	r := gin.Default()
 
+	// 原始请求捕获，需配合捕获监听器；需在CORS之前，否则被CORS直接应答的预检请求不会从缓冲区移除
+	r.Use(raw.Capture())
+
+	// 请求记录（/api/inspect），需在CORS之前，否则被CORS直接应答的预检请求不会被记录
+	r.Use(inspect.Recorder())
+
 	// 配置CORS
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
@@ -70,6 +135,12 @@ func main() {
 		MaxAge:           12 * time.Hour,
 	}))
 
+	// 故障注入（-chaos、/api/chaos、X-Chaos-*），在压缩之外按线上字节计算断开位置
+	r.Use(chaos.Middleware())
+
+	// 可选响应压缩（?encoding=）
+	r.Use(compression.Middleware())
+
 	// 静态文件服务
 	staticSubFS, _ := fs.Sub(staticFS, "static")
 	r.StaticFS("/static", http.FS(staticSubFS))
@@ -87,13 +158,30 @@ func main() {
 	routeManager.RegisterModule(&performance.PerformanceModule{})
 	routeManager.RegisterModule(&system.SystemModule{})
 	routeManager.RegisterModule(&transfer.TransferModule{})
+	routeManager.RegisterModule(&websocket.WebSocketModule{})
+	routeManager.RegisterModule(&raw.RawModule{})
+	routeManager.RegisterModule(&compression.CompressionModule{})
+	routeManager.RegisterModule(&auth.AuthModule{Username: *authUser, Password: *authPass, Token: *authToken})
+	routeManager.RegisterModule(&cookies.CookieModule{})
+	routeManager.RegisterModule(&cache.CacheModule{})
+	routeManager.RegisterModule(&integrity.IntegrityModule{})
+	routeManager.RegisterModule(&inspect.InspectModule{Version: version})
+	routeManager.RegisterModule(&mock.MockModule{Config: mocks, Source: *mockConfig})
+	routeManager.RegisterModule(&chaos.ChaosModule{})
+	routeManager.RegisterModule(&malformed.MalformedModule{})
+	routeManager.RegisterModule(&https.HTTPSModule{Hosts: hosts, TLSPort: *tlsPort})
 
 	// 基础路由
 	r.GET("/", func(c *gin.Context) {
+		wsScheme := "ws"
+		if c.Request.TLS != nil {
+			wsScheme = "wss"
+		}
 		c.HTML(http.StatusOK, "index.html", gin.H{
 			"title":     "HTTP代理测试工具",
 			"version":   version,
 			"buildTime": buildTime,
+			"wsURL":     wsScheme + "://" + c.Request.Host,
 		})
 	})
 
@@ -111,10 +199,16 @@ func main() {
 					{"method": "GET/POST/PUT/DELETE", "path": "/api/test", "desc": "通用HTTP方法测试"},
 					{"method": "GET/POST", "path": "/api/status/:code", "desc": "HTTP状态码测试"},
 					{"method": "GET/POST", "path": "/api/delay/:seconds", "desc": "延迟响应测试"},
+					{"method": "GET/POST", "path": "/api/sequence/:name", "desc": "按调用次数返回steps序列（如503*2,200、200*2,timeout&loop=1），key=ip|global|header:名称"},
+					{"method": "GET", "path": "/api/sequences", "desc": "序列计数器"},
+					{"method": "DELETE", "path": "/api/sequences[/:name]", "desc": "重置序列计数器"},
 					{"method": "GET", "path": "/api/redirect/:times", "desc": "重定向测试"},
 					{"method": "GET", "path": "/api/redirect-to", "desc": "重定向到指定URL"},
 					{"method": "GET", "path": "/api/error", "desc": "错误响应测试"},
 					{"method": "GET", "path": "/api/timeout", "desc": "超时测试"},
+					{"method": "POST/PUT", "path": "/api/expect-continue", "desc": "Expect: 100-continue（mode=accept|reject|ignore，delay=）"},
+					{"method": "GET", "path": "/api/early-hints", "desc": "103 Early Hints（link=、count=、delay=）"},
+					{"method": "GET/POST", "path": "/api/processing", "desc": "处理期间定期发送102 Processing（seconds=、interval=）"},
 				},
 			},
 			{
@@ -127,12 +221,12 @@ func main() {
 					{"method": "GET", "path": "/api/html", "desc": "HTML格式响应"},
 					{"method": "GET", "path": "/api/text", "desc": "纯文本格式响应"},
 					{"method": "GET", "path": "/api/binary", "desc": "二进制格式响应"},
-					{"method": "POST", "path": "/api/parse/json", "desc": "JSON解析测试"},
+					{"method": "POST", "path": "/api/parse/json", "desc": "JSON解析测试（解码gzip/deflate/br/zstd请求体）"},
 					{"method": "POST", "path": "/api/parse/xml", "desc": "XML解析测试"},
 					{"method": "POST", "path": "/api/parse/multipart", "desc": "Multipart解析测试"},
-					{"method": "POST", "path": "/api/parse/binary", "desc": "二进制数据解析测试"},
+					{"method": "POST", "path": "/api/parse/binary", "desc": "二进制数据解析测试（解码gzip/deflate/br/zstd请求体）"},
 					{"method": "GET", "path": "/api/gzip", "desc": "Gzip压缩测试"},
-					{"method": "GET", "path": "/api/deflate", "desc": "Deflate压缩测试"},
+					{"method": "GET", "path": "/api/deflate", "desc": "Deflate压缩测试（?variant=raw发送原始deflate流）"},
 					{"method": "GET", "path": "/api/stream/:lines", "desc": "流式数据测试"},
 				},
 			},
@@ -141,13 +235,172 @@ func main() {
 				"prefix":      "/api/transfer",
 				"description": "分块传输(chunked)、大文件传输、SSE等传输测试",
 				"endpoints": []map[string]string{
-					{"method": "GET", "path": "/api/transfer/chunked", "desc": "分块传输测试"},
+					{"method": "GET", "path": "/api/transfer/chunked", "desc": "分块传输测试（?ext=&sizes=&zero_chunks=&trailer=）"},
 					{"method": "POST", "path": "/api/transfer/chunked", "desc": "分块接收测试"},
 					{"method": "GET", "path": "/api/transfer/chunked/stream", "desc": "分块流式传输"},
 					{"method": "POST", "path": "/api/transfer/chunked/upload", "desc": "分块上传测试"},
-					{"method": "GET", "path": "/api/transfer/large/:size", "desc": "大文件传输测试"},
-					{"method": "POST", "path": "/api/transfer/large", "desc": "大文件接收测试"},
+					{"method": "GET", "path": "/api/transfer/trailers", "desc": "带trailer的流式响应（摘要、状态、耗时，grpc=1）"},
+					{"method": "POST/PUT", "path": "/api/transfer/trailers/echo", "desc": "读取并回显请求trailer"},
+					{"method": "GET/HEAD", "path": "/api/transfer/large/:size", "desc": "大文件传输测试（支持Range、多范围multipart/byteranges、If-Range和416）"},
+					{"method": "POST", "path": "/api/transfer/large", "desc": "大文件接收测试（解码gzip/deflate/br/zstd请求体）"},
 					{"method": "GET", "path": "/api/transfer/stream/sse", "desc": "SSE流式传输"},
+					{"method": "GET", "path": "/api/transfer/stream/websocket", "desc": "WebSocket协议边界测试（?case=）"},
+				},
+			},
+			{
+				"name":        "WebSocket测试",
+				"prefix":      "/ws",
+				"description": "WebSocket连接、回声、广播、心跳、二进制、聊天室和性能测试",
+				"endpoints": []map[string]string{
+					{"method": "GET", "path": "/ws/connect", "desc": "基础连接测试"},
+					{"method": "GET", "path": "/ws/echo", "desc": "回声测试"},
+					{"method": "GET", "path": "/ws/broadcast", "desc": "广播测试"},
+					{"method": "GET", "path": "/ws/realtime", "desc": "实时数据推送"},
+					{"method": "GET", "path": "/ws/heartbeat", "desc": "心跳检测"},
+					{"method": "GET", "path": "/ws/binary", "desc": "二进制数据传输"},
+					{"method": "GET", "path": "/ws/chat", "desc": "聊天室模拟"},
+					{"method": "GET", "path": "/ws/performance", "desc": "性能测试"},
+					{"method": "GET", "path": "/ws/edge", "desc": "协议边界测试（?case=分片/控制帧/关闭码等）"},
+					{"method": "GET", "path": "/ws/edge/sessions", "desc": "边界测试会话记录"},
+					{"method": "GET", "path": "/ws/negotiate", "desc": "permessage-deflate和子协议协商测试"},
+					{"method": "GET", "path": "/ws/stats", "desc": "连接统计"},
+				},
+			},
+			{
+				"name":        "响应压缩测试",
+				"prefix":      "/api/compression",
+				"description": "gzip、deflate（zlib/原始）、brotli、zstd压缩，任意接口可附加?encoding=gzip|deflate|deflate-raw|br|zstd|identity|auto&level=N",
+				"endpoints": []map[string]string{
+					{"method": "GET", "path": "/api/compression/encodings", "desc": "支持的编码和压缩级别"},
+					{"method": "GET", "path": "/api/compression/negotiate", "desc": "按Accept-Encoding的q值协商编码，无可接受编码时返回406"},
+					{"method": "GET", "path": "/api/compression/:encoding", "desc": "指定编码压缩（?size=&level=&random=true）"},
+				},
+			},
+			{
+				"name":        "认证测试",
+				"prefix":      "/api/auth",
+				"description": "Basic、Bearer、Digest（RFC 7616）认证和407代理认证，凭据可用?user=&pass=&token=&realm=覆盖",
+				"endpoints": []map[string]string{
+					{"method": "ANY", "path": "/api/auth/basic", "desc": "Basic认证"},
+					{"method": "ANY", "path": "/api/auth/bearer", "desc": "Bearer令牌认证（RFC 6750错误码）"},
+					{"method": "ANY", "path": "/api/auth/digest", "desc": "Digest认证（?algorithm=MD5,SHA-256,-sess&qop=auth,auth-int&expires=秒）"},
+					{"method": "ANY", "path": "/api/auth/proxy/basic", "desc": "407 + Proxy-Authenticate Basic"},
+					{"method": "ANY", "path": "/api/auth/proxy/bearer", "desc": "407 + Proxy-Authenticate Bearer"},
+					{"method": "ANY", "path": "/api/auth/proxy/digest", "desc": "407 + Proxy-Authenticate Digest"},
+					{"method": "ANY", "path": "/api/auth/headers", "desc": "查看到达服务器的Authorization和Proxy-Authorization"},
+				},
+			},
+			{
+				"name":        "Cookie和会话测试",
+				"prefix":      "/api/cookies",
+				"description": "设置、更新、删除带各种属性的Cookie，服务端会话跟踪",
+				"endpoints": []map[string]string{
+					{"method": "GET", "path": "/api/cookies", "desc": "查看收到的Cookie"},
+					{"method": "ANY", "path": "/api/cookies/set", "desc": "设置Cookie（?name=&value=&domain=&path=&max_age=&expires=&samesite=&secure&httponly&partitioned）"},
+					{"method": "ANY", "path": "/api/cookies/update", "desc": "更新已有Cookie"},
+					{"method": "ANY", "path": "/api/cookies/delete", "desc": "删除Cookie（?name=&path=&domain=）"},
+					{"method": "ANY", "path": "/api/cookies/clear", "desc": "删除请求携带的所有Cookie"},
+					{"method": "GET", "path": "/api/cookies/matrix", "desc": "一次设置所有属性组合的Cookie"},
+					{"method": "GET", "path": "/api/cookies/session", "desc": "获取或创建服务端会话"},
+					{"method": "ANY", "path": "/api/cookies/session/set", "desc": "在会话中保存数据（?key=&value=）"},
+					{"method": "ANY", "path": "/api/cookies/session/destroy", "desc": "销毁会话"},
+				},
+			},
+			{
+				"name":        "HTTP缓存测试",
+				"prefix":      "/api/cache",
+				"description": "强/弱ETag、Last-Modified、304条件请求、Cache-Control和Vary，统计到达源站的请求数",
+				"endpoints": []map[string]string{
+					{"method": "GET/HEAD", "path": "/api/cache/resource/:id", "desc": "可缓存资源（?etag=strong|weak|none&last_modified=false&cache_control=&vary=&size=）"},
+					{"method": "PUT", "path": "/api/cache/resource/:id", "desc": "条件更新资源（If-Match不满足返回412，否则版本加一并返回新ETag）"},
+					{"method": "POST", "path": "/api/cache/resource/:id/bump", "desc": "更新资源版本"},
+					{"method": "GET", "path": "/api/cache/presets", "desc": "Cache-Control预设列表"},
+					{"method": "GET/HEAD", "path": "/api/cache/preset/:name", "desc": "使用预设Cache-Control的资源"},
+					{"method": "GET", "path": "/api/cache/stats", "desc": "源站命中统计（?id=）"},
+					{"method": "POST", "path": "/api/cache/reset", "desc": "重置版本和统计"},
+				},
+			},
+			{
+				"name":        "内容完整性校验",
+				"prefix":      "/api/integrity",
+				"description": "生成类接口附带Repr-Digest、Content-Digest、Digest、Content-MD5和X-Content-SHA256（?digest=trailer改为trailer发送，?digest=none不发送）",
+				"endpoints": []map[string]string{
+					{"method": "ANY", "path": "/api/integrity/verify", "desc": "校验请求携带的摘要头或trailer与收到的请求体是否一致"},
+				},
+			},
+			{
+				"name":        "请求记录",
+				"prefix":      "/api/inspect",
+				"description": "在内存中保留最近的请求（方法、完整URL、有序头部、消息体预览、TLS、客户端地址和耗时），可过滤并导出HAR",
+				"endpoints": []map[string]string{
+					{"method": "GET", "path": "/api/inspect/requests", "desc": "请求列表（?path=前缀&method=&header=Name或Name:Value&status=&since_id=&limit=）"},
+					{"method": "GET", "path": "/api/inspect/requests/:id", "desc": "单个请求详情"},
+					{"method": "DELETE", "path": "/api/inspect/requests", "desc": "清空记录"},
+					{"method": "GET", "path": "/api/inspect/har", "desc": "导出HAR 1.2（过滤参数同列表）"},
+				},
+			},
+			{
+				"name":        "模拟接口",
+				"prefix":      "/mock",
+				"description": "由-mock-config指定的YAML/JSON文件或运行时管理接口定义路由、状态码、头部、消息体、延迟和概率故障（status、delay、reset、empty、hang、truncate），可按方法、路径、查询参数和请求头匹配",
+				"endpoints": []map[string]string{
+					{"method": "ANY", "path": "/mock/*path", "desc": "按优先级和添加顺序匹配的模拟接口"},
+					{"method": "GET", "path": "/api/mock/routes", "desc": "当前的模拟路由和命中统计"},
+					{"method": "GET", "path": "/admin/mocks", "desc": "列出模拟路由和命中次数"},
+					{"method": "POST", "path": "/admin/mocks", "desc": "添加模拟路由（JSON或YAML）"},
+					{"method": "PUT", "path": "/admin/mocks", "desc": "整体替换模拟路由（格式同配置文件）"},
+					{"method": "DELETE", "path": "/admin/mocks", "desc": "删除全部模拟路由"},
+					{"method": "GET", "path": "/admin/mocks/:id", "desc": "单个模拟路由"},
+					{"method": "PUT", "path": "/admin/mocks/:id", "desc": "更新或创建模拟路由"},
+					{"method": "DELETE", "path": "/admin/mocks/:id", "desc": "删除模拟路由"},
+				},
+			},
+			{
+				"name":        "故障注入",
+				"prefix":      "/api/chaos",
+				"description": "对任意接口注入延迟抖动（uniform/normal/exponential）、错误状态码、发送响应头前断开、消息体中途RST、截断和停顿；全局配置来自-chaos或PUT /api/chaos，单个请求用X-Chaos-*请求头或chaos_*查询参数，X-Chaos: off跳过",
+				"endpoints": []map[string]string{
+					{"method": "GET", "path": "/api/chaos", "desc": "当前全局配置和参数列表"},
+					{"method": "PUT", "path": "/api/chaos", "desc": "替换全局配置（JSON对象，如{\"latency\":\"100ms\",\"error_rate\":0.1}）"},
+					{"method": "DELETE", "path": "/api/chaos", "desc": "关闭全局故障注入"},
+					{"method": "GET", "path": "/api/json?chaos_reset_rate=1&chaos_reset_after=100", "desc": "示例：发送100字节后RST断开"},
+				},
+			},
+			{
+				"name":        "HTTPS与本地CA",
+				"prefix":      "/api/tls",
+				"description": "-tls-port启用HTTPS监听器，本地CA按SNI即时签发证书，用于测试中间人代理",
+				"endpoints": []map[string]string{
+					{"method": "GET", "path": "/api/tls/info", "desc": "TLS版本、加密套件、ALPN、SNI、会话恢复和JA3/JA4指纹（?ja3=&ja4=比对）"},
+					{"method": "GET", "path": "/api/tls/ca", "desc": "本地CA信息和SHA-256指纹"},
+					{"method": "GET", "path": "/api/tls/ca.pem", "desc": "下载PEM格式的CA证书"},
+					{"method": "GET", "path": "/api/tls/ca.crt", "desc": "下载DER格式的CA证书"},
+					{"method": "GET", "path": "/api/tls/certificates", "desc": "已按SNI签发的证书"},
+					{"method": "GET", "path": "/api/tls/scenarios", "desc": "证书缺陷场景索引（过期、自签名、主机名不符、弱密钥、已吊销等）"},
+					{"method": "GET", "path": "/api/tls/crl", "desc": "本地CA的吊销列表（DER）"},
+					{"method": "GET", "path": "/api/tls/client", "desc": "服务器收到的客户端证书链和代理转发的证书头"},
+					{"method": "GET/POST", "path": "/api/tls/client-certificate", "desc": "签发本地CA的客户端证书（cn、san、days、format=pem）"},
+				},
+			},
+			{
+				"name":        "畸形响应",
+				"prefix":      "/api/malformed",
+				"description": "接管连接写出不符合HTTP规范的响应后关闭连接，用于测试代理对异常上游的处理（仅HTTP/1.x，desc中分号后为健壮代理应有的处理方式）",
+				"endpoints": append([]map[string]string{
+					{"method": "GET", "path": "/api/malformed", "desc": "用例列表和线上原始字节"},
+				}, malformed.Endpoints()...),
+			},
+			{
+				"name":        "原始请求捕获",
+				"prefix":      "/api/raw",
+				"description": "在net/http解析前记录请求行、头部顺序与大小写和消息体分帧",
+				"endpoints": []map[string]string{
+					{"method": "ANY", "path": "/api/raw/echo", "desc": "回显线上原始请求（?format=raw返回原始字节）"},
+					{"method": "ANY", "path": "/api/raw/desync", "desc": "请求走私探测，返回本次请求的分帧和同一连接此前的请求"},
+					{"method": "GET", "path": "/api/raw/desync/cases", "desc": "CL.TE、TE.CL、TE.TE、CL.CL原始用例"},
+					{"method": "GET", "path": "/api/raw/connections", "desc": "按连接的分帧报告（?suspicious=1）"},
+					{"method": "GET", "path": "/api/raw/connections/:id", "desc": "单个连接的分帧报告"},
+					{"method": "DELETE", "path": "/api/raw/connections", "desc": "清除已关闭连接的报告"},
 				},
 			},
 			{
@@ -181,12 +434,13 @@ func main() {
 		}
 
 		c.HTML(http.StatusOK, "api-docs.html", gin.H{
-			"title":       "API接口文档",
-			"version":     version,
-			"buildTime":   buildTime,
-			"modules":     modules,
-			"apiGroups":   apiGroups,
-			"currentTime": time.Now().Format("2006-01-02 15:04:05"),
+			"title":          "API接口文档",
+			"version":        version,
+			"buildTime":      buildTime,
+			"modules":        modules,
+			"apiGroups":      apiGroups,
+			"malformedCases": malformed.Cases,
+			"currentTime":    time.Now().Format("2006-01-02 15:04:05"),
 		})
 	})
 
@@ -209,7 +463,30 @@ func main() {
 	log.Printf("访问 http://localhost:%s 查看主页", *port)
 	log.Printf("访问 http://localhost:%s/api-docs 查看API文档", *port)
 
-	if err := r.Run(serverAddr); err != nil {
+	// HTTPS监听器，与明文端口共用同一个路由
+	if hosts != nil {
+		tlsListener, err := net.Listen("tcp", ":"+*tlsPort)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("HTTPS启动在端口 %s，CA证书下载: http://localhost:%s/api/tls/ca.pem", *tlsPort, *port)
+		go func() {
+			if err := https.NewServer(r, hosts).ServeTLS(https.NewListener(tlsListener), "", ""); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	// 使用捕获监听器启动，记录net/http解析前的原始请求字节
+	listener, err := net.Listen("tcp", serverAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := &http.Server{
+		Handler:     r,
+		ConnContext: routes.ConnContext,
+	}
+	if err := server.Serve(raw.NewCaptureListener(listener)); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -1,29 +1,73 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	gorillaws "github.com/gorilla/websocket"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 
 	"http_proxy_tool_test_web_demo/routes"
 	"http_proxy_tool_test_web_demo/routes/api"
+	"http_proxy_tool_test_web_demo/routes/auth"
+	"http_proxy_tool_test_web_demo/routes/cache"
+	"http_proxy_tool_test_web_demo/routes/chaos"
+	"http_proxy_tool_test_web_demo/routes/compression"
+	"http_proxy_tool_test_web_demo/routes/cookies"
 	"http_proxy_tool_test_web_demo/routes/format"
+	"http_proxy_tool_test_web_demo/routes/https"
+	"http_proxy_tool_test_web_demo/routes/inspect"
+	"http_proxy_tool_test_web_demo/routes/integrity"
+	"http_proxy_tool_test_web_demo/routes/malformed"
+	"http_proxy_tool_test_web_demo/routes/mock"
+	"http_proxy_tool_test_web_demo/routes/raw"
 	"http_proxy_tool_test_web_demo/routes/test/performance"
 	"http_proxy_tool_test_web_demo/routes/test/system"
 	"http_proxy_tool_test_web_demo/routes/transfer"
+	"http_proxy_tool_test_web_demo/routes/websocket"
 )
 
+// testHosts 设置后setupTestRouter创建的路由启用本地CA接口
+var testHosts *https.VirtualHosts
+
 // setupTestRouter 创建测试用的Gin路由器
 func setupTestRouter() *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
 
-	// 配置CORS
+	// 中间件顺序与main.go一致
+	r.Use(raw.Capture())
+	r.Use(inspect.Recorder())
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS"},
@@ -31,6 +75,8 @@ func setupTestRouter() *gin.Engine {
 		ExposeHeaders:    []string{"Content-Length", "Content-Type"},
 		AllowCredentials: true,
 	}))
+	r.Use(chaos.Middleware())
+	r.Use(compression.Middleware())
 
 	// 版本信息API
 	r.GET("/api/version", func(c *gin.Context) {
@@ -48,6 +94,18 @@ func setupTestRouter() *gin.Engine {
 	routeManager.RegisterModule(&performance.PerformanceModule{})
 	routeManager.RegisterModule(&system.SystemModule{})
 	routeManager.RegisterModule(&transfer.TransferModule{})
+	routeManager.RegisterModule(&websocket.WebSocketModule{})
+	routeManager.RegisterModule(&raw.RawModule{})
+	routeManager.RegisterModule(&compression.CompressionModule{})
+	routeManager.RegisterModule(&auth.AuthModule{})
+	routeManager.RegisterModule(&cookies.CookieModule{})
+	routeManager.RegisterModule(&cache.CacheModule{})
+	routeManager.RegisterModule(&integrity.IntegrityModule{})
+	routeManager.RegisterModule(&inspect.InspectModule{})
+	routeManager.RegisterModule(&mock.MockModule{})
+	routeManager.RegisterModule(&chaos.ChaosModule{})
+	routeManager.RegisterModule(&malformed.MalformedModule{})
+	routeManager.RegisterModule(&https.HTTPSModule{Hosts: testHosts})
 
 	// 初始化所有路由模块
 	routeManager.InitializeRoutes(r)
@@ -157,6 +215,1860 @@ func TestCORS(t *testing.T) {
 	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
 }
 
+// TestWebSocketEcho 测试WebSocket回声接口
+func TestWebSocketEcho(t *testing.T) {
+	server := httptest.NewServer(setupTestRouter())
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/echo"
+	conn, _, err := gorillaws.DefaultDialer.Dial(wsURL, nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.NoError(t, conn.WriteMessage(gorillaws.BinaryMessage, []byte{0x00, 0x01, 0xff}))
+	messageType, data, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, gorillaws.BinaryMessage, messageType)
+	assert.Equal(t, []byte{0x00, 0x01, 0xff}, data)
+
+	assert.NoError(t, conn.WriteMessage(gorillaws.TextMessage, []byte(`{"type":"stats"}`)))
+	_, data, err = conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"messages_received":2`)
+}
+
+// TestWebSocketEdgeFragmented 测试分片并穿插Ping帧的边界场景
+func TestWebSocketEdgeFragmented(t *testing.T) {
+	server := httptest.NewServer(setupTestRouter())
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/edge?case=fragmented&fragments=3"
+	conn, _, err := gorillaws.DefaultDialer.Dial(wsURL, nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	_, data, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("fragment-data-", 12), string(data))
+
+	_, data, err = conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"type":"summary"`)
+
+	assert.NoError(t, conn.WriteMessage(gorillaws.TextMessage, []byte(`{"type":"report"}`)))
+	_, data, err = conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"opcode":"pong"`)
+}
+
+// TestWebSocketNegotiate 测试压缩扩展和子协议协商
+func TestWebSocketNegotiate(t *testing.T) {
+	server := httptest.NewServer(setupTestRouter())
+	defer server.Close()
+
+	dialer := gorillaws.Dialer{
+		EnableCompression: true,
+		Subprotocols:      []string{"chat", "superchat"},
+	}
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/negotiate?protocols=superchat"
+	conn, resp, err := dialer.Dial(wsURL, nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.Equal(t, "superchat", resp.Header.Get("Sec-WebSocket-Protocol"))
+	assert.Contains(t, resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+
+	_, data, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"subprotocol":"superchat"`)
+
+	message := strings.Repeat("compress me ", 100)
+	assert.NoError(t, conn.WriteMessage(gorillaws.TextMessage, []byte(message)))
+	_, data, err = conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, message, string(data))
+
+	assert.NoError(t, conn.WriteMessage(gorillaws.TextMessage, []byte(`{"type":"report"}`)))
+	_, data, err = conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"compression_used":true`)
+}
+
+// TestTransferChunkedWire 测试线上的分块编码（扩展、不均匀分块大小、trailer）
+func TestTransferChunkedWire(t *testing.T) {
+	server := httptest.NewServer(setupTestRouter())
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "GET /api/transfer/chunked?chunks=2&delay=0&sizes=7,3&ext=foo=bar HTTP/1.1\r\nHost: test\r\n\r\n")
+	wire, err := io.ReadAll(conn)
+	assert.NoError(t, err)
+	assert.Contains(t, string(wire), "\r\n\r\n7;foo=bar\r\n{\"chunk\r\n3;foo=bar\r\n_id\r\n")
+	assert.Contains(t, string(wire), "\r\n0;foo=bar\r\nX-Chunk-Count: ")
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(wire)), nil)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(body), `{"chunk_id":1,`))
+	assert.Equal(t, strconv.Itoa(len(body)), resp.Trailer.Get("X-Total-Bytes"))
+}
+
+// startCaptureServer 启动带原始字节捕获监听器的测试服务器
+func startCaptureServer() *httptest.Server {
+	server := httptest.NewUnstartedServer(setupTestRouter())
+	server.Listener = raw.NewCaptureListener(server.Listener)
+	server.Config.ConnContext = routes.ConnContext
+	server.Start()
+	return server
+}
+
+// TestRawEcho 测试原始请求捕获（头部顺序、大小写、分块结构和管线化请求）
+func TestRawEcho(t *testing.T) {
+	server := startCaptureServer()
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	request := "POST /api/raw/echo HTTP/1.1\r\nhost: test\r\nX-lower-Case: a\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"3;ext=1\r\nabc\r\n2\r\nde\r\n0\r\nX-Trailer: t\r\n\r\n"
+	fmt.Fprint(conn, request+"GET /api/raw/echo?format=raw HTTP/1.1\r\nHOST: test\r\n\r\n")
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, string(body), `"header_order":["host","X-lower-Case","Transfer-Encoding"]`)
+	assert.Contains(t, string(body), `"framing":"chunked"`)
+	assert.Contains(t, string(body), `"size_line":"3;ext=1"`)
+	assert.Contains(t, string(body), `"name":"X-Trailer"`)
+	assert.Contains(t, string(body), `"body":"abcde"`)
+
+	resp, err = http.ReadResponse(reader, nil)
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, "GET /api/raw/echo?format=raw HTTP/1.1\r\nHOST: test\r\n\r\n", string(body))
+}
+
+// TestRawEchoAfterPreflight 测试被CORS直接应答的预检请求同样从捕获缓冲区移除
+func TestRawEchoAfterPreflight(t *testing.T) {
+	server := startCaptureServer()
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	fmt.Fprint(conn, "OPTIONS /api/raw/echo HTTP/1.1\r\nHost: test\r\nOrigin: http://example.test\r\nAccess-Control-Request-Method: GET\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	assert.NoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	assert.Equal(t, "*", resp.Header.Get("Access-Control-Allow-Origin"))
+
+	fmt.Fprint(conn, "GET /api/raw/echo?format=raw HTTP/1.1\r\nHost: test\r\n\r\n")
+	resp, err = http.ReadResponse(reader, nil)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "GET /api/raw/echo?format=raw HTTP/1.1\r\nHost: test\r\n\r\n", string(body))
+}
+
+// TestRawPreflightNotSuspicious 测试预检请求后接普通请求再关闭的连接不被标记为可疑
+func TestRawPreflightNotSuspicious(t *testing.T) {
+	server := startCaptureServer()
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	assert.NoError(t, err)
+	remoteAddr := conn.LocalAddr().String()
+	fmt.Fprint(conn, "OPTIONS /api/json HTTP/1.1\r\nHost: test\r\nOrigin: http://example.test\r\nAccess-Control-Request-Method: GET\r\n\r\n"+
+		"GET /api/json HTTP/1.1\r\nHost: test\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	for i := 0; i < 2; i++ {
+		resp, err := http.ReadResponse(reader, nil)
+		assert.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	conn.Close()
+
+	// 等待服务器处理连接关闭
+	var report raw.ConnReport
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, r := range raw.Reports() {
+			if r.RemoteAddr == remoteAddr && r.ClosedAt != nil {
+				report = r
+			}
+		}
+		if report.ClosedAt != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if assert.NotNil(t, report.ClosedAt) {
+		assert.False(t, report.Suspicious, report.Findings)
+		assert.Len(t, report.Requests, 2)
+		for _, record := range report.Requests {
+			assert.NotContains(t, record.Flags, raw.FlagUnprocessed)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/api/raw/connections?suspicious=1")
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NotContains(t, string(body), remoteAddr)
+}
+
+// TestTransferChunkedReceiveWire 测试分块接收统计线上的真实分块数
+func TestTransferChunkedReceiveWire(t *testing.T) {
+	server := startCaptureServer()
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	fmt.Fprint(conn, "POST /api/transfer/chunked HTTP/1.1\r\nHost: test\r\nTransfer-Encoding: chunked\r\n\r\n"+
+		"1\r\na\r\n1\r\nb\r\n1\r\nc\r\n0\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), `"chunk_count":3`)
+	assert.Contains(t, string(body), `"chunk_source":"wire"`)
+	assert.Contains(t, string(body), `"received_data":"abc"`)
+}
+
+// TestCompressionEncodings 测试各编码的响应确实经过压缩且可解码
+func TestCompressionEncodings(t *testing.T) {
+	router := setupTestRouter()
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip":        func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"deflate":     func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+		"deflate-raw": func(r io.Reader) (io.Reader, error) { return flate.NewReader(r), nil },
+		"br":          func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"zstd":        func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+	for name, decode := range decoders {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/compression/"+name+"?size=4096", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code, name)
+		assert.Equal(t, compression.HeaderValue(name), w.Header().Get("Content-Encoding"), name)
+		assert.Less(t, w.Body.Len(), 4096, name)
+
+		reader, err := decode(bytes.NewReader(w.Body.Bytes()))
+		assert.NoError(t, err, name)
+		plain, err := io.ReadAll(reader)
+		assert.NoError(t, err, name)
+		assert.Len(t, plain, 4096, name)
+	}
+
+	// 原有的/api/gzip返回真实gzip数据
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/gzip", nil)
+	router.ServeHTTP(w, req)
+	reader, err := gzip.NewReader(bytes.NewReader(w.Body.Bytes()))
+	assert.NoError(t, err)
+	plain, _ := io.ReadAll(reader)
+	assert.Contains(t, string(plain), "Gzip压缩测试")
+}
+
+// TestCompressionNegotiate 测试Accept-Encoding的q值协商和?encoding=参数
+func TestCompressionNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"gzip;q=0.5, br;q=0.8":     "br",
+		"gzip, zstd;q=0":           "gzip",
+		"*;q=0.1, gzip;q=0.2":      "gzip",
+		"identity;q=1, gzip;q=0.5": "identity",
+		"":                         "identity",
+		"compress":                 "identity",
+		"gzip;q=0, identity;q=0":   "",
+		"*;q=0":                    "",
+	}
+	for header, expected := range cases {
+		assert.Equal(t, expected, compression.Negotiate(header, compression.NegotiableEncodings), header)
+	}
+
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/compression/negotiate", nil)
+	req.Header.Set("Accept-Encoding", "*;q=0")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/json?encoding=auto", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0.4, zstd")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "zstd", w.Header().Get("Content-Encoding"))
+	decoder, err := zstd.NewReader(bytes.NewReader(w.Body.Bytes()))
+	assert.NoError(t, err)
+	plain, err := io.ReadAll(decoder)
+	assert.NoError(t, err)
+	assert.Contains(t, string(plain), `"code":200`)
+}
+
+// TestParseCompressedBody 测试解码压缩请求体并报告大小和编码不一致
+func TestParseCompressedBody(t *testing.T) {
+	router := setupTestRouter()
+
+	payload := []byte(`{"message":"` + strings.Repeat("compressed ", 50) + `"}`)
+	gzipped, err := compression.Encode(compression.Gzip, payload, compression.DefaultLevel)
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/parse/json", bytes.NewReader(gzipped))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"decoded":true`)
+	assert.Contains(t, w.Body.String(), fmt.Sprintf(`"compressed_size":%d`, len(gzipped)))
+	assert.Contains(t, w.Body.String(), fmt.Sprintf(`"decompressed_size":%d`, len(payload)))
+	assert.Contains(t, w.Body.String(), `"encoding_mismatch":false`)
+
+	// 声明为br但实际是gzip
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/parse/binary", bytes.NewReader(gzipped))
+	req.Header.Set("Content-Encoding", "br")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), `"detected_encoding":"gzip"`)
+	assert.Contains(t, w.Body.String(), `"encoding_mismatch":true`)
+	assert.Contains(t, w.Body.String(), `"decoded":false`)
+
+	// 未声明编码但发送了zstd数据
+	compressed, err := compression.Encode(compression.Zstd, payload, compression.DefaultLevel)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/transfer/large", bytes.NewReader(compressed))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"detected_encoding":"zstd"`)
+	assert.Contains(t, w.Body.String(), `"encoding_mismatch":true`)
+
+	// 开头恰好符合zlib头校验的普通文本不应识别为deflate
+	for _, text := range []string{"x hello", "Hjello world", "x^ hello world"} {
+		assert.Equal(t, "", compression.DetectEncoding([]byte(text)), text)
+	}
+	var zlibbed bytes.Buffer
+	zw := zlib.NewWriter(&zlibbed)
+	_, _ = zw.Write(payload)
+	zw.Close()
+	assert.Equal(t, compression.Deflate, compression.DetectEncoding(zlibbed.Bytes()[:64]))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/parse/binary", strings.NewReader("x hello"))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"encoding_mismatch":false`)
+}
+
+// TestAuthBasicAndBearer 测试Basic、Bearer认证和407代理认证
+func TestAuthBasicAndBearer(t *testing.T) {
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/auth/basic", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 401, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `Basic realm="http-proxy-test"`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/auth/basic?user=alice&pass=secret", nil)
+	req.SetBasicAuth("alice", "secret")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/auth/bearer", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 401, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/auth/proxy/bearer", nil)
+	req.Header.Set("Authorization", "Bearer "+auth.DefaultToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 407, w.Code)
+	assert.Contains(t, w.Header().Get("Proxy-Authenticate"), "Bearer")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/auth/proxy/bearer", nil)
+	req.Header.Set("Proxy-Authorization", "Bearer "+auth.DefaultToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+}
+
+// TestAuthDigest 测试Digest认证（SHA-256、auth-int、nc重放）
+func TestAuthDigest(t *testing.T) {
+	router := setupTestRouter()
+	uri := "/api/auth/digest?algorithm=SHA-256&qop=auth-int"
+	body := "hello digest"
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", uri, strings.NewReader(body))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 401, w.Code)
+	challenge := w.Header().Get("WWW-Authenticate")
+	assert.Contains(t, challenge, "algorithm=SHA-256")
+
+	param := func(name string) string {
+		start := strings.Index(challenge, name+`="`) + len(name) + 2
+		return challenge[start : start+strings.Index(challenge[start:], `"`)]
+	}
+	nonce, opaque := param("nonce"), param("opaque")
+	hash := func(data string) string {
+		sum := sha256.Sum256([]byte(data))
+		return hex.EncodeToString(sum[:])
+	}
+	ha1 := hash(auth.DefaultUsername + ":" + auth.DefaultRealm + ":" + auth.DefaultPassword)
+	ha2 := hash("POST:" + uri + ":" + hash(body))
+	response := hash(ha1 + ":" + nonce + ":00000001:abc:auth-int:" + ha2)
+	authorization := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=SHA-256, qop=auth-int, nc=00000001, cnonce="abc", response="%s", opaque="%s"`,
+		auth.DefaultUsername, auth.DefaultRealm, nonce, uri, response, opaque)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", uri, strings.NewReader(body))
+	req.Header.Set("Authorization", authorization)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Header().Get("Authentication-Info"), "rspauth=")
+
+	// 相同的nc被视为重放
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", uri, strings.NewReader(body))
+	req.Header.Set("Authorization", authorization)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 401, w.Code)
+}
+
+// TestCookieAttributes 测试Cookie属性和属性组合
+func TestCookieAttributes(t *testing.T) {
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/cookies/set?name=a&value=1&samesite=none&secure&partitioned&httponly=true&max_age=60&domain=example.com", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	setCookie := w.Header().Get("Set-Cookie")
+	for _, attribute := range []string{"a=1", "Domain=example.com", "Max-Age=60", "HttpOnly", "Secure", "SameSite=None", "Partitioned"} {
+		assert.Contains(t, setCookie, attribute)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/cookies/update?name=a", nil)
+	req.Header.Set("Cookie", "a=1")
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Header().Get("Set-Cookie"), "a=2")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/cookies/delete?name=a", nil)
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Header().Get("Set-Cookie"), "Max-Age=0")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/cookies/matrix", nil)
+	router.ServeHTTP(w, req)
+	assert.Len(t, w.Header()["Set-Cookie"], 39)
+}
+
+// TestCookieSession 测试服务端会话跨请求保持
+func TestCookieSession(t *testing.T) {
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/cookies/session/set?key=color&value=blue", nil)
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"created":true`)
+	cookie := w.Result().Cookies()[0]
+	assert.Equal(t, "session_id", cookie.Name)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/cookies/session", nil)
+	req.AddCookie(cookie)
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"created":false`)
+	assert.Contains(t, w.Body.String(), `"visits":2`)
+	assert.Contains(t, w.Body.String(), `"color":"blue"`)
+}
+
+// TestCacheConditional 测试ETag、Last-Modified条件请求和源站命中计数
+func TestCacheConditional(t *testing.T) {
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/cache/resource/conditional?vary=Accept-Language", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	etag := w.Header().Get("ETag")
+	lastModified := w.Header().Get("Last-Modified")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "Accept-Language", w.Header().Get("Vary"))
+
+	// 弱比较下W/前缀不影响If-None-Match
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/cache/resource/conditional?vary=Accept-Language", nil)
+	req.Header.Set("If-None-Match", `"other", W/`+etag)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 304, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/cache/resource/conditional?vary=Accept-Language", nil)
+	req.Header.Set("If-Modified-Since", lastModified)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 304, w.Code)
+	assert.Equal(t, "3", w.Header().Get("X-Origin-Hits"))
+
+	// 更新版本后旧ETag失效，If-Match返回412
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/cache/resource/conditional/bump", nil)
+	router.ServeHTTP(w, req)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/api/cache/resource/conditional", nil)
+	req.Header.Set("If-Match", etag)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 412, w.Code)
+
+	// PUT匹配当前ETag时更新版本并返回新的校验器，再用旧ETag更新返回412
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/cache/resource/conditional?vary=Accept-Language", nil)
+	router.ServeHTTP(w, req)
+	current := w.Header().Get("ETag")
+	assert.Equal(t, "2", w.Header().Get("X-Resource-Version"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/api/cache/resource/conditional?vary=Accept-Language", strings.NewReader("{}"))
+	req.Header.Set("If-Match", current)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "3", w.Header().Get("X-Resource-Version"))
+	assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+	updated := w.Header().Get("ETag")
+	assert.NotEmpty(t, updated)
+	assert.NotEqual(t, current, updated)
+	assert.Contains(t, w.Body.String(), `"version":3`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/api/cache/resource/conditional?vary=Accept-Language", strings.NewReader("{}"))
+	req.Header.Set("If-Match", current)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 412, w.Code)
+	assert.Equal(t, updated, w.Header().Get("ETag"))
+	assert.Equal(t, "3", w.Header().Get("X-Resource-Version"))
+
+	// GET得到的ETag与PUT返回的一致
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/cache/resource/conditional?vary=Accept-Language", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, updated, w.Header().Get("ETag"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/cache/stats?id=conditional", nil)
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"not_modified":2`)
+	assert.Contains(t, w.Body.String(), `"precondition_failed":2`)
+}
+
+// TestTransferLargeRange 测试Range、multipart/byteranges、If-Range和416
+func TestTransferLargeRange(t *testing.T) {
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/transfer/large/1", nil)
+	router.ServeHTTP(w, req)
+	full := w.Body.Bytes()
+	assert.Len(t, full, 1024*1024)
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+	etag := w.Header().Get("ETag")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/transfer/large/1", nil)
+	req.Header.Set("Range", "bytes=1000-1999")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 206, w.Code)
+	assert.Equal(t, "bytes 1000-1999/1048576", w.Header().Get("Content-Range"))
+	assert.Equal(t, full[1000:2000], w.Body.Bytes())
+
+	// 后缀范围
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/transfer/large/1", nil)
+	req.Header.Set("Range", "bytes=-10")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, full[len(full)-10:], w.Body.Bytes())
+
+	// 多范围
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/transfer/large/1", nil)
+	req.Header.Set("Range", "bytes=0-9, 500000-500009")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 206, w.Code)
+	assert.Equal(t, strconv.Itoa(w.Body.Len()), w.Header().Get("Content-Length"))
+	_, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	assert.NoError(t, err)
+	reader := multipart.NewReader(bytes.NewReader(w.Body.Bytes()), params["boundary"])
+	part, err := reader.NextPart()
+	assert.NoError(t, err)
+	assert.Equal(t, "bytes 0-9/1048576", part.Header.Get("Content-Range"))
+	data, _ := io.ReadAll(part)
+	assert.Equal(t, full[0:10], data)
+	part, err = reader.NextPart()
+	assert.NoError(t, err)
+	data, _ = io.ReadAll(part)
+	assert.Equal(t, full[500000:500010], data)
+
+	// If-Range不匹配时返回完整内容
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/transfer/large/1", nil)
+	req.Header.Set("Range", "bytes=0-9")
+	req.Header.Set("If-Range", `"stale"`)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/transfer/large/1", nil)
+	req.Header.Set("Range", "bytes=0-9")
+	req.Header.Set("If-Range", etag)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 206, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/transfer/large/1", nil)
+	req.Header.Set("Range", "bytes=2000000-")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 416, w.Code)
+	assert.Equal(t, "bytes */1048576", w.Header().Get("Content-Range"))
+}
+
+// TestIntegrityDigests 测试响应摘要头、trailer和上传摘要校验
+func TestIntegrityDigests(t *testing.T) {
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/bytes/100", nil)
+	router.ServeHTTP(w, req)
+	sum := sha256.Sum256(w.Body.Bytes())
+	encoded := base64.StdEncoding.EncodeToString(sum[:])
+	assert.Equal(t, hex.EncodeToString(sum[:]), w.Header().Get("X-Content-SHA256"))
+	assert.Contains(t, w.Header().Get("Repr-Digest"), "sha-256=:"+encoded+":")
+	assert.Contains(t, w.Header().Get("Digest"), "SHA-256="+encoded)
+	assert.NotEmpty(t, w.Header().Get("Content-MD5"))
+
+	// trailer方式
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/json?digest=trailer", nil)
+	router.ServeHTTP(w, req)
+	result := w.Result()
+	sum = sha256.Sum256(w.Body.Bytes())
+	assert.Contains(t, result.Header.Get("Trailer"), "X-Content-SHA256")
+	assert.Equal(t, hex.EncodeToString(sum[:]), result.Trailer.Get("X-Content-SHA256"))
+
+	// 压缩后不发送按原始数据计算的摘要
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/text?encoding=gzip", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Empty(t, w.Header().Get("Repr-Digest"))
+
+	// 大文件：完整响应和Range响应的Repr-Digest一致
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/transfer/large/1", nil)
+	router.ServeHTTP(w, req)
+	sum = sha256.Sum256(w.Body.Bytes())
+	assert.Equal(t, hex.EncodeToString(sum[:]), w.Header().Get("X-Content-SHA256"))
+	reprDigest := w.Header().Get("Repr-Digest")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/transfer/large/1", nil)
+	req.Header.Set("Range", "bytes=0-9")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 206, w.Code)
+	assert.Equal(t, reprDigest, w.Header().Get("Repr-Digest"))
+	assert.Empty(t, w.Header().Get("Content-MD5"))
+
+	// 摘要未缓存时，Range和HEAD不为此计算整个文件的摘要，除非显式指定digest=header
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/transfer/large/7", nil)
+	req.Header.Set("Range", "bytes=0-9")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 206, w.Code)
+	assert.Empty(t, w.Header().Get("Repr-Digest"))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("HEAD", "/api/transfer/large/7", nil)
+	router.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("Repr-Digest"))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/transfer/large/7?chunked=true", nil)
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Header().Get("Trailer"), "Repr-Digest")
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/transfer/large/7?digest=header", nil)
+	req.Header.Set("Range", "bytes=0-9")
+	router.ServeHTTP(w, req)
+	assert.NotEmpty(t, w.Header().Get("Repr-Digest"))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("HEAD", "/api/transfer/large/7", nil)
+	router.ServeHTTP(w, req)
+	assert.NotEmpty(t, w.Header().Get("Repr-Digest"))
+
+	// 上传摘要校验
+	body := []byte("integrity upload body")
+	sum = sha256.Sum256(body)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/integrity/verify", bytes.NewReader(body))
+	req.Header.Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum[:])+":, unixsum=:AAAA:")
+	req.Header.Set("X-Content-SHA256", hex.EncodeToString(sum[:]))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"verified":true`)
+	assert.Contains(t, w.Body.String(), `"supported":false`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/integrity/verify", bytes.NewReader(body))
+	req.Header.Set("X-Content-SHA256", strings.Repeat("00", 32))
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"present":true`)
+	assert.Contains(t, w.Body.String(), `"verified":false`)
+
+	// 压缩上传：摘要针对解码前的字节
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, _ = gz.Write(body)
+	_ = gz.Close()
+	md5Sum := md5.Sum(compressed.Bytes())
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/parse/binary", bytes.NewReader(compressed.Bytes()))
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5Sum[:]))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"verified":true`)
+}
+
+// TestInspectRequests 测试请求记录的过滤、详情和HAR导出
+func TestInspectRequests(t *testing.T) {
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/api/inspect/requests", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/test?from=inspect", strings.NewReader(`{"name":"inspect"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Inspect-Marker", "marker-123")
+	req.Header.Set("Cookie", "a=1; b=2")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/json", nil)
+	router.ServeHTTP(w, req)
+
+	// 按头部和方法过滤
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/inspect/requests?method=post&header=X-Inspect-Marker:marker", nil)
+	router.ServeHTTP(w, req)
+	var list struct {
+		Data struct {
+			Requests []inspect.Summary `json:"requests"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.Data.Requests, 1)
+	assert.True(t, strings.HasSuffix(list.Data.Requests[0].URL, "/api/test?from=inspect"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/inspect/requests?path=/api/json", nil)
+	router.ServeHTTP(w, req)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.Data.Requests, 1)
+
+	// 详情
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/inspect/requests/%d", list.Data.Requests[0].ID-1), nil)
+	router.ServeHTTP(w, req)
+	var detail struct {
+		Data inspect.Entry `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+	assert.Equal(t, "POST", detail.Data.Method)
+	assert.Equal(t, `{"name":"inspect"}`, detail.Data.BodyPreview)
+	assert.Equal(t, 200, detail.Data.Status)
+	assert.Nil(t, detail.Data.TLS)
+
+	// HAR导出
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/inspect/har?method=POST", nil)
+	router.ServeHTTP(w, req)
+	var har inspect.HAR
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &har))
+	assert.Equal(t, "1.2", har.Log.Version)
+	assert.Len(t, har.Log.Entries, 1)
+	entry := har.Log.Entries[0]
+	assert.Equal(t, "POST", entry.Request.Method)
+	assert.Equal(t, []inspect.HARNameValue{{Name: "from", Value: "inspect"}}, entry.Request.QueryString)
+	assert.Len(t, entry.Request.Cookies, 2)
+	assert.Equal(t, "application/json", entry.Request.PostData.MimeType)
+	assert.Equal(t, 200, entry.Response.Status)
+
+	// 查询接口自身不被记录
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/inspect/requests?path=/api/inspect", nil)
+	router.ServeHTTP(w, req)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Empty(t, list.Data.Requests)
+
+	// 由CORS直接应答的预检请求也被记录
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("OPTIONS", "/api/json", nil)
+	req.Header.Set("Origin", "http://example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 204, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/inspect/requests?method=OPTIONS", nil)
+	router.ServeHTTP(w, req)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	if assert.Len(t, list.Data.Requests, 1) {
+		assert.True(t, strings.HasSuffix(list.Data.Requests[0].URL, "/api/json"))
+		assert.Equal(t, 204, list.Data.Requests[0].Status)
+	}
+}
+
+// TestMockRoutes 测试YAML配置的模拟接口
+func TestMockRoutes(t *testing.T) {
+	config, err := mock.Parse([]byte(`
+routes:
+  - name: user
+    method: GET
+    path: /users/:id
+    headers:
+      X-Upstream: legacy
+      Set-Cookie: [a=1, b=2]
+    body: {id: 1, name: mock}
+  - method: POST
+    path: /users/:id
+    status: 201
+    body: created
+  - path: /files/*rest
+    status: 418
+    delay: 10ms
+  - path: /broken
+    faults:
+      - probability: 1
+        type: status
+        status: 503
+        headers: {Retry-After: "5"}
+        body: down
+`), "yaml", ".")
+	assert.NoError(t, err)
+
+	router := gin.New()
+	(&mock.MockModule{Config: config}).RegisterRoutes(router)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/mock/users/42", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "legacy", w.Header().Get("X-Upstream"))
+	assert.Equal(t, []string{"a=1", "b=2"}, w.Header()["Set-Cookie"])
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"id":1,"name":"mock"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/mock/users/42", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 201, w.Code)
+	assert.Equal(t, "created", w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/mock/users/42", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 405, w.Code)
+	assert.Equal(t, "GET, POST", w.Header().Get("Allow"))
+
+	start := time.Now()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/mock/files/a/b/c.txt", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 418, w.Code)
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/mock/broken", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 503, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Retry-After"))
+	assert.Equal(t, "down", w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/mock/unknown", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/mock/routes", nil)
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"faults":{"status":1}`)
+
+	// 配置错误在加载时报告
+	_, err = mock.Parse([]byte(`{"routes":[{"path":"/x","faults":[{"probability":0.8,"type":"reset"},{"probability":0.5,"type":"empty"}]}]}`), "json", ".")
+	assert.Error(t, err)
+	_, err = mock.Parse([]byte("routes:\n  - path: /x\n    stauts: 200\n"), "yaml", ".")
+	assert.Error(t, err)
+}
+
+// TestMockAdmin 测试运行时管理模拟路由
+func TestMockAdmin(t *testing.T) {
+	router := gin.New()
+	(&mock.MockModule{}).RegisterRoutes(router)
+
+	send := func(method, path, contentType, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/admin/mocks", "application/json", `{"method":"GET","path":"/orders/:id","body":{"state":"paid"}}`)
+	assert.Equal(t, 201, w.Code)
+	assert.Equal(t, "/admin/mocks/1", w.Header().Get("Location"))
+
+	// 条件更具体、优先级更高的路由先匹配
+	w = send("POST", "/admin/mocks", "application/yaml", "id: refund\npriority: 5\npath: /orders/:id\nmatch:\n  query: {case: refund}\n  headers: {X-Tenant: \"*\"}\nstatus: 409\nbody: refunded\n")
+	assert.Equal(t, 201, w.Code)
+	w = send("POST", "/admin/mocks", "application/json", `{"id":"refund","path":"/x"}`)
+	assert.Equal(t, 409, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/mock/orders/7?case=refund", nil)
+	req.Header.Set("x-tenant", "acme")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 409, w.Code)
+	assert.Equal(t, "refunded", w.Body.String())
+
+	w = send("GET", "/mock/orders/7?case=refund", "", "")
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"state":"paid"}`, w.Body.String())
+
+	// 列表包含命中次数
+	var list struct {
+		Data struct {
+			Routes []struct {
+				ID    string `json:"id"`
+				Stats struct {
+					Hits int `json:"hits"`
+				} `json:"stats"`
+			} `json:"routes"`
+		} `json:"data"`
+	}
+	w = send("GET", "/admin/mocks", "", "")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	if assert.Len(t, list.Data.Routes, 2) {
+		assert.Equal(t, "refund", list.Data.Routes[0].ID)
+		assert.Equal(t, 1, list.Data.Routes[0].Stats.Hits)
+		assert.Equal(t, 1, list.Data.Routes[1].Stats.Hits)
+	}
+
+	// 更新后立即生效，统计重新计数
+	w = send("PUT", "/admin/mocks/1", "application/json", `{"method":"GET","path":"/orders/:id","status":500}`)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"hits":0`)
+	assert.Equal(t, 500, send("GET", "/mock/orders/7", "", "").Code)
+
+	// 管理接口不允许读取服务器文件
+	assert.Equal(t, 400, send("PUT", "/admin/mocks/file", "application/json", `{"path":"/f","body_file":"/etc/hostname"}`).Code)
+	assert.Equal(t, 400, send("PUT", "/admin/mocks/other", "application/json", `{"id":"1","path":"/f"}`).Code)
+
+	assert.Equal(t, 200, send("DELETE", "/admin/mocks/refund", "", "").Code)
+	assert.Equal(t, 404, send("GET", "/admin/mocks/refund", "", "").Code)
+
+	// 整体替换后只保留新路由
+	w = send("PUT", "/admin/mocks", "application/json", `{"routes":[{"path":"/health","body":"ok"}]}`)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, 404, send("GET", "/mock/orders/7", "", "").Code)
+	assert.Equal(t, "ok", send("GET", "/mock/health", "", "").Body.String())
+
+	w = send("DELETE", "/admin/mocks", "", "")
+	assert.Contains(t, w.Body.String(), `"deleted":1`)
+	assert.Equal(t, 404, send("GET", "/mock/health", "", "").Code)
+}
+
+// TestStatusSequence 测试按调用次数变化的状态码序列
+func TestStatusSequence(t *testing.T) {
+	router := setupTestRouter()
+
+	call := func(path string, header string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		if header != "" {
+			req.Header.Set("X-Test-Id", header)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 前两次失败，之后一直成功
+	codes := make([]int, 0)
+	for i := 0; i < 4; i++ {
+		codes = append(codes, call("/api/sequence/retry?steps=503*2,200", "").Code)
+	}
+	assert.Equal(t, []int{503, 503, 200, 200}, codes)
+
+	// 循环交替两个消息体
+	bodies := make([]string, 0)
+	for i := 0; i < 3; i++ {
+		bodies = append(bodies, call("/api/sequence/flap?steps=200:a,200:b&loop=1", "").Body.String())
+	}
+	assert.Equal(t, []string{"a", "b", "a"}, bodies)
+
+	// 按请求头分别计数
+	assert.Equal(t, 500, call("/api/sequence/per-case?steps=500,200&key=header:X-Test-Id", "one").Code)
+	assert.Equal(t, 500, call("/api/sequence/per-case?steps=500,200&key=header:X-Test-Id", "two").Code)
+	w := call("/api/sequence/per-case?steps=500,200&key=header:X-Test-Id", "one")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-Sequence-Call"))
+	assert.Equal(t, "2/2", w.Header().Get("X-Sequence-Step"))
+
+	// 重置后重新开始
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/api/sequences/retry", nil)
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"reset":1`)
+	assert.Equal(t, 503, call("/api/sequence/retry?steps=503*2,200", "").Code)
+
+	assert.Equal(t, 400, call("/api/sequence/bad?steps=abc", "").Code)
+	assert.Equal(t, 400, call("/api/sequence/bad?steps=200&key=host", "").Code)
+}
+
+// TestChaosMiddleware 测试故障注入中间件
+func TestChaosMiddleware(t *testing.T) {
+	router := setupTestRouter()
+
+	send := func(method, path string, headers map[string]string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 附加延迟
+	start := time.Now()
+	w := send("GET", "/api/json?chaos_latency=30ms", nil, "")
+	assert.Equal(t, 200, w.Code)
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+	assert.Contains(t, w.Header().Get("X-Chaos-Applied"), "latency=")
+
+	// 错误状态码，不调用处理器
+	w = send("GET", "/api/json", map[string]string{"X-Chaos-Error-Rate": "1", "X-Chaos-Error-Status": "503"}, "")
+	assert.Equal(t, 503, w.Code)
+	assert.Equal(t, "error=503", w.Header().Get("X-Chaos-Applied"))
+
+	// 测试环境无法接管连接，截断表现为只写出指定字节数
+	w = send("GET", "/api/transfer/large/1?chaos_truncate_rate=1&chaos_truncate_after=1000", nil, "")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, 1000, w.Body.Len())
+	assert.Equal(t, "1048576", w.Header().Get("Content-Length"))
+
+	// 发送响应头前断开在测试环境退回502
+	assert.Equal(t, 502, send("GET", "/api/json?chaos_drop_rate=1", nil, "").Code)
+
+	// 停顿后继续发送完整内容
+	start = time.Now()
+	w = send("GET", "/api/json?chaos_stall_rate=1&chaos_stall=30ms", nil, "")
+	assert.Equal(t, 200, w.Code)
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+	assert.True(t, json.Valid(w.Body.Bytes()))
+
+	assert.Equal(t, 400, send("GET", "/api/json?chaos_error_rate=2", nil, "").Code)
+
+	// 全局配置只对指定路径生效，X-Chaos: off跳过
+	w = send("PUT", "/api/chaos", map[string]string{"Content-Type": "application/json"}, `{"error_rate":1,"error_status":[418],"paths":["/api/json"]}`)
+	assert.Equal(t, 200, w.Code)
+	defer chaos.SetGlobal(chaos.NewOptions())
+	assert.Equal(t, 418, send("GET", "/api/json", nil, "").Code)
+	assert.Equal(t, 200, send("GET", "/api/test", nil, "").Code)
+	assert.Equal(t, 200, send("GET", "/api/json", map[string]string{"X-Chaos": "off"}, "").Code)
+	assert.Equal(t, 200, send("GET", "/api/chaos", nil, "").Code)
+
+	assert.Equal(t, 200, send("DELETE", "/api/chaos", nil, "").Code)
+	assert.Equal(t, 200, send("GET", "/api/json", nil, "").Code)
+
+	_, err := chaos.Parse("latency=abc")
+	assert.Error(t, err)
+}
+
+// TestMalformedResponses 测试畸形响应用例
+func TestMalformedResponses(t *testing.T) {
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/malformed", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":15`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/malformed/unknown", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+
+	// 无法接管连接时返回501和原始字节
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/malformed/obs-fold", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 501, w.Code)
+	assert.Contains(t, w.Body.String(), `X-Folded: first line\r\n continued line`)
+
+	// 通过真实连接收到的字节与用例完全一致
+	server := httptest.NewServer(setupTestRouter())
+	defer server.Close()
+	for _, name := range []string{"conflicting-content-length", "bare-lf", "http09"} {
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		assert.NoError(t, err)
+		_, _ = fmt.Fprintf(conn, "GET /api/malformed/%s HTTP/1.1\r\nHost: test\r\n\r\n", name)
+		received, err := io.ReadAll(conn)
+		conn.Close()
+		assert.NoError(t, err)
+		expected, _ := malformed.Find(name)
+		assert.Equal(t, expected.Raw, string(received), name)
+	}
+
+	// 标准客户端拒绝冲突的Content-Length
+	_, err := http.Get(server.URL + "/api/malformed/conflicting-content-length")
+	assert.Error(t, err)
+}
+
+// TestRequestSmuggling 测试按连接的分帧记录能发现CL.TE走私留下的前缀
+func TestRequestSmuggling(t *testing.T) {
+	server := startCaptureServer()
+	defer server.Close()
+
+	client := &http.Client{}
+	resp, err := client.Get(server.URL + "/api/raw/desync/cases?host=proxy.test")
+	assert.NoError(t, err)
+	var listed struct {
+		Data struct {
+			Cases []raw.DesyncCase `json:"cases"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&listed))
+	resp.Body.Close()
+	var clte raw.DesyncCase
+	for _, item := range listed.Data.Cases {
+		if item.Name == "cl-te" {
+			clte = item
+		}
+	}
+	assert.Contains(t, clte.Raw, "Host: proxy.test\r\n")
+
+	// 直接发给服务器相当于代理按Content-Length转发，服务器按分块解析后剩下的G污染下一个请求
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	fmt.Fprint(conn, clte.Raw+clte.FollowUp)
+
+	reader := bufio.NewReader(conn)
+	resp, err = http.ReadResponse(reader, nil)
+	assert.NoError(t, err)
+	var first struct {
+		Data struct {
+			ConnID  int64           `json:"conn_id"`
+			Current raw.FrameRecord `json:"current"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&first))
+	resp.Body.Close()
+	assert.Equal(t, "chunked", first.Data.Current.Framing)
+	assert.Contains(t, first.Data.Current.Flags, raw.FlagCLAndTE)
+
+	resp, err = http.ReadResponse(reader, nil)
+	assert.NoError(t, err)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	resp, err = client.Get(fmt.Sprintf("%s/api/raw/connections/%d", server.URL, first.Data.ConnID))
+	assert.NoError(t, err)
+	var report struct {
+		Data raw.ConnReport `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	resp.Body.Close()
+	assert.True(t, report.Data.Suspicious)
+	assert.Len(t, report.Data.Requests, 2)
+	smuggled := report.Data.Requests[1]
+	assert.Equal(t, "GGET", smuggled.Method)
+	assert.Equal(t, "G", smuggled.Prefix)
+	assert.Contains(t, smuggled.Flags, raw.FlagPrefixed)
+
+	resp, err = client.Get(server.URL + "/api/raw/connections?suspicious=1")
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(t, string(body), fmt.Sprintf(`"conn_id":%d`, first.Data.ConnID))
+
+	resp, err = client.Get(server.URL + "/api/raw/connections/999999")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+// TestInformationalResponses 测试100-continue、103 Early Hints和102 Processing
+func TestInformationalResponses(t *testing.T) {
+	server := httptest.NewServer(setupTestRouter())
+	defer server.Close()
+
+	// 记录客户端收到的1xx状态码和103中的Link头
+	var interim []int
+	var hintLinks []string
+	trace := &httptrace.ClientTrace{
+		Got100Continue: func() { interim = append(interim, 100) },
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			interim = append(interim, code)
+			if code == http.StatusEarlyHints {
+				hintLinks = append(hintLinks, header.Values("Link")...)
+			}
+			return nil
+		},
+	}
+	send := func(method, path string, body io.Reader, expect bool) (*http.Response, string) {
+		interim, hintLinks = nil, nil
+		req, _ := http.NewRequest(method, server.URL+path, body)
+		if expect {
+			req.Header.Set("Expect", "100-continue")
+		}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+		client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: 5 * time.Second}}
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(data)
+	}
+
+	resp, body := send("POST", "/api/expect-continue", strings.NewReader("hello"), true)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, interim, 100)
+	assert.Contains(t, body, `"body_size":5`)
+
+	// 拒绝时客户端不应发送消息体
+	resp, body = send("POST", "/api/expect-continue?mode=reject", strings.NewReader("hello"), true)
+	assert.Equal(t, http.StatusExpectationFailed, resp.StatusCode)
+	assert.NotContains(t, interim, 100)
+	assert.Contains(t, body, `"expect_continue":true`)
+
+	resp, _ = send("GET", "/api/early-hints?count=2&link=%3C%2Fa.css%3E%3B+rel%3Dpreload", nil, false)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, []int{103, 103}, interim)
+	assert.Equal(t, []string{"</a.css>; rel=preload", "</a.css>; rel=preload"}, hintLinks)
+	assert.Equal(t, "</a.css>; rel=preload", resp.Header.Get("Link"))
+
+	// 压缩中间件包装响应后仍能找到底层连接发送1xx
+	resp, body = send("GET", "/api/early-hints?count=1&encoding=gzip", nil, false)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, []int{103}, interim)
+	assert.Equal(t, "gzip", resp.Header.Get("X-Compression"))
+	assert.Contains(t, body, `"code":200`)
+
+	resp, body = send("GET", "/api/processing?seconds=300ms&interval=100ms", nil, false)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.GreaterOrEqual(t, len(interim), 2)
+	for _, code := range interim {
+		assert.Equal(t, http.StatusProcessing, code)
+	}
+	assert.Contains(t, body, fmt.Sprintf(`"processing":%d`, len(interim)))
+
+	// httptest.ResponseRecorder无法发送1xx
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/early-hints", nil)
+	setupTestRouter().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
+
+// TestTrailers 测试流式响应trailer和请求trailer回显（HTTP/1.1分块和HTTP/2）
+func TestTrailers(t *testing.T) {
+	http1 := httptest.NewServer(setupTestRouter())
+	defer http1.Close()
+	http2 := httptest.NewUnstartedServer(setupTestRouter())
+	http2.EnableHTTP2 = true
+	http2.StartTLS()
+	defer http2.Close()
+
+	for major, server := range map[int]*httptest.Server{1: http1, 2: http2} {
+		client := server.Client()
+
+		resp, err := client.Get(server.URL + "/api/transfer/trailers?size=1000&chunks=3&grpc=1&trailer=X-Custom:a")
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		proto := resp.Proto
+		assert.Equal(t, major, resp.ProtoMajor)
+		assert.Len(t, body, 1000, proto)
+		sum := sha256.Sum256(body)
+		assert.Equal(t, hex.EncodeToString(sum[:]), resp.Trailer.Get("X-Content-SHA256"), proto)
+		assert.Equal(t, "1000", resp.Trailer.Get("X-Body-Bytes"), proto)
+		assert.Equal(t, "3", resp.Trailer.Get("X-Chunk-Count"), proto)
+		assert.Equal(t, "ok", resp.Trailer.Get("X-Stream-Status"), proto)
+		assert.Equal(t, "0", resp.Trailer.Get("Grpc-Status"), proto)
+		assert.Equal(t, "a", resp.Trailer.Get("X-Custom"), proto)
+		assert.Contains(t, resp.Trailer.Get("Server-Timing"), "total;dur=", proto)
+
+		// 请求trailer在消息体发送完后才写入，MultiReader隐藏长度使HTTP/1.1改用分块编码
+		payload := "hello trailers"
+		payloadSum := sha256.Sum256([]byte(payload))
+		req, _ := http.NewRequest("POST", server.URL+"/api/transfer/trailers/echo", io.MultiReader(strings.NewReader(payload)))
+		req.Trailer = http.Header{"X-Content-Sha256": nil, "X-Missing": nil}
+		req.Trailer.Set("X-Content-Sha256", hex.EncodeToString(payloadSum[:]))
+		resp, err = client.Do(req)
+		assert.NoError(t, err)
+		var echoed struct {
+			Data struct {
+				BodySize  int64               `json:"body_size"`
+				Declared  []string            `json:"declared_trailers"`
+				Trailers  map[string][]string `json:"trailers"`
+				Missing   []string            `json:"missing"`
+				Integrity integrity.Result    `json:"integrity"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&echoed))
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, int64(len(payload)), echoed.Data.BodySize, proto)
+		assert.Equal(t, []string{"X-Content-Sha256", "X-Missing"}, echoed.Data.Declared, proto)
+		assert.Equal(t, []string{"X-Missing"}, echoed.Data.Missing, proto)
+		assert.Equal(t, []string{hex.EncodeToString(payloadSum[:])}, echoed.Data.Trailers["X-Content-Sha256"], proto)
+		assert.True(t, echoed.Data.Integrity.Verified, proto)
+		assert.Equal(t, hex.EncodeToString(payloadSum[:]), resp.Trailer.Get("X-Content-Sha256"), proto)
+	}
+}
+
+// TestTrailersClientGone 测试客户端断开后trailer流停止等待写入间隔
+func TestTrailersClientGone(t *testing.T) {
+	router := setupTestRouter()
+	ctx, cancel := context.WithCancel(context.Background())
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "/api/transfer/trailers?size=100&chunks=100&interval=10000", nil)
+
+	done := make(chan struct{})
+	go func() {
+		router.ServeHTTP(w, req)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("客户端断开后处理函数仍在等待")
+	}
+	assert.Empty(t, w.Header().Get("X-Chunk-Count"))
+}
+
+// TestHTTPSLocalCA 测试本地CA的生成、加载、下载和按SNI签发证书
+func TestHTTPSLocalCA(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := https.LoadOrCreateCA(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "generated", ca.Source)
+	loaded, err := https.LoadOrCreateCA(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "loaded", loaded.Source)
+	assert.Equal(t, ca.Fingerprint(), loaded.Fingerprint())
+
+	hosts, err := https.NewVirtualHosts(loaded, "")
+	assert.NoError(t, err)
+	testHosts = hosts
+	router := setupTestRouter()
+	testHosts = nil
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := https.NewServer(router, hosts)
+	go server.ServeTLS(listener, "", "")
+	defer server.Close()
+
+	// 未启用时CA接口返回404
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/tls/ca.pem", nil)
+	setupTestRouter().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/tls/ca.pem", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	pool := x509.NewCertPool()
+	assert.True(t, pool.AppendCertsFromPEM(w.Body.Bytes()))
+
+	// 任意主机名都解析到监听地址，由SNI决定签发的证书
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool},
+		ForceAttemptHTTP2: true,
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, listener.Addr().String())
+		},
+	}}
+	for _, host := range []string{"origin.test", "api.example.com"} {
+		resp, err := client.Get("https://" + host + "/api/test")
+		assert.NoError(t, err)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, 2, resp.ProtoMajor)
+		assert.Equal(t, []string{host}, resp.TLS.PeerCertificates[0].DNSNames)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/tls/certificates", nil)
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"name":"api.example.com"`)
+	assert.Contains(t, w.Body.String(), `"count":2`)
+}
+
+// TestTLSScenarios 测试按SNI选择的证书缺陷场景
+func TestTLSScenarios(t *testing.T) {
+	ca, err := https.NewCA()
+	assert.NoError(t, err)
+	hosts, err := https.NewVirtualHosts(ca, "8080")
+	assert.NoError(t, err)
+	testHosts = hosts
+	router := setupTestRouter()
+	testHosts = nil
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := https.NewServer(router, hosts)
+	go server.ServeTLS(listener, "", "")
+	defer server.Close()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/tls/scenarios?domain=example.test", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"host":"expired.example.test"`)
+	assert.Contains(t, w.Body.String(), fmt.Sprintf(`"count":%d`, len(https.Scenarios)))
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	handshake := func(name string) (*tls.ConnectionState, error) {
+		conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{RootCAs: pool, ServerName: name})
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		state := conn.ConnectionState()
+		return &state, nil
+	}
+
+	var invalid x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	var unknown x509.UnknownAuthorityError
+	_, err = handshake("expired.example.test")
+	if assert.ErrorAs(t, err, &invalid) {
+		assert.Equal(t, x509.Expired, invalid.Reason)
+	}
+	_, err = handshake("not-yet-valid.example.test")
+	if assert.ErrorAs(t, err, &invalid) {
+		assert.Equal(t, x509.Expired, invalid.Reason)
+	}
+	_, err = handshake("wronghost.example.test")
+	assert.ErrorAs(t, err, &hostname)
+	for _, name := range []string{"selfsigned", "untrusted-root", "incomplete-chain", "sha1"} {
+		_, err = handshake(name + ".example.test")
+		assert.ErrorAs(t, err, &unknown, name)
+	}
+
+	state, err := handshake("valid.example.test")
+	assert.NoError(t, err)
+	state, err = handshake("weak-key.example.test")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1024, state.PeerCertificates[0].PublicKey.(*rsa.PublicKey).N.BitLen())
+	}
+
+	// 不检查吊销状态时握手成功，证书序列号出现在CRL中
+	state, err = handshake("revoked.example.test")
+	if assert.NoError(t, err) {
+		leaf := state.PeerCertificates[0]
+		assert.Equal(t, []string{"http://127.0.0.1:8080/api/tls/crl"}, leaf.CRLDistributionPoints)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/api/tls/crl", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, "application/pkix-crl", w.Header().Get("Content-Type"))
+		crl, err := x509.ParseRevocationList(w.Body.Bytes())
+		if assert.NoError(t, err) {
+			assert.NoError(t, crl.CheckSignatureFrom(ca.Cert))
+			assert.Len(t, crl.RevokedCertificateEntries, 1)
+			assert.Equal(t, 0, crl.RevokedCertificateEntries[0].SerialNumber.Cmp(leaf.SerialNumber))
+		}
+	}
+}
+
+// TestMutualTLS 测试客户端证书策略、客户端证书签发和证书链回显
+func TestMutualTLS(t *testing.T) {
+	ca, err := https.NewCA()
+	assert.NoError(t, err)
+	hosts, err := https.NewVirtualHosts(ca, "")
+	assert.NoError(t, err)
+	hosts.ClientAuth, err = https.ParseClientAuth("verify")
+	assert.NoError(t, err)
+	_, err = https.ParseClientAuth("optional")
+	assert.Error(t, err)
+	testHosts = hosts
+	router := setupTestRouter()
+	testHosts = nil
+
+	// 策略在创建服务器时生效，每种策略使用单独的监听器
+	serve := func() string {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		server := https.NewServer(router, hosts)
+		go server.ServeTLS(listener, "", "")
+		t.Cleanup(func() { server.Close() })
+		return listener.Addr().String()
+	}
+	addr := serve()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/tls/client-certificate?cn=proxy-client&san=client.test&san=ops@example.test&days=7", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var issued struct {
+		Data struct {
+			CertPEM string `json:"cert_pem"`
+			KeyPEM  string `json:"key_pem"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &issued))
+	clientCert, err := tls.X509KeyPair([]byte(issued.Data.CertPEM), []byte(issued.Data.KeyPEM))
+	assert.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/tls/client-certificate?format=pem", nil)
+	router.ServeHTTP(w, req)
+	_, err = tls.X509KeyPair(w.Body.Bytes(), w.Body.Bytes())
+	assert.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	get := func(addr string, certs ...tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: pool, ServerName: "mtls.test", Certificates: certs},
+			ForceAttemptHTTP2: true,
+		}}
+		defer client.CloseIdleConnections()
+		resp, err := client.Get("https://" + addr + "/api/tls/client")
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	// verify策略下不提供证书无法完成请求
+	_, err = get(addr)
+	assert.Error(t, err)
+
+	body, err := get(addr, clientCert)
+	assert.NoError(t, err)
+	assert.Contains(t, body, `"mode":"verify"`)
+	assert.Contains(t, body, `"presented":true`)
+	assert.Contains(t, body, `"verified":true`)
+	assert.Contains(t, body, `"subject":"CN=proxy-client,O=HTTP Proxy Test Tool"`)
+	assert.Contains(t, body, `"dns_names":["client.test"]`)
+	assert.Contains(t, body, `"email_addresses":["ops@example.test"]`)
+
+	// request策略下接受任意证书，由接口给出校验结果
+	hosts.ClientAuth = tls.RequestClientCert
+	addr = serve()
+	other, err := https.NewCA()
+	assert.NoError(t, err)
+	_, certPEM, keyPEM, err := other.IssueClient(https.ClientRequest{CommonName: "stranger", Validity: time.Hour})
+	assert.NoError(t, err)
+	strangerCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	assert.NoError(t, err)
+	body, err = get(addr, strangerCert)
+	assert.NoError(t, err)
+	assert.Contains(t, body, `"mode":"request"`)
+	assert.Contains(t, body, `"verified":false`)
+	assert.Contains(t, body, `"verify_error"`)
+	body, err = get(addr)
+	assert.NoError(t, err)
+	assert.Contains(t, body, `"presented":false`)
+
+	// 终止TLS的代理转发的证书头
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/tls/client", nil)
+	req.Header.Set("X-SSL-Client-Verify", "SUCCESS")
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"tls":false`)
+	assert.Contains(t, w.Body.String(), `"X-SSL-Client-Verify":"SUCCESS"`)
+}
+
+// TestTLSInfo 测试TLS握手信息和由原始ClientHello计算的JA3/JA4指纹
+func TestTLSInfo(t *testing.T) {
+	// 手工构造带GREASE取值的ClientHello
+	vector := func(prefix int, data []byte) []byte {
+		length := []byte{byte(len(data) >> 8), byte(len(data))}
+		return append(length[2-prefix:], data...)
+	}
+	extension := func(kind uint16, data []byte) []byte {
+		return append([]byte{byte(kind >> 8), byte(kind)}, vector(2, data)...)
+	}
+	var extensions []byte
+	extensions = append(extensions, extension(0x0a0a, nil)...)
+	extensions = append(extensions, extension(0, vector(2, append([]byte{0}, vector(2, []byte("a.test"))...)))...)
+	extensions = append(extensions, extension(10, vector(2, []byte{0x0a, 0x0a, 0x00, 0x1d}))...)
+	extensions = append(extensions, extension(11, vector(1, []byte{0}))...)
+	extensions = append(extensions, extension(13, vector(2, []byte{0x04, 0x03, 0x08, 0x04}))...)
+	extensions = append(extensions, extension(16, vector(2, append(vector(1, []byte("h2")), vector(1, []byte("http/1.1"))...)))...)
+	extensions = append(extensions, extension(43, vector(1, []byte{0x0a, 0x0a, 0x03, 0x04, 0x03, 0x03}))...)
+	body := append([]byte{0x03, 0x03}, make([]byte, 32)...)
+	body = append(body, 0)
+	body = append(body, vector(2, []byte{0x0a, 0x0a, 0x13, 0x01, 0x13, 0x02})...)
+	body = append(body, vector(1, []byte{0})...)
+	body = append(body, vector(2, extensions)...)
+	message := append([]byte{1, 0, byte(len(body) >> 8), byte(len(body))}, body...)
+
+	hello, err := https.ParseClientHello(message)
+	assert.NoError(t, err)
+	assert.Equal(t, "a.test", hello.ServerName)
+	assert.Equal(t, []string{"h2", "http/1.1"}, hello.ALPN)
+	assert.Equal(t, "771,4865-4866,0-10-11-13-16-43,29,0", hello.JA3())
+	assert.Equal(t, "171cd0d398cf929f8fa2e3d771ca4bea", hello.JA3Hash())
+	assert.Equal(t, "t13d0206h2_1301,1302_000a,000b,000d,002b_0403,0804", hello.JA4(true))
+	assert.Equal(t, "t13d0206h2_62ed6f6ca7ad_fb71836bce29", hello.JA4(false))
+
+	ca, err := https.NewCA()
+	assert.NoError(t, err)
+	hosts, err := https.NewVirtualHosts(ca, "")
+	assert.NoError(t, err)
+	router := setupTestRouter()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := https.NewServer(router, hosts)
+	go server.ServeTLS(https.NewListener(listener), "", "")
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	get := func(config *tls.Config, query string) map[string]interface{} {
+		// Transport会改写NextProtos，复制后使用（会话缓存仍然共享）；未指定ALPN时协商h2
+		config = config.Clone()
+		transport := &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: len(config.NextProtos) == 0}
+		defer transport.CloseIdleConnections()
+		resp, err := (&http.Client{Transport: transport}).Get("https://" + listener.Addr().String() + "/api/tls/info" + query)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		defer resp.Body.Close()
+		var result struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return result.Data
+	}
+
+	// 限定TLS 1.2和单个加密套件，不协商ALPN
+	data := get(&tls.Config{
+		RootCAs:      pool,
+		ServerName:   "origin.test",
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+		NextProtos:   []string{"http/1.1"},
+	}, "")
+	assert.Equal(t, "TLS 1.2", data["version"])
+	assert.Equal(t, "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", data["cipher_suite"])
+	assert.Equal(t, "origin.test", data["sni"])
+	assert.Equal(t, "http/1.1", data["alpn"])
+	fingerprints := data["fingerprints"].(map[string]interface{})
+	assert.True(t, strings.HasPrefix(fingerprints["ja3_full"].(string), "771,49195,"))
+	sum := md5.Sum([]byte(fingerprints["ja3_full"].(string)))
+	assert.Equal(t, hex.EncodeToString(sum[:]), fingerprints["ja3"])
+	assert.Regexp(t, `^t12d01\d\dh1_648b5c445417_[0-9a-f]{12}$`, fingerprints["ja4"])
+
+	// 默认配置下协商TLS 1.3和h2，同一客户端配置的指纹一致
+	config := &tls.Config{RootCAs: pool, ServerName: "origin.test"}
+	data = get(config, "")
+	assert.Equal(t, "TLS 1.3", data["version"])
+	assert.Equal(t, "h2", data["alpn"])
+	assert.Equal(t, "HTTP/2.0", data["http_protocol"])
+	fingerprints = data["fingerprints"].(map[string]interface{})
+	assert.Regexp(t, `^t13d\d{4}h2_`, fingerprints["ja4"])
+	data = get(config, "?ja3="+fingerprints["ja3"].(string)+"&ja4=t13d0000h2_000000000000_000000000000")
+	assert.Equal(t, true, data["fingerprints"].(map[string]interface{})["ja3_match"])
+	assert.Equal(t, false, data["fingerprints"].(map[string]interface{})["ja4_match"])
+
+	// 复用会话缓存时第二次握手为会话恢复
+	config.ClientSessionCache = tls.NewLRUClientSessionCache(4)
+	assert.Equal(t, false, get(config, "")["resumed"])
+	assert.Equal(t, true, get(config, "")["resumed"])
+
+	// 明文请求
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/tls/info", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestChunkedHTTP10 测试HTTP/1.0请求分块传输接口时返回505
+func TestChunkedHTTP10(t *testing.T) {
+	router := setupTestRouter()
+	for _, path := range []string{"/api/transfer/chunked?chunks=1", "/api/transfer/large/1?chunked=true", "/api/transfer/chunked/stream"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/1.0", 1, 0
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusHTTPVersionNotSupported, w.Code, path)
+		assert.NotContains(t, w.Header().Get("Transfer-Encoding"), "chunked")
+	}
+}
+
+// TestRawParserOverflow 测试超大的Content-Length和分块大小不会使解析器溢出
+func TestRawParserOverflow(t *testing.T) {
+	for name, data := range map[string]string{
+		"content-length": "POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 9223372036854775807\r\n\r\nabc",
+		"chunk-size":     "POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\n7fffffffffffffff\r\nabc",
+	} {
+		assert.NotPanics(t, func() {
+			req, n, err := raw.ParseRequest([]byte(data))
+			assert.Error(t, err, name)
+			assert.Nil(t, req, name)
+			assert.Equal(t, 0, n, name)
+		}, name)
+	}
+}
+
+// FuzzParseRequest 测试解析器对任意输入不panic
+// 连接关闭时解析未处理字节的路径带有recover，解析器的panic只能靠这里发现：go test -fuzz=FuzzParseRequest
+func FuzzParseRequest(f *testing.F) {
+	for _, seed := range []string{
+		"GET / HTTP/1.1\r\nHost: a\r\n\r\n",
+		"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 3\r\n\r\nabc",
+		"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\n3;ext=1\r\nabc\r\n0\r\nX-Trailer: t\r\n\r\n",
+		"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\nG",
+		"POST / HTTP/1.1\r\nHost: a\r\nContent-Length: 9223372036854775807\r\n\r\nabc",
+		"POST / HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\n7fffffffffffffff\r\nabc",
+		"GGET / HTTP/1.1\r\n\r\n",
+		"GET / HTTP/1.1\n Folded: a\n\n",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		req, n, err := raw.ParseRequest(data)
+		if err != nil {
+			if req != nil || n != 0 {
+				t.Fatalf("解析失败时返回了请求或长度: %d", n)
+			}
+			return
+		}
+		if req == nil || n <= 0 || n > len(data) {
+			t.Fatalf("解析成功但长度无效: %d/%d", n, len(data))
+		}
+	})
+}
+
+// TestDecompressionLimit 测试压缩请求体解码后超过上限时返回413
+func TestDecompressionLimit(t *testing.T) {
+	var bomb bytes.Buffer
+	writer := gzip.NewWriter(&bomb)
+	_, _ = writer.Write(make([]byte, compression.DefaultDecodeLimit+1024*1024))
+	writer.Close()
+
+	router := setupTestRouter()
+	for _, path := range []string{"/api/parse/json", "/api/parse/binary"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewReader(bomb.Bytes()))
+		req.Header.Set("Content-Encoding", "gzip")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, path)
+		assert.Contains(t, w.Body.String(), `"truncated":true`, path)
+		assert.Contains(t, w.Body.String(), fmt.Sprintf(`"decompressed_size":%d`, compression.DefaultDecodeLimit), path)
+	}
+
+	// 未超过上限的压缩请求体正常解码
+	var small bytes.Buffer
+	writer = gzip.NewWriter(&small)
+	_, _ = writer.Write(make([]byte, 1024))
+	writer.Close()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/parse/binary", &small)
+	req.Header.Set("Content-Encoding", "gzip")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"truncated"`)
+}
+
+// TestCaptureOverflowRequest 测试被net/http拒绝的超大Content-Length请求在关闭连接时不会使服务器崩溃
+func TestCaptureOverflowRequest(t *testing.T) {
+	server := startCaptureServer()
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	assert.NoError(t, err)
+	fmt.Fprint(conn, "POST /api/test HTTP/1.1\r\nHost: test\r\nContent-Length: 1\r\nContent-Length: 9223372036854775807\r\n\r\nabcdefgh")
+	response, _ := io.ReadAll(conn)
+	conn.Close()
+	assert.Contains(t, string(response), "400 Bad Request")
+
+	// 服务器仍能正常响应
+	resp, err := http.Get(server.URL + "/api/test")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode)
+	}
+}
+
+// TestTLSConnectionReset 测试经HTTPS监听器接管的连接仍能以RST关闭
+func TestTLSConnectionReset(t *testing.T) {
+	ca, err := https.NewCA()
+	assert.NoError(t, err)
+	hosts, err := https.NewVirtualHosts(ca, "")
+	assert.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := https.NewServer(setupTestRouter(), hosts)
+	go server.ServeTLS(https.NewListener(listener), "", "")
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{RootCAs: pool, ServerName: "origin.test", NextProtos: []string{"http/1.1"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "GET /api/test?chaos_reset_rate=1 HTTP/1.1\r\nHost: origin.test\r\n\r\n")
+	_, err = io.ReadAll(conn)
+	assert.ErrorIs(t, err, syscall.ECONNRESET)
+}
+
 // BenchmarkAPITest API性能基准测试
 func BenchmarkAPITest(b *testing.B) {
 	router := setupTestRouter()
@@ -39,6 +39,20 @@ func (m *BasicAPIModule) RegisterRoutes(r *gin.Engine) {
 		api.GET("/delay/:seconds", handleDelay)
 		api.POST("/delay/:seconds", handleDelay)
 
+		// 状态码序列测试，第N次调用返回第N步
+		api.GET("/sequence/:name", handleSequence)
+		api.POST("/sequence/:name", handleSequence)
+		api.GET("/sequences", handleSequenceCounters)
+		api.DELETE("/sequences", handleSequenceReset)
+		api.DELETE("/sequences/:name", handleSequenceReset)
+
+		// 1xx临时响应测试
+		api.POST("/expect-continue", handleExpectContinue)
+		api.PUT("/expect-continue", handleExpectContinue)
+		api.GET("/early-hints", handleEarlyHints)
+		api.GET("/processing", handleProcessing)
+		api.POST("/processing", handleProcessing)
+
 		// 重定向测试
 		api.GET("/redirect/:times", handleRedirect)
 		api.GET("/redirect-to", handleRedirectTo)
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 100-continue的处理方式
+const (
+	continueAccept = "accept" // 发送100后读取消息体（默认）
+	continueReject = "reject" // 不读取消息体，直接返回417（或status指定的状态码）
+	continueIgnore = "ignore" // 不发送100也不读取消息体，直接返回200
+)
+
+const (
+	maxEarlyHints      = 10                     // 103的最大发送次数
+	minProcessingEvery = 100 * time.Millisecond // 102的最小发送间隔
+)
+
+// defaultEarlyHints 未指定link参数时在103中提示的资源
+var defaultEarlyHints = []string{
+	"</static/css/style.css>; rel=preload; as=style",
+	"</static/js/app.js>; rel=preload; as=script",
+}
+
+// interimError 无法发送1xx时的响应
+func interimError(c *gin.Context, err error) {
+	if errors.Is(err, routes.ErrInterimNotSupported) {
+		response := routes.CreateErrorResponse(501, err.Error())
+		c.JSON(http.StatusNotImplemented, response)
+		return
+	}
+	response := routes.CreateErrorResponse(500, "发送临时响应失败: "+err.Error())
+	c.JSON(http.StatusInternalServerError, response)
+}
+
+// Expect: 100-continue 测试，mode=accept|reject|ignore，delay为发送100前的等待时间
+func handleExpectContinue(c *gin.Context) {
+	mode := c.DefaultQuery("mode", continueAccept)
+	expect := strings.EqualFold(c.GetHeader("Expect"), "100-continue")
+	info := map[string]interface{}{
+		"mode":            mode,
+		"expect_continue": expect,
+		"content_length":  c.Request.ContentLength,
+	}
+
+	switch mode {
+	case continueReject:
+		status := http.StatusExpectationFailed
+		if value := c.Query("status"); value != "" {
+			code, err := strconv.Atoi(value)
+			if err != nil || code < 400 || code > 599 {
+				response := routes.CreateErrorResponse(400, "status必须是4xx或5xx: "+value)
+				c.JSON(http.StatusBadRequest, response)
+				return
+			}
+			status = code
+		}
+		// 消息体未读取，连接不能继续复用
+		c.Header("Connection", "close")
+		response := routes.CreateErrorResponse(status, "拒绝接收消息体: "+http.StatusText(status))
+		response.Data = info
+		c.JSON(status, response)
+		return
+
+	case continueIgnore:
+		c.Header("Connection", "close")
+		response := routes.CreateSuccessResponse("未发送100 Continue，也未读取消息体", info)
+		c.JSON(http.StatusOK, response)
+		return
+
+	case continueAccept:
+	default:
+		response := routes.CreateErrorResponse(400, "mode支持accept、reject、ignore")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	delay, err := parseStepDelay(c.DefaultQuery("delay", "0"))
+	if err != nil {
+		response := routes.CreateErrorResponse(400, err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	if delay > 0 && !sleepContext(c, delay) {
+		return
+	}
+	// 显式发送100，不依赖net/http在首次读取消息体时的自动发送
+	if expect {
+		if err := routes.WriteInterim(c.Writer, http.StatusContinue); err != nil {
+			interimError(c, err)
+			return
+		}
+	}
+
+	size, err := io.Copy(io.Discard, c.Request.Body)
+	if err != nil {
+		response := routes.CreateErrorResponse(400, "读取请求体失败: "+err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	info["delay"] = delay.String()
+	info["continue_sent"] = expect
+	info["body_size"] = size
+	response := routes.CreateSuccessResponse("已接收消息体", info)
+	c.JSON(http.StatusOK, response)
+}
+
+// 103 Early Hints 测试，link可重复指定，count为103的次数，delay为最后一个103到最终响应的间隔
+func handleEarlyHints(c *gin.Context) {
+	links := c.QueryArray("link")
+	if len(links) == 0 {
+		links = defaultEarlyHints
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", "1"))
+	if err != nil || count < 1 || count > maxEarlyHints {
+		response := routes.CreateErrorResponse(400, fmt.Sprintf("count必须在1到%d之间", maxEarlyHints))
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	delay, err := parseStepDelay(c.DefaultQuery("delay", "0"))
+	if err != nil {
+		response := routes.CreateErrorResponse(400, err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	for _, link := range links {
+		c.Writer.Header().Add("Link", link)
+	}
+	for i := 0; i < count; i++ {
+		if err := routes.WriteInterim(c.Writer, http.StatusEarlyHints); err != nil {
+			interimError(c, err)
+			return
+		}
+	}
+	if delay > 0 && !sleepContext(c, delay) {
+		return
+	}
+
+	response := routes.CreateSuccessResponse("已发送103 Early Hints", map[string]interface{}{
+		"hints": count,
+		"links": links,
+		"delay": delay.String(),
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// 102 Processing 测试，在seconds秒的处理过程中每隔interval发送一次102
+func handleProcessing(c *gin.Context) {
+	duration, err := parseStepDelay(c.DefaultQuery("seconds", "10"))
+	if err != nil {
+		response := routes.CreateErrorResponse(400, err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	interval, err := parseStepDelay(c.DefaultQuery("interval", "1"))
+	if err != nil || interval < minProcessingEvery {
+		response := routes.CreateErrorResponse(400, fmt.Sprintf("interval不能小于%v", minProcessingEvery))
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	started := time.Now()
+	sent := 0
+	for {
+		remaining := duration - time.Since(started)
+		if remaining <= 0 {
+			break
+		}
+		if err := routes.WriteInterim(c.Writer, http.StatusProcessing); err != nil {
+			interimError(c, err)
+			return
+		}
+		sent++
+		if !sleepContext(c, min(interval, remaining)) {
+			return
+		}
+	}
+
+	response := routes.CreateSuccessResponse("处理完成", map[string]interface{}{
+		"seconds":    duration.Seconds(),
+		"interval":   interval.String(),
+		"processing": sent,
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// sleepContext 等待指定时间，客户端断开时返回false
+func sleepContext(c *gin.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
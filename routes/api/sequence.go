@@ -0,0 +1,281 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 序列的计数维度
+const (
+	keyIP     = "ip"      // 按客户端IP分别计数（默认）
+	keyGlobal = "global"  // 所有客户端共享计数
+	keyHeader = "header:" // 按指定请求头的值分别计数，如 header:X-Test-Id
+)
+
+const (
+	maxSteps       = 100              // 单个序列的最大步数（展开重复后）
+	maxStepDelay   = 30 * time.Second // 与延迟测试相同的上限
+	maxCounters    = 10000            // 计数器数量上限，超出时全部清空
+	timeoutDefault = 30 * time.Second // timeout步骤默认等待时间，与超时测试相同
+)
+
+// step 序列中的一步
+type step struct {
+	Status  int           `json:"status"`
+	Body    string        `json:"body,omitempty"`
+	Delay   time.Duration `json:"-"`
+	Timeout bool          `json:"timeout,omitempty"`
+	Text    string        `json:"step"` // 原始写法
+}
+
+// counter 某个序列在某个计数维度下的调用次数
+type counter struct {
+	Sequence string    `json:"sequence"`
+	Key      string    `json:"key"`
+	Value    string    `json:"value,omitempty"`
+	Calls    int64     `json:"calls"`
+	LastCall time.Time `json:"last_call"`
+}
+
+var (
+	counterLock sync.Mutex
+	counters    = make(map[string]*counter)
+)
+
+// parseSteps 解析序列，步骤以逗号分隔：
+// STATUS[:BODY][@DELAY][*N]，如 503、200:alpha、200@2s、503*3；
+// timeout[@DELAY][*N] 等待DELAY（默认30秒）后才响应，用于触发代理超时
+func parseSteps(value string) ([]step, error) {
+	steps := make([]step, 0)
+	for _, text := range strings.Split(value, ",") {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		repeat := 1
+		if base, count, ok := strings.Cut(text, "*"); ok {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("无效的重复次数: %s", text)
+			}
+			text, repeat = base, n
+		}
+
+		s := step{Text: text}
+		spec, delay, hasDelay := strings.Cut(text, "@")
+		if hasDelay {
+			d, err := parseStepDelay(delay)
+			if err != nil {
+				return nil, err
+			}
+			s.Delay = d
+		}
+		if spec == "timeout" {
+			s.Timeout = true
+			s.Status = http.StatusOK
+			if !hasDelay {
+				s.Delay = timeoutDefault
+			}
+		} else {
+			code, body, _ := strings.Cut(spec, ":")
+			status, err := strconv.Atoi(code)
+			if err != nil || status < 100 || status > 999 {
+				return nil, fmt.Errorf("无效的状态码: %s", text)
+			}
+			s.Status = status
+			s.Body = body
+		}
+
+		if len(steps)+repeat > maxSteps {
+			return nil, fmt.Errorf("序列最多%d步", maxSteps)
+		}
+		for i := 0; i < repeat; i++ {
+			steps = append(steps, s)
+		}
+	}
+	if len(steps) == 0 {
+		return nil, errors.New("缺少steps参数，如 steps=503,503,200")
+	}
+	return steps, nil
+}
+
+// parseStepDelay 解析步骤延迟，纯数字按秒处理，与延迟测试一致
+func parseStepDelay(value string) (time.Duration, error) {
+	var d time.Duration
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		d = time.Duration(seconds) * time.Second
+	} else {
+		d, err = time.ParseDuration(value)
+	}
+	if err != nil || d < 0 || d > maxStepDelay {
+		return 0, fmt.Errorf("无效的延迟: %s（0到%v）", value, maxStepDelay)
+	}
+	return d, nil
+}
+
+// sequenceKey 按key参数计算计数维度和取值
+func sequenceKey(c *gin.Context) (string, string, error) {
+	key := c.DefaultQuery("key", keyIP)
+	switch {
+	case key == keyIP:
+		return key, c.ClientIP(), nil
+	case key == keyGlobal:
+		return key, "", nil
+	case strings.HasPrefix(key, keyHeader) && len(key) > len(keyHeader):
+		return key, c.GetHeader(key[len(keyHeader):]), nil
+	default:
+		return "", "", fmt.Errorf("无效的key: %s（支持ip、global、header:名称）", key)
+	}
+}
+
+// nextCall 计数加一并返回本次是第几次调用
+func nextCall(name, key, value string) int64 {
+	counterLock.Lock()
+	defer counterLock.Unlock()
+
+	id := name + "\x00" + key + "\x00" + value
+	entry, ok := counters[id]
+	if !ok {
+		if len(counters) >= maxCounters {
+			counters = make(map[string]*counter)
+		}
+		entry = &counter{Sequence: name, Key: key, Value: value}
+		counters[id] = entry
+	}
+	entry.Calls++
+	entry.LastCall = time.Now()
+	return entry.Calls
+}
+
+// pickStep 第call次调用对应的步骤序号（从0开始），loop为false时停在最后一步
+func pickStep(call int64, count int, loop bool) int {
+	index := call - 1
+	if loop {
+		return int(index % int64(count))
+	}
+	if index >= int64(count) {
+		return count - 1
+	}
+	return int(index)
+}
+
+// 按调用次数返回预设的状态码序列
+func handleSequence(c *gin.Context) {
+	steps, err := parseSteps(c.Query("steps"))
+	if err != nil {
+		response := routes.CreateErrorResponse(400, err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	key, value, err := sequenceKey(c)
+	if err != nil {
+		response := routes.CreateErrorResponse(400, err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	loop := c.Query("loop") == "true" || c.Query("loop") == "1"
+
+	name := c.Param("name")
+	call := nextCall(name, key, value)
+	index := pickStep(call, len(steps), loop)
+	s := steps[index]
+
+	c.Header("X-Sequence-Call", strconv.FormatInt(call, 10))
+	c.Header("X-Sequence-Step", fmt.Sprintf("%d/%d", index+1, len(steps)))
+
+	if s.Delay > 0 {
+		timer := time.NewTimer(s.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+
+	if s.Body != "" {
+		c.String(s.Status, s.Body)
+		return
+	}
+	c.JSON(s.Status, routes.ApiResponse{
+		Code:    s.Status,
+		Message: http.StatusText(s.Status),
+		Data: map[string]interface{}{
+			"sequence": name,
+			"key":      key,
+			"value":    value,
+			"call":     call,
+			"step":     index + 1,
+			"steps":    steps,
+			"loop":     loop,
+			"request":  getRequestInfo(c),
+		},
+		Timestamp: time.Now().Unix(),
+		RequestID: routes.GenerateRequestID(),
+	})
+}
+
+// 序列计数器列表
+func handleSequenceCounters(c *gin.Context) {
+	counterLock.Lock()
+	list := make([]counter, 0, len(counters))
+	for _, entry := range counters {
+		list = append(list, *entry)
+	}
+	counterLock.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Sequence != list[j].Sequence {
+			return list[i].Sequence < list[j].Sequence
+		}
+		if list[i].Key != list[j].Key {
+			return list[i].Key < list[j].Key
+		}
+		return list[i].Value < list[j].Value
+	})
+
+	response := routes.CreateSuccessResponse("序列计数器", map[string]interface{}{
+		"counters": list,
+		"count":    len(list),
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// 重置序列计数器，指定name时只重置该序列，可再用key和value缩小范围
+func handleSequenceReset(c *gin.Context) {
+	name := c.Param("name")
+	key := c.Query("key")
+	value, hasValue := c.GetQuery("value")
+
+	counterLock.Lock()
+	reset := 0
+	for id, entry := range counters {
+		if name != "" && entry.Sequence != name {
+			continue
+		}
+		if key != "" && entry.Key != key {
+			continue
+		}
+		if hasValue && entry.Value != value {
+			continue
+		}
+		delete(counters, id)
+		reset++
+	}
+	counterLock.Unlock()
+
+	response := routes.CreateSuccessResponse("序列计数器已重置", map[string]interface{}{"reset": reset})
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 默认凭据，可通过AuthModule字段或查询参数覆盖
+const (
+	DefaultUsername = "admin"
+	DefaultPassword = "password"
+	DefaultToken    = "test-token"
+	DefaultRealm    = "http-proxy-test"
+)
+
+// AuthModule 认证测试模块
+type AuthModule struct {
+	Username string
+	Password string
+	Token    string
+}
+
+// authTarget 区分源站认证（401）和代理认证（407）使用的状态码与头部
+type authTarget struct {
+	status            int
+	challengeHeader   string
+	credentialsHeader string
+	infoHeader        string
+}
+
+var (
+	originTarget = authTarget{http.StatusUnauthorized, "WWW-Authenticate", "Authorization", "Authentication-Info"}
+	proxyTarget  = authTarget{http.StatusProxyAuthRequired, "Proxy-Authenticate", "Proxy-Authorization", "Proxy-Authentication-Info"}
+)
+
+// credentials 本次请求期望的凭据
+type credentials struct {
+	username string
+	password string
+	token    string
+	realm    string
+}
+
+// RegisterRoutes 注册路由
+func (m *AuthModule) RegisterRoutes(r *gin.Engine) {
+	api := r.Group("/api/auth")
+	{
+		// 源站认证（401 + WWW-Authenticate）
+		api.Any("/basic", m.handler(originTarget, handleBasic))
+		api.Any("/bearer", m.handler(originTarget, handleBearer))
+		api.Any("/digest", m.handler(originTarget, handleDigest))
+
+		// 代理认证（407 + Proxy-Authenticate）
+		api.Any("/proxy/basic", m.handler(proxyTarget, handleBasic))
+		api.Any("/proxy/bearer", m.handler(proxyTarget, handleBearer))
+		api.Any("/proxy/digest", m.handler(proxyTarget, handleDigest))
+
+		// 查看到达服务器的认证头
+		api.Any("/headers", handleAuthHeaders)
+	}
+}
+
+// GetPrefix 获取前缀
+func (m *AuthModule) GetPrefix() string {
+	return "/api/auth"
+}
+
+// GetDescription 获取描述
+func (m *AuthModule) GetDescription() string {
+	return "Basic、Bearer、Digest认证和407代理认证测试接口"
+}
+
+// handler 解析期望凭据：查询参数 user、pass、token、realm 优先，其次为模块配置和默认值
+func (m *AuthModule) handler(target authTarget, handle func(*gin.Context, authTarget, credentials)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := credentials{
+			username: firstNonEmpty(c.Query("user"), m.Username, DefaultUsername),
+			password: firstNonEmpty(c.Query("pass"), m.Password, DefaultPassword),
+			token:    firstNonEmpty(c.Query("token"), m.Token, DefaultToken),
+			realm:    firstNonEmpty(c.Query("realm"), DefaultRealm),
+		}
+		handle(c, target, expected)
+	}
+}
+
+// Basic认证测试
+func handleBasic(c *gin.Context, target authTarget, expected credentials) {
+	challenge := fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, expected.realm)
+
+	scheme, value := splitAuthorization(c.GetHeader(target.credentialsHeader))
+	if !strings.EqualFold(scheme, "Basic") {
+		reject(c, target, "缺少Basic认证信息", challenge)
+		return
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		reject(c, target, "Basic凭据不是合法的Base64: "+err.Error(), challenge)
+		return
+	}
+	username, password, found := strings.Cut(string(decoded), ":")
+	if !found {
+		reject(c, target, "Basic凭据缺少冒号分隔符", challenge)
+		return
+	}
+	if !secureEqual(username, expected.username) || !secureEqual(password, expected.password) {
+		reject(c, target, "用户名或密码错误", challenge)
+		return
+	}
+
+	accept(c, target, "Basic", map[string]interface{}{"user": username})
+}
+
+// Bearer认证测试（RFC 6750）
+func handleBearer(c *gin.Context, target authTarget, expected credentials) {
+	challenge := fmt.Sprintf("Bearer realm=%q", expected.realm)
+
+	scheme, token := splitAuthorization(c.GetHeader(target.credentialsHeader))
+	if !strings.EqualFold(scheme, "Bearer") {
+		// 未携带凭据时不返回error参数（RFC 6750 3.1）
+		reject(c, target, "缺少Bearer令牌", challenge)
+		return
+	}
+	if token == "" || strings.ContainsAny(token, " \t") {
+		c.Header(target.challengeHeader, challenge+`, error="invalid_request", error_description="malformed bearer token"`)
+		response := routes.CreateErrorResponse(http.StatusBadRequest, "Bearer令牌格式错误")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	if !secureEqual(token, expected.token) {
+		reject(c, target, "Bearer令牌无效", challenge+`, error="invalid_token", error_description="the access token is invalid"`)
+		return
+	}
+
+	accept(c, target, "Bearer", map[string]interface{}{"token": token})
+}
+
+// 显示请求中到达服务器的认证头，用于检查代理是透传还是消费了认证信息
+func handleAuthHeaders(c *gin.Context) {
+	describe := func(name string) map[string]interface{} {
+		value := c.GetHeader(name)
+		scheme, _ := splitAuthorization(value)
+		return map[string]interface{}{
+			"present": value != "",
+			"scheme":  scheme,
+			"value":   value,
+		}
+	}
+
+	response := routes.CreateSuccessResponse("认证头信息", map[string]interface{}{
+		"authorization":       describe("Authorization"),
+		"proxy_authorization": describe("Proxy-Authorization"),
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// reject 返回401/407和一个或多个质询
+func reject(c *gin.Context, target authTarget, message string, challenges ...string) {
+	for _, challenge := range challenges {
+		c.Writer.Header().Add(target.challengeHeader, challenge)
+	}
+	response := routes.CreateErrorResponse(target.status, message)
+	c.JSON(target.status, response)
+}
+
+// accept 认证成功
+func accept(c *gin.Context, target authTarget, scheme string, details map[string]interface{}) {
+	details["authenticated"] = true
+	details["scheme"] = scheme
+	details["credentials_header"] = target.credentialsHeader
+	response := routes.CreateSuccessResponse(scheme+"认证成功", details)
+	c.JSON(http.StatusOK, response)
+}
+
+// splitAuthorization 拆分认证方案和参数
+func splitAuthorization(header string) (string, string) {
+	scheme, value, _ := strings.Cut(strings.TrimSpace(header), " ")
+	return scheme, strings.TrimSpace(value)
+}
+
+// secureEqual 常量时间比较，避免测试服务泄露时序信息
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,331 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 默认nonce有效期和nc记录的保留时间
+const (
+	defaultNonceExpiry = 300 * time.Second
+	nonceRetention     = time.Hour
+)
+
+var (
+	// nonceSecret 签名nonce的密钥，进程重启后旧nonce全部失效
+	nonceSecret = randomBytes(32)
+	// digestOpaque 服务端不透明值，客户端需原样返回
+	digestOpaque = hex.EncodeToString(randomBytes(16))
+
+	// nonceCounts 记录每个nonce已使用的最大nc，用于检测重放
+	nonceCounts = make(map[string]nonceUsage)
+	nonceLock   sync.Mutex
+)
+
+// nonceUsage nonce的使用情况
+type nonceUsage struct {
+	count    uint64
+	issuedAt time.Time
+}
+
+// digestAlgorithms 支持的算法，顺序即质询的发送顺序（RFC 7616建议优先SHA-256）
+var digestAlgorithms = []string{"SHA-256", "SHA-256-sess", "MD5", "MD5-sess"}
+
+// digestOptions 本次请求的Digest配置
+type digestOptions struct {
+	algorithms []string
+	qops       []string
+	expiry     time.Duration
+}
+
+// parseDigestOptions 从查询参数解析配置
+// algorithm=MD5,SHA-256（默认SHA-256和MD5）；qop=auth,auth-int（默认两者）；expires=nonce有效秒数
+func parseDigestOptions(c *gin.Context) digestOptions {
+	options := digestOptions{expiry: defaultNonceExpiry}
+
+	for _, name := range strings.Split(c.Query("algorithm"), ",") {
+		for _, supported := range digestAlgorithms {
+			if strings.EqualFold(strings.TrimSpace(name), supported) {
+				options.algorithms = append(options.algorithms, supported)
+			}
+		}
+	}
+	if len(options.algorithms) == 0 {
+		options.algorithms = []string{"SHA-256", "MD5"}
+	}
+
+	for _, qop := range strings.Split(c.Query("qop"), ",") {
+		qop = strings.ToLower(strings.TrimSpace(qop))
+		if qop == "auth" || qop == "auth-int" {
+			options.qops = append(options.qops, qop)
+		}
+	}
+	if len(options.qops) == 0 {
+		options.qops = []string{"auth", "auth-int"}
+	}
+
+	if seconds, err := strconv.Atoi(c.Query("expires")); err == nil && seconds > 0 && seconds <= 86400 {
+		options.expiry = time.Duration(seconds) * time.Second
+	}
+	return options
+}
+
+// Digest认证测试（RFC 7616）
+func handleDigest(c *gin.Context, target authTarget, expected credentials) {
+	options := parseDigestOptions(c)
+
+	scheme, value := splitAuthorization(c.GetHeader(target.credentialsHeader))
+	if !strings.EqualFold(scheme, "Digest") {
+		reject(c, target, "缺少Digest认证信息", digestChallenges(expected.realm, options, false)...)
+		return
+	}
+
+	params := parseDigestParams(value)
+	failure := func(message string) {
+		reject(c, target, message, digestChallenges(expected.realm, options, false)...)
+	}
+
+	algorithm := params["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+	if !containsFold(options.algorithms, algorithm) {
+		failure("不支持的算法: " + algorithm)
+		return
+	}
+	if params["realm"] != expected.realm {
+		failure("realm不匹配")
+		return
+	}
+	if params["opaque"] != digestOpaque {
+		failure("opaque不匹配")
+		return
+	}
+	if requestURI := c.Request.URL.RequestURI(); params["uri"] != requestURI {
+		failure(fmt.Sprintf("uri不匹配: 请求为%s，认证参数为%s", requestURI, params["uri"]))
+		return
+	}
+
+	qop := params["qop"]
+	if !containsFold(options.qops, qop) {
+		failure("不支持的qop: " + qop)
+		return
+	}
+	nc, err := strconv.ParseUint(params["nc"], 16, 64)
+	if err != nil || params["cnonce"] == "" {
+		failure("缺少nc或cnonce")
+		return
+	}
+
+	issuedAt, ok := verifyNonce(params["nonce"], expected.realm)
+	if !ok {
+		failure("nonce无效")
+		return
+	}
+
+	newHash := digestHash(algorithm)
+	var bodyHash string
+	if qop == "auth-int" {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			failure("读取请求体失败: " + err.Error())
+			return
+		}
+		bodyHash = hashHex(newHash, string(body))
+	}
+
+	ha1 := digestHA1(newHash, algorithm, params["username"], expected.realm, expected.password, params["nonce"], params["cnonce"])
+	ha2 := digestHA2(newHash, qop, c.Request.Method, params["uri"], bodyHash)
+	response := hashHex(newHash, strings.Join([]string{ha1, params["nonce"], params["nc"], params["cnonce"], qop, ha2}, ":"))
+
+	if !secureEqual(params["username"], expected.username) || !secureEqual(params["response"], response) {
+		failure("用户名或密码错误")
+		return
+	}
+
+	// 摘要正确但nonce过期时返回stale=true，客户端应使用新nonce重试而无需重新输入密码
+	if time.Since(issuedAt) > options.expiry {
+		reject(c, target, "nonce已过期", digestChallenges(expected.realm, options, true)...)
+		return
+	}
+	if !recordNonceCount(params["nonce"], nc, issuedAt) {
+		failure(fmt.Sprintf("nc重放: %s", params["nc"]))
+		return
+	}
+
+	// 响应认证（rspauth）的A2不含请求方法
+	rspauth := hashHex(newHash, strings.Join([]string{ha1, params["nonce"], params["nc"], params["cnonce"], qop, digestHA2(newHash, qop, "", params["uri"], bodyHash)}, ":"))
+	c.Header(target.infoHeader, fmt.Sprintf(`rspauth="%s", qop=%s, nc=%s, cnonce="%s", nextnonce="%s"`,
+		rspauth, qop, params["nc"], params["cnonce"], newNonce(expected.realm)))
+
+	accept(c, target, "Digest", map[string]interface{}{
+		"user":      params["username"],
+		"algorithm": algorithm,
+		"qop":       qop,
+		"nc":        nc,
+		"nonce_age": time.Since(issuedAt).Seconds(),
+	})
+}
+
+// digestChallenges 为每个算法生成一个质询
+func digestChallenges(realm string, options digestOptions, stale bool) []string {
+	nonce := newNonce(realm)
+	challenges := make([]string, 0, len(options.algorithms))
+	for _, algorithm := range options.algorithms {
+		challenge := fmt.Sprintf(`Digest realm="%s", qop="%s", algorithm=%s, nonce="%s", opaque="%s", charset=UTF-8`,
+			realm, strings.Join(options.qops, ", "), algorithm, nonce, digestOpaque)
+		if stale {
+			challenge += ", stale=true"
+		}
+		challenges = append(challenges, challenge)
+	}
+	return challenges
+}
+
+// digestHash 按算法选择哈希函数
+func digestHash(algorithm string) func() hash.Hash {
+	if strings.HasPrefix(strings.ToUpper(algorithm), "SHA-256") {
+		return sha256.New
+	}
+	return md5.New
+}
+
+// digestHA1 计算A1的哈希，-sess算法额外混入nonce和cnonce
+func digestHA1(newHash func() hash.Hash, algorithm, username, realm, password, nonce, cnonce string) string {
+	ha1 := hashHex(newHash, username+":"+realm+":"+password)
+	if strings.HasSuffix(strings.ToLower(algorithm), "-sess") {
+		ha1 = hashHex(newHash, ha1+":"+nonce+":"+cnonce)
+	}
+	return ha1
+}
+
+// digestHA2 计算A2的哈希，auth-int包含请求体的哈希
+func digestHA2(newHash func() hash.Hash, qop, method, uri, bodyHash string) string {
+	if qop == "auth-int" {
+		return hashHex(newHash, method+":"+uri+":"+bodyHash)
+	}
+	return hashHex(newHash, method+":"+uri)
+}
+
+// hashHex 计算十六进制小写哈希
+func hashHex(newHash func() hash.Hash, data string) string {
+	h := newHash()
+	h.Write([]byte(data))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newNonce 生成带时间戳和签名的nonce，无需在服务端保存即可校验
+func newNonce(realm string) string {
+	timestamp := strconv.FormatInt(time.Now().UnixNano(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(timestamp + ":" + signNonce(timestamp, realm)))
+}
+
+// verifyNonce 校验nonce签名并返回签发时间
+func verifyNonce(nonce, realm string) (time.Time, bool) {
+	decoded, err := base64.RawURLEncoding.DecodeString(nonce)
+	if err != nil {
+		return time.Time{}, false
+	}
+	timestamp, signature, found := strings.Cut(string(decoded), ":")
+	if !found || !hmac.Equal([]byte(signature), []byte(signNonce(timestamp, realm))) {
+		return time.Time{}, false
+	}
+	nanos, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos), true
+}
+
+// signNonce 计算nonce签名
+func signNonce(timestamp, realm string) string {
+	mac := hmac.New(sha256.New, nonceSecret)
+	mac.Write([]byte(timestamp + ":" + realm))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// recordNonceCount 记录nc，nc必须严格递增，否则视为重放
+func recordNonceCount(nonce string, nc uint64, issuedAt time.Time) bool {
+	nonceLock.Lock()
+	defer nonceLock.Unlock()
+
+	for key, usage := range nonceCounts {
+		if time.Since(usage.issuedAt) > nonceRetention {
+			delete(nonceCounts, key)
+		}
+	}
+
+	usage := nonceCounts[nonce]
+	if nc <= usage.count {
+		return false
+	}
+	nonceCounts[nonce] = nonceUsage{count: nc, issuedAt: issuedAt}
+	return true
+}
+
+// parseDigestParams 解析 key=value 或 key="quoted value" 形式的参数列表
+func parseDigestParams(value string) map[string]string {
+	params := make(map[string]string)
+	for len(value) > 0 {
+		value = strings.TrimLeft(value, " \t,")
+		eq := strings.IndexByte(value, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(value[:eq]))
+		value = strings.TrimLeft(value[eq+1:], " \t")
+
+		var parsed strings.Builder
+		if strings.HasPrefix(value, `"`) {
+			i := 1
+			for ; i < len(value) && value[i] != '"'; i++ {
+				if value[i] == '\\' && i+1 < len(value) {
+					i++
+				}
+				parsed.WriteByte(value[i])
+			}
+			value = value[min(i+1, len(value)):]
+		} else {
+			end := strings.IndexByte(value, ',')
+			if end < 0 {
+				end = len(value)
+			}
+			parsed.WriteString(strings.TrimSpace(value[:end]))
+			value = value[end:]
+		}
+		params[key] = parsed.String()
+	}
+	return params
+}
+
+// containsFold 不区分大小写的包含判断
+func containsFold(values []string, target string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// randomBytes 生成随机字节
+func randomBytes(n int) []byte {
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -0,0 +1,392 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultCacheControl 未指定cache_control时使用的指令
+const defaultCacheControl = "public, max-age=60"
+
+// CacheModule HTTP缓存测试模块
+type CacheModule struct{}
+
+// resourceState 资源的当前版本
+type resourceState struct {
+	version  int
+	modified time.Time
+}
+
+// HitStats 到达源站的请求统计
+type HitStats struct {
+	Total              int64            `json:"total"`
+	OK                 int64            `json:"ok"`
+	NotModified        int64            `json:"not_modified"`
+	PreconditionFailed int64            `json:"precondition_failed"`
+	Variants           map[string]int64 `json:"variants"`
+	LastHit            time.Time        `json:"last_hit"`
+}
+
+var (
+	resources = make(map[string]*resourceState)
+	hits      = make(map[string]*HitStats)
+	cacheLock sync.Mutex
+)
+
+// cachePresets 常用Cache-Control组合
+var cachePresets = map[string]string{
+	"no-store":               "no-store",
+	"no-cache":               "no-cache",
+	"private":                "private, max-age=60",
+	"public":                 "public, max-age=60",
+	"max-age-0":              "max-age=0",
+	"s-maxage":               "public, max-age=0, s-maxage=60",
+	"must-revalidate":        "max-age=5, must-revalidate",
+	"proxy-revalidate":       "public, max-age=5, proxy-revalidate",
+	"stale-while-revalidate": "max-age=1, stale-while-revalidate=30",
+	"stale-if-error":         "max-age=1, stale-if-error=60",
+	"immutable":              "public, max-age=31536000, immutable",
+	"no-transform":           "public, max-age=60, no-transform",
+}
+
+// RegisterRoutes 注册路由
+func (m *CacheModule) RegisterRoutes(r *gin.Engine) {
+	api := r.Group("/api/cache")
+	{
+		// 可缓存资源
+		api.GET("/resource/:id", handleResource)
+		api.HEAD("/resource/:id", handleResource)
+		api.PUT("/resource/:id", handlePut)
+		api.POST("/resource/:id/bump", handleBump)
+
+		// 预设Cache-Control
+		api.GET("/presets", handlePresets)
+		api.GET("/preset/:name", handlePreset)
+		api.HEAD("/preset/:name", handlePreset)
+
+		// 源站命中统计
+		api.GET("/stats", handleStats)
+		api.POST("/reset", handleReset)
+	}
+}
+
+// GetPrefix 获取前缀
+func (m *CacheModule) GetPrefix() string {
+	return "/api/cache"
+}
+
+// GetDescription 获取描述
+func (m *CacheModule) GetDescription() string {
+	return "ETag、Last-Modified、条件请求、Cache-Control和Vary缓存测试接口"
+}
+
+// 可缓存资源
+// etag=strong|weak|none，last_modified=false 不发送Last-Modified，cache_control=指令（空值表示不发送），
+// vary=请求头列表（响应内容随这些请求头变化），size=填充字节数
+func handleResource(c *gin.Context) {
+	cacheControl, exists := c.GetQuery("cache_control")
+	if !exists {
+		cacheControl = defaultCacheControl
+	}
+	serveResource(c, c.Param("id"), cacheControl)
+}
+
+// 预设Cache-Control的资源
+func handlePreset(c *gin.Context) {
+	name := c.Param("name")
+	cacheControl, ok := cachePresets[name]
+	if !ok {
+		response := routes.CreateErrorResponse(404, "未知的预设: "+name)
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+	serveResource(c, "preset-"+name, cacheControl)
+}
+
+// representation 资源某个版本在当前请求下的响应内容和校验器
+type representation struct {
+	body         []byte
+	etag         string
+	lastModified time.Time
+	varyNames    []string
+	variantKey   string
+}
+
+// buildRepresentation 按资源版本和请求参数生成响应内容、ETag和Last-Modified
+func buildRepresentation(c *gin.Context, id string, state resourceState) representation {
+	// Vary中列出的请求头会影响响应内容
+	varyNames := make([]string, 0)
+	variant := make(map[string]string)
+	for _, name := range strings.Split(c.Query("vary"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			name = textproto.CanonicalMIMEHeaderKey(name)
+			varyNames = append(varyNames, name)
+			variant[name] = c.GetHeader(name)
+		}
+	}
+	variantKey := variantKey(varyNames, variant)
+
+	size, _ := strconv.Atoi(c.Query("size"))
+	if size < 0 || size > 1024*1024 {
+		size = 0
+	}
+
+	// 内容只取决于资源版本和变体，保证同一版本的响应字节相同
+	body, _ := json.Marshal(struct {
+		ID           string            `json:"id"`
+		Version      int               `json:"version"`
+		LastModified string            `json:"last_modified"`
+		Variant      map[string]string `json:"variant,omitempty"`
+		Padding      string            `json:"padding,omitempty"`
+	}{id, state.version, state.modified.Format(http.TimeFormat), variant, strings.Repeat("x", size)})
+
+	sum := sha256.Sum256(body)
+	etag := ""
+	switch c.DefaultQuery("etag", "strong") {
+	case "strong":
+		etag = `"` + hex.EncodeToString(sum[:8]) + `"`
+	case "weak":
+		// 弱标签只随版本和变体变化，填充大小等细节不影响语义等价
+		variantSum := sha256.Sum256([]byte(variantKey))
+		etag = fmt.Sprintf(`W/"%s-v%d-%s"`, id, state.version, hex.EncodeToString(variantSum[:4]))
+	}
+
+	lastModified := state.modified
+	if c.Query("last_modified") == "false" {
+		lastModified = time.Time{}
+	}
+	return representation{body: body, etag: etag, lastModified: lastModified, varyNames: varyNames, variantKey: variantKey}
+}
+
+// setValidators 设置ETag、Last-Modified和版本号响应头
+func setValidators(c *gin.Context, rep representation, version int) {
+	header := c.Writer.Header()
+	if rep.etag != "" {
+		header.Set("ETag", rep.etag)
+	}
+	if !rep.lastModified.IsZero() {
+		header.Set("Last-Modified", rep.lastModified.UTC().Format(http.TimeFormat))
+	}
+	header.Set("X-Resource-Version", strconv.Itoa(version))
+}
+
+// serveResource 输出资源并处理条件请求
+func serveResource(c *gin.Context, id, cacheControl string) {
+	state := currentState(id)
+	rep := buildRepresentation(c, id, state)
+
+	// 304也必须携带这些头，缓存据此更新存储的响应
+	header := c.Writer.Header()
+	if cacheControl != "" {
+		header.Set("Cache-Control", cacheControl)
+	}
+	if len(rep.varyNames) > 0 {
+		header.Set("Vary", strings.Join(rep.varyNames, ", "))
+	}
+	setValidators(c, rep, state.version)
+
+	status, condition := evaluatePreconditions(c.Request, rep.etag, rep.lastModified)
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header.Set("X-Origin-Hits", strconv.FormatInt(recordHit(id, rep.variantKey, status), 10))
+	if condition != "" {
+		header.Set("X-Matched-Condition", condition)
+	}
+
+	switch status {
+	case http.StatusNotModified:
+		c.Status(http.StatusNotModified)
+	case http.StatusPreconditionFailed:
+		response := routes.CreateErrorResponse(412, condition+"条件不满足")
+		c.JSON(http.StatusPreconditionFailed, response)
+	default:
+		c.Data(http.StatusOK, "application/json; charset=utf-8", rep.body)
+	}
+}
+
+// 更新资源，If-Match、If-Unmodified-Since和If-None-Match不满足时返回412，否则版本加一
+// 校验器按与GET相同的查询参数计算，返回新版本的ETag和Last-Modified
+func handlePut(c *gin.Context) {
+	id := c.Param("id")
+
+	// 条件判断和更新在同一把锁内完成，并发的PUT中只有一个能匹配旧ETag
+	cacheLock.Lock()
+	state := stateLocked(id)
+	old := buildRepresentation(c, id, *state)
+	status, condition := evaluatePreconditions(c.Request, old.etag, old.lastModified)
+	if status == 0 {
+		bumpLocked(state)
+	}
+	updated := *state
+	cacheLock.Unlock()
+
+	if status != 0 {
+		c.Header("X-Origin-Hits", strconv.FormatInt(recordHit(id, old.variantKey, status), 10))
+		c.Header("X-Matched-Condition", condition)
+		setValidators(c, old, updated.version)
+		response := routes.CreateErrorResponse(412, condition+"条件不满足")
+		c.JSON(http.StatusPreconditionFailed, response)
+		return
+	}
+
+	rep := buildRepresentation(c, id, updated)
+	c.Header("X-Origin-Hits", strconv.FormatInt(recordHit(id, rep.variantKey, http.StatusOK), 10))
+	setValidators(c, rep, updated.version)
+	response := routes.CreateSuccessResponse("资源已更新", map[string]interface{}{
+		"id":            id,
+		"version":       updated.version,
+		"etag":          rep.etag,
+		"last_modified": updated.modified.Format(http.TimeFormat),
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// 更新资源版本，ETag和Last-Modified随之变化
+func handleBump(c *gin.Context) {
+	id := c.Param("id")
+
+	cacheLock.Lock()
+	state := stateLocked(id)
+	bumpLocked(state)
+	version, modified := state.version, state.modified
+	cacheLock.Unlock()
+
+	response := routes.CreateSuccessResponse("资源已更新", map[string]interface{}{
+		"id":            id,
+		"version":       version,
+		"last_modified": modified.Format(http.TimeFormat),
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// 预设列表
+func handlePresets(c *gin.Context) {
+	names := make([]string, 0, len(cachePresets))
+	for name := range cachePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	presets := make([]map[string]string, 0, len(names))
+	for _, name := range names {
+		presets = append(presets, map[string]string{
+			"name":          name,
+			"cache_control": cachePresets[name],
+			"url":           "/api/cache/preset/" + name,
+		})
+	}
+
+	response := routes.CreateSuccessResponse("Cache-Control预设", map[string]interface{}{
+		"presets": presets,
+		"default": defaultCacheControl,
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// 源站命中统计，可用?id=查看单个资源
+func handleStats(c *gin.Context) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	if id := c.Query("id"); id != "" {
+		stats, ok := hits[id]
+		if !ok {
+			stats = &HitStats{Variants: map[string]int64{}}
+		}
+		response := routes.CreateSuccessResponse("源站命中统计", map[string]interface{}{"id": id, "hits": stats})
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := routes.CreateSuccessResponse("源站命中统计", map[string]interface{}{"resources": hits})
+	c.JSON(http.StatusOK, response)
+}
+
+// 重置资源版本和命中统计
+func handleReset(c *gin.Context) {
+	cacheLock.Lock()
+	resources = make(map[string]*resourceState)
+	hits = make(map[string]*HitStats)
+	cacheLock.Unlock()
+
+	response := routes.CreateSuccessResponse("缓存测试状态已重置", nil)
+	c.JSON(http.StatusOK, response)
+}
+
+// currentState 获取资源状态，首次访问时创建
+func currentState(id string) resourceState {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	return *stateLocked(id)
+}
+
+// stateLocked 获取资源状态，首次访问时创建，调用方需持有cacheLock
+func stateLocked(id string) *resourceState {
+	state, ok := resources[id]
+	if !ok {
+		state = &resourceState{version: 1, modified: time.Now().UTC().Truncate(time.Second)}
+		resources[id] = state
+	}
+	return state
+}
+
+// bumpLocked 版本加一并更新修改时间，调用方需持有cacheLock
+func bumpLocked(state *resourceState) {
+	state.version++
+	// Last-Modified精度为秒，保证新版本的时间晚于旧版本
+	modified := time.Now().UTC().Truncate(time.Second)
+	if !modified.After(state.modified) {
+		modified = state.modified.Add(time.Second)
+	}
+	state.modified = modified
+}
+
+// recordHit 记录到达源站的请求，返回该资源的累计命中数
+func recordHit(id, variant string, status int) int64 {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	stats, ok := hits[id]
+	if !ok {
+		stats = &HitStats{Variants: make(map[string]int64)}
+		hits[id] = stats
+	}
+	stats.Total++
+	stats.Variants[variant]++
+	stats.LastHit = time.Now()
+	switch status {
+	case http.StatusOK:
+		stats.OK++
+	case http.StatusNotModified:
+		stats.NotModified++
+	case http.StatusPreconditionFailed:
+		stats.PreconditionFailed++
+	}
+	return stats.Total
+}
+
+// variantKey 生成变体标识，如 Accept-Encoding=gzip&User-Agent=curl
+func variantKey(names []string, values map[string]string) string {
+	if len(names) == 0 {
+		return "default"
+	}
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+"="+values[name])
+	}
+	return strings.Join(parts, "&")
+}
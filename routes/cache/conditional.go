@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// entityTag 解析后的实体标签
+type entityTag struct {
+	weak  bool
+	value string // 含引号
+}
+
+// parseETag 解析单个实体标签，如 "abc" 或 W/"abc"
+func parseETag(tag string) (entityTag, bool) {
+	tag = strings.TrimSpace(tag)
+	weak := false
+	if strings.HasPrefix(tag, "W/") {
+		weak = true
+		tag = tag[2:]
+	}
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return entityTag{}, false
+	}
+	return entityTag{weak: weak, value: tag}, true
+}
+
+// matchETags 判断If-Match/If-None-Match列表是否命中当前ETag
+// strong为true时使用强比较（两者都不能是弱标签），否则使用弱比较
+func matchETags(header, current string, strong bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return current != ""
+	}
+	currentTag, ok := parseETag(current)
+	if !ok {
+		return false
+	}
+	for _, item := range strings.Split(header, ",") {
+		tag, ok := parseETag(item)
+		if !ok || tag.value != currentTag.value {
+			continue
+		}
+		if !strong || (!tag.weak && !currentTag.weak) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluatePreconditions 按RFC 9110 13.2.2的顺序评估条件请求
+// 返回应答状态码（0表示继续正常处理）和命中的条件
+func evaluatePreconditions(r *http.Request, etag string, lastModified time.Time) (int, string) {
+	// 1. If-Match（强比较）
+	if header := r.Header.Get("If-Match"); header != "" {
+		if !matchETags(header, etag, true) {
+			return http.StatusPreconditionFailed, "If-Match"
+		}
+	} else if header := r.Header.Get("If-Unmodified-Since"); header != "" && !lastModified.IsZero() {
+		// 2. If-Unmodified-Since，仅在没有If-Match时评估
+		if since, err := http.ParseTime(header); err == nil && lastModified.After(since) {
+			return http.StatusPreconditionFailed, "If-Unmodified-Since"
+		}
+	}
+
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+	notModified := http.StatusNotModified
+	if !safe {
+		notModified = http.StatusPreconditionFailed
+	}
+
+	// 3. If-None-Match（弱比较）
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		if matchETags(header, etag, false) {
+			return notModified, "If-None-Match"
+		}
+		return 0, ""
+	}
+
+	// 4. If-Modified-Since，仅对GET/HEAD且没有If-None-Match时评估
+	if header := r.Header.Get("If-Modified-Since"); header != "" && safe && !lastModified.IsZero() {
+		if since, err := http.ParseTime(header); err == nil && !lastModified.After(since) {
+			return http.StatusNotModified, "If-Modified-Since"
+		}
+	}
+	return 0, ""
+}
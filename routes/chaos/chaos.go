@@ -0,0 +1,111 @@
+package chaos
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChaosModule 故障注入配置模块，注入本身由Middleware完成
+type ChaosModule struct{}
+
+// RegisterRoutes 注册路由
+func (m *ChaosModule) RegisterRoutes(r *gin.Engine) {
+	api := r.Group(chaosPrefix)
+	{
+		api.GET("", handleGet)
+		api.PUT("", handlePut)
+		api.DELETE("", handleDelete)
+	}
+}
+
+// GetPrefix 获取前缀
+func (m *ChaosModule) GetPrefix() string {
+	return chaosPrefix
+}
+
+// GetDescription 获取描述
+func (m *ChaosModule) GetDescription() string {
+	return "对任意接口注入延迟、错误状态码、断开、截断和停顿的故障注入配置"
+}
+
+// 当前全局配置和可用参数
+func handleGet(c *gin.Context) {
+	global := Global()
+	params := make([]map[string]string, 0, len(optionNames))
+	for _, name := range optionNames {
+		param := map[string]string{"name": name}
+		if name != "paths" {
+			param["header"] = headerName(name)
+			param["query"] = "chaos_" + name
+		}
+		params = append(params, param)
+	}
+
+	response := routes.CreateSuccessResponse("故障注入配置", map[string]interface{}{
+		"global":     global.Describe(),
+		"parameters": params,
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// 替换全局配置，请求体为参数名到取值的JSON对象，未给出的参数恢复默认值
+func handlePut(c *gin.Context) {
+	var body map[string]interface{}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response := routes.CreateErrorResponse(400, "请求体必须是JSON对象: "+err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	names := make([]string, 0, len(body))
+	for name := range body {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	options := NewOptions()
+	for _, name := range names {
+		if err := options.Set(name, formatValue(body[name])); err != nil {
+			response := routes.CreateErrorResponse(400, err.Error())
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+	}
+	SetGlobal(options)
+
+	response := routes.CreateSuccessResponse("故障注入配置已更新", options.Describe())
+	c.JSON(http.StatusOK, response)
+}
+
+// 关闭全局故障注入
+func handleDelete(c *gin.Context) {
+	options := NewOptions()
+	SetGlobal(options)
+	response := routes.CreateSuccessResponse("故障注入已关闭", options.Describe())
+	c.JSON(http.StatusOK, response)
+}
+
+// formatValue 将JSON取值转换为参数字符串，数组以逗号连接
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, formatValue(item))
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
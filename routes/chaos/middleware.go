@@ -0,0 +1,255 @@
+package chaos
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chaosPrefix 配置接口的路径前缀，始终不注入故障，保证随时可以关闭
+const chaosPrefix = "/api/chaos"
+
+// errInjected 故障注入断开连接后继续写入时返回，使流式处理器尽快结束
+var errInjected = errors.New("故障注入已断开连接")
+
+var (
+	globalLock sync.RWMutex
+	global     = NewOptions()
+)
+
+// SetGlobal 设置对所有请求生效的故障注入参数
+func SetGlobal(options Options) {
+	globalLock.Lock()
+	defer globalLock.Unlock()
+	global = options
+}
+
+// Global 当前的全局参数
+func Global() Options {
+	globalLock.RLock()
+	defer globalLock.RUnlock()
+	return global
+}
+
+// headerName 参数对应的请求头，如 error_rate -> X-Chaos-Error-Rate
+func headerName(name string) string {
+	parts := strings.Split(name, "_")
+	for i, part := range parts {
+		parts[i] = strings.ToUpper(part[:1]) + part[1:]
+	}
+	return "X-Chaos-" + strings.Join(parts, "-")
+}
+
+// requestOptions 在全局参数上叠加请求头和查询参数，X-Chaos: off 或 chaos=off 时本次请求不注入
+func requestOptions(c *gin.Context) (Options, error) {
+	if strings.EqualFold(c.GetHeader("X-Chaos"), "off") || c.Query("chaos") == "off" {
+		return NewOptions(), nil
+	}
+	options := Global()
+	if !options.Covers(c.Request.URL.Path) {
+		options = NewOptions()
+	}
+	for _, name := range optionNames {
+		if name == "paths" {
+			continue
+		}
+		value := c.GetHeader(headerName(name))
+		if value == "" {
+			value = c.Query("chaos_" + name)
+		}
+		if value == "" {
+			continue
+		}
+		if err := options.Set(name, value); err != nil {
+			return options, err
+		}
+	}
+	return options, nil
+}
+
+// roll 按概率决定是否触发
+func roll(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// sleep 等待指定时间，客户端断开时返回false
+func sleep(c *gin.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
+
+// Middleware 故障注入中间件，参数来自全局配置（-chaos、/api/chaos）以及
+// X-Chaos-* 请求头或 chaos_* 查询参数，任意接口都可以作为代理容错测试的上游
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, chaosPrefix) || c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+		options, err := requestOptions(c)
+		if err != nil {
+			response := routes.CreateErrorResponse(400, "故障注入参数错误: "+err.Error())
+			c.AbortWithStatusJSON(http.StatusBadRequest, response)
+			return
+		}
+		if !options.Active() {
+			c.Next()
+			return
+		}
+
+		applied := make([]string, 0)
+		if d := options.delay(); d > 0 {
+			applied = append(applied, "latency="+d.Round(time.Millisecond).String())
+			if !sleep(c, d) {
+				c.Abort()
+				return
+			}
+		}
+
+		// 发送响应头之前断开
+		if roll(options.DropRate) {
+			c.Abort()
+			if err := routes.CloseConnection(c.Writer, false); err != nil {
+				// 无法接管连接时（HTTP/2、测试环境）退回502，便于发现故障未能按预期模拟
+				response := routes.CreateErrorResponse(502, "无法接管连接模拟故障: "+err.Error())
+				c.JSON(http.StatusBadGateway, response)
+			}
+			return
+		}
+
+		if roll(options.ErrorRate) {
+			status := options.errorStatus()
+			applied = append(applied, "error="+strconv.Itoa(status))
+			c.Header("X-Chaos-Applied", strings.Join(applied, ", "))
+			response := routes.CreateErrorResponse(status, "故障注入: "+http.StatusText(status))
+			c.AbortWithStatusJSON(status, response)
+			return
+		}
+
+		writer := &chaosWriter{ResponseWriter: c.Writer, ctx: c}
+		switch {
+		case roll(options.ResetRate):
+			writer.cut, writer.cutAfter = "reset", options.ResetAfter
+		case roll(options.TruncateRate):
+			writer.cut, writer.cutAfter = "truncate", options.TruncateAfter
+		}
+		if writer.cut != "" {
+			applied = append(applied, writer.cut)
+		}
+		if roll(options.StallRate) && options.Stall > 0 {
+			writer.stall, writer.stallAfter = options.Stall, options.StallAfter
+			applied = append(applied, "stall="+options.Stall.String())
+		}
+
+		if len(applied) > 0 {
+			c.Header("X-Chaos-Applied", strings.Join(applied, ", "))
+		}
+		if writer.cut != "" || writer.stall > 0 {
+			c.Writer = writer
+		}
+		c.Next()
+	}
+}
+
+// chaosWriter 在消息体的指定字节位置停顿或断开连接
+type chaosWriter struct {
+	gin.ResponseWriter
+	ctx *gin.Context
+
+	cut      string // reset或truncate
+	cutAfter int64
+
+	stall      time.Duration
+	stallAfter int64
+
+	written  int64
+	started  bool
+	finished bool
+}
+
+// resolve 首次写入时确定auto位置：有Content-Length时取一半，否则取首次写入数据的一半
+func (w *chaosWriter) resolve(first int) {
+	if w.started {
+		return
+	}
+	w.started = true
+	half := int64(first / 2)
+	if length, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64); err == nil {
+		half = length / 2
+	}
+	if w.cutAfter == autoSize {
+		w.cutAfter = half
+	}
+	if w.stallAfter == autoSize {
+		w.stallAfter = half
+	}
+}
+
+// Write 写到下一个故障位置时先刷新已写入的数据，再停顿或断开
+func (w *chaosWriter) Write(data []byte) (int, error) {
+	if w.finished {
+		return 0, errInjected
+	}
+	w.resolve(len(data))
+
+	total := 0
+	for {
+		limit := int64(len(data))
+		if w.stall > 0 && w.stallAfter-w.written < limit {
+			limit = max(w.stallAfter-w.written, 0)
+		}
+		if w.cut != "" && w.cutAfter-w.written < limit {
+			limit = max(w.cutAfter-w.written, 0)
+		}
+		n, err := w.ResponseWriter.Write(data[:limit])
+		total += n
+		w.written += int64(n)
+		data = data[n:]
+		if err != nil {
+			return total, err
+		}
+
+		if w.stall > 0 && w.written >= w.stallAfter {
+			w.ResponseWriter.Flush()
+			stall := w.stall
+			w.stall = 0
+			if !sleep(w.ctx, stall) {
+				return total, w.ctx.Request.Context().Err()
+			}
+		}
+		if w.cut != "" && w.written >= w.cutAfter {
+			w.ResponseWriter.Flush()
+			w.finished = true
+			// 无法接管连接时只能停止写入
+			_ = routes.CloseConnection(w.ResponseWriter, w.cut == "reset")
+			return total, errInjected
+		}
+		if len(data) == 0 {
+			return total, nil
+		}
+	}
+}
+
+// WriteString 写入字符串
+func (w *chaosWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Unwrap 返回被包装的ResponseWriter
+func (w *chaosWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
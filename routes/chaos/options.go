@@ -0,0 +1,254 @@
+package chaos
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 抖动分布
+const (
+	DistUniform     = "uniform"     // latency ± jitter 均匀分布
+	DistNormal      = "normal"      // 以latency为均值、jitter为标准差的正态分布
+	DistExponential = "exponential" // latency + 均值为jitter的指数分布，长尾
+)
+
+const (
+	maxDelay = 60 * time.Second // 延迟和停顿的上限
+	autoSize = -1               // 未指定字节位置时取消息体的一半
+)
+
+// optionNames 支持的参数，请求头形式为 X-Chaos-Error-Rate，查询参数形式为 chaos_error_rate
+var optionNames = []string{
+	"latency", "jitter", "jitter_dist",
+	"error_rate", "error_status",
+	"drop_rate",
+	"reset_rate", "reset_after",
+	"truncate_rate", "truncate_after",
+	"stall_rate", "stall", "stall_after",
+	"paths",
+}
+
+// Options 故障注入参数，各故障按各自的概率独立触发
+type Options struct {
+	Latency    time.Duration // 固定附加延迟
+	Jitter     time.Duration // 抖动幅度
+	JitterDist string        // 抖动分布，默认uniform
+
+	ErrorRate   float64 // 不调用处理器，直接返回错误状态码的概率
+	ErrorStatus []int   // 错误状态码，多个时随机选择，默认500
+
+	DropRate float64 // 发送响应头之前关闭连接的概率
+
+	ResetRate  float64 // 发送ResetAfter字节后以RST断开的概率
+	ResetAfter int64
+
+	TruncateRate  float64 // 发送TruncateAfter字节后正常关闭连接的概率
+	TruncateAfter int64
+
+	StallRate  float64       // 发送StallAfter字节后停顿Stall的概率
+	Stall      time.Duration // 默认10秒
+	StallAfter int64
+
+	Paths []string // 仅全局配置使用：只对这些路径前缀生效，为空时对所有路径生效
+}
+
+// NewOptions 返回默认参数
+func NewOptions() Options {
+	return Options{
+		JitterDist:    DistUniform,
+		ErrorStatus:   []int{500},
+		ResetAfter:    autoSize,
+		TruncateAfter: autoSize,
+		Stall:         10 * time.Second,
+		StallAfter:    autoSize,
+	}
+}
+
+// Set 按名称设置参数
+func (o *Options) Set(name, value string) error {
+	value = strings.TrimSpace(value)
+	var err error
+	switch name {
+	case "latency":
+		o.Latency, err = parseDuration(value)
+	case "jitter":
+		o.Jitter, err = parseDuration(value)
+	case "jitter_dist":
+		switch value {
+		case DistUniform, DistNormal, DistExponential:
+			o.JitterDist = value
+		default:
+			err = fmt.Errorf("支持uniform、normal、exponential")
+		}
+	case "error_rate":
+		o.ErrorRate, err = parseRate(value)
+	case "error_status":
+		o.ErrorStatus, err = parseStatuses(value)
+	case "drop_rate":
+		o.DropRate, err = parseRate(value)
+	case "reset_rate":
+		o.ResetRate, err = parseRate(value)
+	case "reset_after":
+		o.ResetAfter, err = parseSize(value)
+	case "truncate_rate":
+		o.TruncateRate, err = parseRate(value)
+	case "truncate_after":
+		o.TruncateAfter, err = parseSize(value)
+	case "stall_rate":
+		o.StallRate, err = parseRate(value)
+	case "stall":
+		o.Stall, err = parseDuration(value)
+	case "stall_after":
+		o.StallAfter, err = parseSize(value)
+	case "paths":
+		o.Paths = nil
+		for _, path := range strings.Split(value, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				o.Paths = append(o.Paths, path)
+			}
+		}
+	default:
+		return fmt.Errorf("未知的参数: %s，支持: %s", name, strings.Join(optionNames, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+// Parse 解析 latency=100ms&error_rate=0.1 形式的参数，用于-chaos启动参数
+func Parse(spec string) (Options, error) {
+	options := NewOptions()
+	values, err := url.ParseQuery(spec)
+	if err != nil {
+		return options, fmt.Errorf("故障注入参数格式错误: %v", err)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := options.Set(name, values.Get(name)); err != nil {
+			return options, err
+		}
+	}
+	return options, nil
+}
+
+// Active 是否会注入任何故障
+func (o *Options) Active() bool {
+	return o.Latency > 0 || o.Jitter > 0 || o.ErrorRate > 0 || o.DropRate > 0 ||
+		o.ResetRate > 0 || o.TruncateRate > 0 || o.StallRate > 0
+}
+
+// Covers 路径是否在生效范围内
+func (o *Options) Covers(path string) bool {
+	if len(o.Paths) == 0 {
+		return true
+	}
+	for _, prefix := range o.Paths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// delay 按分布抽取本次附加的延迟
+func (o *Options) delay() time.Duration {
+	d := float64(o.Latency)
+	jitter := float64(o.Jitter)
+	if jitter > 0 {
+		switch o.JitterDist {
+		case DistNormal:
+			d += rand.NormFloat64() * jitter
+		case DistExponential:
+			d += rand.ExpFloat64() * jitter
+		default:
+			d += (rand.Float64()*2 - 1) * jitter
+		}
+	}
+	return time.Duration(math.Max(0, math.Min(d, float64(maxDelay))))
+}
+
+// errorStatus 随机选择一个错误状态码
+func (o *Options) errorStatus() int {
+	return o.ErrorStatus[rand.Intn(len(o.ErrorStatus))]
+}
+
+// Describe 以参数名输出当前配置
+func (o *Options) Describe() map[string]interface{} {
+	return map[string]interface{}{
+		"latency":        o.Latency.String(),
+		"jitter":         o.Jitter.String(),
+		"jitter_dist":    o.JitterDist,
+		"error_rate":     o.ErrorRate,
+		"error_status":   o.ErrorStatus,
+		"drop_rate":      o.DropRate,
+		"reset_rate":     o.ResetRate,
+		"reset_after":    o.ResetAfter,
+		"truncate_rate":  o.TruncateRate,
+		"truncate_after": o.TruncateAfter,
+		"stall_rate":     o.StallRate,
+		"stall":          o.Stall.String(),
+		"stall_after":    o.StallAfter,
+		"paths":          o.Paths,
+		"active":         o.Active(),
+	}
+}
+
+// parseDuration 解析时长，纯数字按毫秒处理
+func parseDuration(value string) (time.Duration, error) {
+	var d time.Duration
+	ms, err := strconv.ParseFloat(value, 64)
+	if err == nil {
+		d = time.Duration(ms * float64(time.Millisecond))
+	} else {
+		d, err = time.ParseDuration(value)
+	}
+	if err != nil || d < 0 || d > maxDelay {
+		return 0, fmt.Errorf("无效的时长: %s（0到%v，纯数字按毫秒）", value, maxDelay)
+	}
+	return d, nil
+}
+
+// parseRate 解析0到1之间的概率
+func parseRate(value string) (float64, error) {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("概率必须在0到1之间: %s", value)
+	}
+	return rate, nil
+}
+
+// parseStatuses 解析逗号分隔的状态码
+func parseStatuses(value string) ([]int, error) {
+	statuses := make([]int, 0)
+	for _, item := range strings.Split(value, ",") {
+		status, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || status < 100 || status > 999 {
+			return nil, fmt.Errorf("无效的状态码: %s", item)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// parseSize 解析字节位置，auto表示消息体的一半
+func parseSize(value string) (int64, error) {
+	if value == "auto" {
+		return autoSize, nil
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("无效的字节数: %s", value)
+	}
+	return size, nil
+}
@@ -0,0 +1,122 @@
+package compression
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 支持的编码名称
+// deflate按RFC 9110使用zlib封装；deflate-raw为不带zlib头的原始deflate流，
+// 部分旧服务器（如早期IIS）会这样发送，线上的Content-Encoding同样为deflate
+const (
+	Gzip       = "gzip"
+	Deflate    = "deflate"
+	DeflateRaw = "deflate-raw"
+	Brotli     = "br"
+	Zstd       = "zstd"
+	Identity   = "identity"
+)
+
+// DefaultLevel 使用各算法的默认压缩级别
+const DefaultLevel = -1
+
+// Encodings 所有支持的编码，顺序即协商时q值相同的优先级
+var Encodings = []string{Brotli, Zstd, Gzip, Deflate, DeflateRaw, Identity}
+
+// encoder 流式压缩器
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// nopEncoder identity编码
+type nopEncoder struct {
+	io.Writer
+}
+
+func (nopEncoder) Close() error { return nil }
+func (nopEncoder) Flush() error { return nil }
+
+// IsSupported 判断编码是否受支持
+func IsSupported(encoding string) bool {
+	for _, name := range Encodings {
+		if name == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+// HeaderValue 编码对应的Content-Encoding值
+func HeaderValue(encoding string) string {
+	if encoding == DeflateRaw {
+		return Deflate
+	}
+	return encoding
+}
+
+// LevelRange 编码支持的压缩级别范围
+func LevelRange(encoding string) (int, int) {
+	switch encoding {
+	case Gzip, Deflate, DeflateRaw:
+		return flate.HuffmanOnly, flate.BestCompression
+	case Brotli:
+		return brotli.BestSpeed, brotli.BestCompression
+	case Zstd:
+		return 1, 22
+	}
+	return 0, 0
+}
+
+// newEncoder 创建流式压缩器，level超出范围时使用默认级别
+func newEncoder(encoding string, w io.Writer, level int) (encoder, error) {
+	if low, high := LevelRange(encoding); level < low || level > high {
+		level = DefaultLevel
+	}
+
+	switch encoding {
+	case Gzip:
+		return gzip.NewWriterLevel(w, level)
+	case Deflate:
+		return zlib.NewWriterLevel(w, level)
+	case DeflateRaw:
+		return flate.NewWriter(w, level)
+	case Brotli:
+		if level == DefaultLevel {
+			level = brotli.DefaultCompression
+		}
+		return brotli.NewWriterLevel(w, level), nil
+	case Zstd:
+		zstdLevel := zstd.SpeedDefault
+		if level != DefaultLevel {
+			zstdLevel = zstd.EncoderLevelFromZstd(level)
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstdLevel))
+	case Identity:
+		return nopEncoder{w}, nil
+	}
+	return nil, fmt.Errorf("不支持的编码: %s", encoding)
+}
+
+// Encode 一次性压缩数据
+func Encode(encoding string, data []byte, level int) ([]byte, error) {
+	var buffer bytes.Buffer
+	enc, err := newEncoder(encoding, &buffer, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := enc.Write(data); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,161 @@
+package compression
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+	"http_proxy_tool_test_web_demo/routes/integrity"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompressionModule 响应压缩测试模块
+type CompressionModule struct{}
+
+// RegisterRoutes 注册路由
+func (m *CompressionModule) RegisterRoutes(r *gin.Engine) {
+	api := r.Group("/api/compression")
+	{
+		api.GET("/encodings", handleEncodings)
+		api.GET("/negotiate", handleNegotiate)
+		api.GET("/:encoding", handleCompressed)
+	}
+}
+
+// GetPrefix 获取前缀
+func (m *CompressionModule) GetPrefix() string {
+	return "/api/compression"
+}
+
+// GetDescription 获取描述
+func (m *CompressionModule) GetDescription() string {
+	return "gzip/deflate/brotli/zstd响应压缩和Accept-Encoding协商测试接口"
+}
+
+// Respond 压缩完整响应体后一次写出，附带压缩前后的大小
+// 已设置Content-Encoding的响应不会被压缩中间件再次处理
+func Respond(c *gin.Context, status int, contentType string, body []byte, encoding string, level int) {
+	compressed, err := Encode(encoding, body, level)
+	if err != nil {
+		response := routes.CreateErrorResponse(500, "压缩失败: "+err.Error())
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	c.Header("Vary", "Accept-Encoding")
+	if encoding != Identity {
+		c.Header("Content-Encoding", HeaderValue(encoding))
+	}
+	c.Header("X-Compression", encoding)
+	c.Header("X-Uncompressed-Size", strconv.Itoa(len(body)))
+	c.Header("X-Compressed-Size", strconv.Itoa(len(compressed)))
+	c.Header("Content-Length", strconv.Itoa(len(compressed)))
+	// 摘要按实际发送的（压缩后的）内容计算
+	integrity.SetHeaders(c, compressed)
+	c.Data(status, contentType, compressed)
+}
+
+// RespondJSON 以指定编码返回JSON
+func RespondJSON(c *gin.Context, status int, obj interface{}, encoding string, level int) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		response := routes.CreateErrorResponse(500, "JSON序列化失败: "+err.Error())
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	Respond(c, status, "application/json; charset=utf-8", body, encoding, level)
+}
+
+// 支持的编码列表
+func handleEncodings(c *gin.Context) {
+	encodings := make([]map[string]interface{}, 0, len(Encodings))
+	for _, name := range Encodings {
+		low, high := LevelRange(name)
+		encodings = append(encodings, map[string]interface{}{
+			"name":             name,
+			"content_encoding": HeaderValue(name),
+			"negotiable":       name != DeflateRaw,
+			"min_level":        low,
+			"max_level":        high,
+			"endpoint":         "/api/compression/" + name,
+		})
+	}
+
+	response := routes.CreateSuccessResponse("支持的压缩编码", map[string]interface{}{
+		"encodings":   encodings,
+		"query_param": "任意接口附加 ?encoding=<名称>|auto&level=N 即可压缩响应",
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// Accept-Encoding协商测试，按协商结果压缩响应
+func handleNegotiate(c *gin.Context) {
+	header := c.GetHeader("Accept-Encoding")
+	encoding := Negotiate(header, NegotiableEncodings)
+	if encoding == "" {
+		c.Header("Vary", "Accept-Encoding")
+		response := routes.CreateErrorResponse(406, "没有可接受的内容编码，支持: "+strings.Join(NegotiableEncodings, ", "))
+		c.JSON(http.StatusNotAcceptable, response)
+		return
+	}
+
+	response := routes.CreateSuccessResponse("编码协商完成", map[string]interface{}{
+		"accept_encoding": header,
+		"preferences":     ParseAcceptEncoding(header),
+		"offered":         NegotiableEncodings,
+		"selected":        encoding,
+		"data":            strings.Repeat("压缩测试数据 ", 100),
+	})
+	RespondJSON(c, http.StatusOK, response, encoding, queryLevel(c))
+}
+
+// 指定编码压缩测试
+// size=字节数，random=true 生成不可压缩数据，level=压缩级别
+func handleCompressed(c *gin.Context) {
+	encoding := c.Param("encoding")
+	if !IsSupported(encoding) {
+		response := routes.CreateErrorResponse(404, "不支持的编码: "+encoding+"，支持: "+strings.Join(Encodings, ", "))
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	size, err := strconv.Atoi(c.Query("size"))
+	if err != nil || size < 1 || size > 10*1024*1024 {
+		size = 10 * 1024
+	}
+
+	if c.Query("random") == "true" {
+		data := make([]byte, size)
+		random := rand.New(rand.NewSource(time.Now().UnixNano()))
+		random.Read(data)
+		Respond(c, http.StatusOK, "application/octet-stream", data, encoding, queryLevel(c))
+		return
+	}
+
+	Respond(c, http.StatusOK, "text/plain; charset=utf-8", generateText(size), encoding, queryLevel(c))
+}
+
+// generateText 生成指定大小的可压缩文本
+func generateText(size int) []byte {
+	var builder strings.Builder
+	builder.Grow(size + 64)
+	for line := 1; builder.Len() < size; line++ {
+		fmt.Fprintf(&builder, "第%d行: The quick brown fox jumps over the lazy dog. 压缩测试数据\n", line)
+	}
+	return []byte(builder.String()[:size])
+}
+
+// queryLevel 读取level参数，未指定时使用默认级别
+func queryLevel(c *gin.Context) int {
+	level, err := strconv.Atoi(c.Query("level"))
+	if err != nil {
+		return DefaultLevel
+	}
+	return level
+}
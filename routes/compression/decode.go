@@ -0,0 +1,262 @@
+package compression
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// detectSize 识别压缩格式时预读的字节数，zlib需要试解压开头的数据
+const detectSize = 64
+
+// DefaultDecodeLimit 读入内存解析的请求体解码后的大小上限，防止压缩炸弹
+const DefaultDecodeLimit = 64 * 1024 * 1024
+
+// ErrDecodedTooLarge 解码后的数据超过上限
+var ErrDecodedTooLarge = errors.New("解码后的请求体超过大小上限")
+
+// BodyInfo 请求体解码结果
+type BodyInfo struct {
+	ContentEncoding  string   `json:"content_encoding"`
+	Codings          []string `json:"codings"`
+	DetectedEncoding string   `json:"detected_encoding"` // 按魔数识别的编码，brotli没有魔数无法识别
+	CompressedSize   int64    `json:"compressed_size"`
+	DecompressedSize int64    `json:"decompressed_size"`
+	Ratio            float64  `json:"compression_ratio"` // 解压后大小/压缩后大小
+	Decoded          bool     `json:"decoded"`
+	Truncated        bool     `json:"truncated,omitempty"` // 解码后超过上限，已停止解码
+	DecodeLimit      int64    `json:"decode_limit,omitempty"`
+	Error            string   `json:"error,omitempty"`
+	Mismatch         bool     `json:"encoding_mismatch"`
+	MismatchDetail   string   `json:"mismatch_detail,omitempty"`
+}
+
+// RequestBody 按Content-Encoding流式解码请求体，同时统计解码前后的字节数
+type RequestBody struct {
+	info       BodyInfo
+	compressed countingReader
+	decoded    countingReader
+	closers    []io.Closer
+	limit      int64
+	eof        bool
+}
+
+// countingReader 统计读取的字节数
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// errReader 解码器创建失败时在读取时返回错误
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+// DetectEncoding 按魔数识别压缩格式，无法识别时返回空字符串
+func DetectEncoding(data []byte) string {
+	switch {
+	case len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b:
+		return Gzip
+	case len(data) >= 4 && bytes.Equal(data[:4], []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return Zstd
+	case isZlibHeader(data) && inflates(data[2:]):
+		return Deflate
+	}
+	return ""
+}
+
+// isZlibHeader RFC 1950：CM=8，CINFO<=7，FDICT=0（无法解码使用预设字典的流），且(CMF*256+FLG)能被31整除
+// 只看头部会匹配不少普通文本（如"x "、"Hj"），识别格式时还需配合inflates
+func isZlibHeader(data []byte) bool {
+	if len(data) < 2 {
+		return false
+	}
+	return data[0]&0x0f == 8 && data[0]>>4 <= 7 && data[1]&0x20 == 0 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0
+}
+
+// inflates 试解压deflate数据的开头，数据被截断不算失败，格式错误或没有任何数据时返回false
+func inflates(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	reader := flate.NewReader(bytes.NewReader(data))
+	defer reader.Close()
+	_, err := io.Copy(io.Discard, io.LimitReader(reader, detectSize*16))
+	return err == nil || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// parseContentEncoding 解析Content-Encoding，按应用顺序返回，忽略identity
+func parseContentEncoding(header string) []string {
+	codings := make([]string, 0)
+	for _, coding := range strings.Split(header, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "x-gzip" {
+			coding = Gzip
+		}
+		if coding != "" && coding != Identity {
+			codings = append(codings, coding)
+		}
+	}
+	return codings
+}
+
+// newDecoder 创建解码器，deflate同时兼容zlib封装和原始deflate流
+func newDecoder(coding string, r io.Reader) (io.Reader, error) {
+	switch coding {
+	case Gzip:
+		return gzip.NewReader(r)
+	case Deflate:
+		buffered := bufio.NewReader(r)
+		header, _ := buffered.Peek(2)
+		if isZlibHeader(header) {
+			return zlib.NewReader(buffered)
+		}
+		return flate.NewReader(buffered), nil
+	case Brotli:
+		return brotli.NewReader(r), nil
+	case Zstd:
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("不支持的内容编码: %s", coding)
+}
+
+// DecodeRequest 包装请求体，读取时按Content-Encoding逆序解码
+// 有内容编码时解码后超过limit字节返回ErrDecodedTooLarge，未编码的请求体不受限制
+func DecodeRequest(r *http.Request, limit int64) *RequestBody {
+	header := r.Header.Get("Content-Encoding")
+	codings := parseContentEncoding(header)
+	body := &RequestBody{info: BodyInfo{ContentEncoding: header, Codings: codings}, limit: -1}
+	if len(codings) > 0 {
+		body.limit = limit
+		body.info.DecodeLimit = limit
+	}
+	body.compressed.reader = r.Body
+
+	buffered := bufio.NewReader(&body.compressed)
+	magic, _ := buffered.Peek(detectSize)
+	body.info.DetectedEncoding = DetectEncoding(magic)
+	if detail := checkMismatch(codings, body.info.DetectedEncoding); detail != "" {
+		body.info.Mismatch = true
+		body.info.MismatchDetail = detail
+	}
+
+	var reader io.Reader = buffered
+	for i := len(codings) - 1; i >= 0; i-- {
+		decoder, err := newDecoder(codings[i], reader)
+		if err != nil {
+			reader = errReader{err: fmt.Errorf("%s解码失败: %w", codings[i], err)}
+			break
+		}
+		if closer, ok := decoder.(io.Closer); ok {
+			body.closers = append(body.closers, closer)
+		}
+		reader = decoder
+	}
+	// 多读一个字节用于判断是否超过上限
+	if body.limit >= 0 {
+		reader = io.LimitReader(reader, body.limit+1)
+	}
+	body.decoded.reader = reader
+	return body
+}
+
+// checkMismatch 比较声明的最外层编码和数据的实际魔数
+func checkMismatch(codings []string, detected string) string {
+	describe := detected
+	if describe == "" {
+		describe = "未识别的格式"
+	}
+
+	outer := ""
+	if len(codings) > 0 {
+		outer = codings[len(codings)-1]
+	}
+	switch outer {
+	case "":
+		if detected != "" {
+			return "未声明Content-Encoding，但数据魔数为" + detected
+		}
+	case Gzip, Zstd:
+		if detected != outer {
+			return fmt.Sprintf("声明为%s，但数据为%s", outer, describe)
+		}
+	case Deflate:
+		// 没有zlib头时可能是原始deflate流，无法据此判断
+		if detected == Gzip || detected == Zstd {
+			return fmt.Sprintf("声明为deflate，但数据为%s", detected)
+		}
+	case Brotli:
+		// brotli没有魔数，只能排除其他格式
+		if detected != "" {
+			return fmt.Sprintf("声明为br，但数据为%s", detected)
+		}
+	}
+	return ""
+}
+
+// Read 读取解码后的数据
+func (b *RequestBody) Read(p []byte) (int, error) {
+	n, err := b.decoded.Read(p)
+	if b.limit >= 0 && b.decoded.count > b.limit {
+		n -= int(b.decoded.count - b.limit)
+		b.decoded.count = b.limit
+		b.info.Truncated = true
+		err = ErrDecodedTooLarge
+	}
+	if err == io.EOF {
+		b.eof = true
+	} else if err != nil && b.info.Error == "" {
+		b.info.Error = err.Error()
+	}
+	return n, err
+}
+
+// Close 释放解码器资源，不关闭原始请求体
+func (b *RequestBody) Close() error {
+	for _, closer := range b.closers {
+		_ = closer.Close()
+	}
+	return nil
+}
+
+// Info 返回当前的解码统计，应在读完请求体后调用
+func (b *RequestBody) Info() BodyInfo {
+	info := b.info
+	info.CompressedSize = b.compressed.count
+	info.DecompressedSize = b.decoded.count
+	info.Decoded = len(info.Codings) > 0 && b.eof && info.Error == ""
+	if info.CompressedSize > 0 {
+		info.Ratio = float64(info.DecompressedSize) / float64(info.CompressedSize)
+	}
+	return info
+}
+
+// ErrorStatus 读取解码后的请求体出错时的状态码，超过大小上限时为413
+func ErrorStatus(err error) int {
+	if errors.Is(err, ErrDecodedTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
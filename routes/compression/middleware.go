@@ -0,0 +1,126 @@
+package compression
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"http_proxy_tool_test_web_demo/routes"
+	"http_proxy_tool_test_web_demo/routes/integrity"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NegotiableEncodings 参与Accept-Encoding协商的编码（deflate-raw只能显式指定）
+var NegotiableEncodings = []string{Brotli, Zstd, Gzip, Deflate, Identity}
+
+// compressWriter 在首次写入时设置编码头并压缩后续数据
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	level    int
+	encoder  encoder
+	bypass   bool
+}
+
+// start 首次写入时决定是否压缩：已有Content-Encoding或状态码不允许消息体时直接透传
+func (w *compressWriter) start() {
+	if w.encoder != nil || w.bypass {
+		return
+	}
+	header := w.Header()
+	status := w.Status()
+	if header.Get("Content-Encoding") != "" || status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+		w.bypass = true
+		return
+	}
+
+	enc, err := newEncoder(w.encoding, w.ResponseWriter, w.level)
+	if err != nil {
+		log.Printf("创建压缩器失败: %v", err)
+		w.bypass = true
+		return
+	}
+	w.encoder = enc
+
+	header.Del("Content-Length")
+	header.Add("Vary", "Accept-Encoding")
+	if w.encoding != Identity {
+		header.Set("Content-Encoding", HeaderValue(w.encoding))
+		// 处理器按未压缩数据计算的摘要不再匹配消息内容
+		integrity.Remove(header)
+	}
+	header.Set("X-Compression", w.encoding)
+}
+
+// Write 压缩写入
+func (w *compressWriter) Write(data []byte) (int, error) {
+	w.start()
+	if w.bypass {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.encoder.Write(data)
+}
+
+// WriteString 压缩写入字符串
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush 先刷新压缩器再刷新连接，保证流式响应及时到达客户端
+func (w *compressWriter) Flush() {
+	if w.encoder != nil {
+		if err := w.encoder.Flush(); err != nil {
+			log.Printf("刷新压缩器失败: %v", err)
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Unwrap 返回被包装的ResponseWriter，供接管连接和发送1xx时找到底层连接
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close 写出压缩流的结尾
+func (w *compressWriter) close() {
+	if w.encoder == nil {
+		return
+	}
+	if err := w.encoder.Close(); err != nil {
+		log.Printf("关闭压缩器失败: %v", err)
+	}
+}
+
+// Middleware 可选的响应压缩中间件，任意接口附加 ?encoding= 即可启用
+// encoding=gzip|deflate|deflate-raw|br|zstd|identity 指定编码，encoding=auto 按Accept-Encoding协商
+// level=N 指定压缩级别
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := c.Query("encoding")
+		if encoding == "" || c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+
+		if encoding == "auto" {
+			encoding = Negotiate(c.GetHeader("Accept-Encoding"), NegotiableEncodings)
+			if encoding == "" {
+				c.Header("Vary", "Accept-Encoding")
+				response := routes.CreateErrorResponse(406, "没有可接受的内容编码，支持: "+strings.Join(NegotiableEncodings, ", "))
+				c.AbortWithStatusJSON(http.StatusNotAcceptable, response)
+				return
+			}
+		}
+		if !IsSupported(encoding) {
+			response := routes.CreateErrorResponse(400, "不支持的编码: "+encoding+"，支持: "+strings.Join(Encodings, ", "))
+			c.AbortWithStatusJSON(http.StatusBadRequest, response)
+			return
+		}
+
+		writer := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, level: queryLevel(c)}
+		c.Writer = writer
+		defer writer.close()
+		c.Next()
+	}
+}
@@ -0,0 +1,81 @@
+package compression
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Preference Accept-Encoding中的一项
+type Preference struct {
+	Coding string  `json:"coding"`
+	Q      float64 `json:"q"`
+}
+
+// ParseAcceptEncoding 解析Accept-Encoding，保留原始顺序；q值非法的项被忽略
+func ParseAcceptEncoding(header string) []Preference {
+	preferences := make([]Preference, 0)
+	for _, item := range strings.Split(header, ",") {
+		parts := strings.Split(item, ";")
+		coding := strings.ToLower(strings.TrimSpace(parts[0]))
+		if coding == "" {
+			continue
+		}
+		if coding == "x-gzip" {
+			coding = Gzip
+		}
+
+		q, valid := 1.0, true
+		for _, param := range parts[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(strings.TrimSpace(name), "q") {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				valid = false
+				break
+			}
+			q = parsed
+		}
+		if valid {
+			preferences = append(preferences, Preference{Coding: coding, Q: q})
+		}
+	}
+	return preferences
+}
+
+// Negotiate 按RFC 9110 12.5.3从候选编码中选择q值最高的一个，q值相同时按候选顺序
+// 未列出的编码取*的q值；identity未被显式或通过*排除时始终可接受
+// 没有可接受的编码时返回空字符串，此时应返回406
+func Negotiate(header string, offers []string) string {
+	preferences := ParseAcceptEncoding(header)
+	explicit := make(map[string]float64, len(preferences))
+	for _, preference := range preferences {
+		explicit[preference.Coding] = preference.Q
+	}
+	wildcard, hasWildcard := explicit["*"]
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		coding := HeaderValue(offer)
+		q, listed := explicit[coding]
+		switch {
+		case listed:
+		case hasWildcard:
+			q = wildcard
+		case coding == Identity:
+			q = 1
+		default:
+			q = 0
+		}
+
+		// identity仅作为兜底，只有在没有其他可接受编码时才选择
+		if coding == Identity && !listed && best != "" {
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
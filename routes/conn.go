@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrHijackNotSupported 底层ResponseWriter不支持接管连接（例如httptest.ResponseRecorder）
+var ErrHijackNotSupported = errors.New("当前连接不支持Hijack")
+
+// HijackConnection 接管底层TCP连接，用于需要直接控制线上字节的测试
+// gin的Hijack在底层不支持时会直接panic，这里提前检查并返回错误
+func HijackConnection(c *gin.Context) (net.Conn, *bufio.ReadWriter, error) {
+	return hijack(c.Writer)
+}
+
+// hijack 逐层解开ResponseWriter的包装，确认最内层支持Hijack后再接管
+func hijack(w gin.ResponseWriter) (net.Conn, *bufio.ReadWriter, error) {
+	var inner http.ResponseWriter = w
+	for {
+		unwrapper, ok := inner.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		inner = unwrapper.Unwrap()
+	}
+	if _, ok := inner.(http.Hijacker); !ok {
+		return nil, nil, ErrHijackNotSupported
+	}
+	return w.Hijack()
+}
+
+// ErrInterimNotSupported 底层ResponseWriter无法发送1xx临时响应
+var ErrInterimNotSupported = errors.New("当前连接不支持发送1xx临时响应")
+
+// WriteInterim 绕过gin的状态码缓存，直接在最内层ResponseWriter上发送1xx临时响应
+// 当前已设置的响应头随临时响应一起发出，并保留给最终响应（如103的Link头）
+func WriteInterim(w gin.ResponseWriter, code int) error {
+	if code < 100 || code > 199 || code == http.StatusSwitchingProtocols {
+		return errors.New("只能发送101以外的1xx状态码")
+	}
+	if w.Written() {
+		return errors.New("最终响应已经开始发送")
+	}
+	var inner http.ResponseWriter = w
+	for {
+		unwrapper, ok := inner.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		inner = unwrapper.Unwrap()
+	}
+	// net/http的HTTP/1.x（Hijacker）和HTTP/2（Pusher）实现支持1xx，httptest.ResponseRecorder会把它当作最终状态码
+	_, http1 := inner.(http.Hijacker)
+	_, http2 := inner.(http.Pusher)
+	if !http1 && !http2 {
+		return ErrInterimNotSupported
+	}
+	inner.WriteHeader(code)
+	return nil
+}
+
+// ResetConnection 接管连接并以RST关闭（SO_LINGER=0），模拟上游异常断开
+func ResetConnection(c *gin.Context) error {
+	return CloseConnection(c.Writer, true)
+}
+
+// CloseConnection 接管ResponseWriter所在的连接并关闭，已写入的数据先发出
+// reset为true时以RST关闭，否则正常关闭（FIN）
+func CloseConnection(w gin.ResponseWriter, reset bool) error {
+	conn, _, err := hijack(w)
+	if err != nil {
+		return err
+	}
+	if tcp, ok := tcpConn(conn); reset && ok {
+		// 先直接关闭TCP连接，否则TLS连接关闭时会先发送close_notify，客户端看到的是正常结束
+		_ = tcp.SetLinger(0)
+		_ = tcp.Close()
+		_ = conn.Close() // 外层包装（如捕获连接）仍需完成自身的清理
+		return nil
+	}
+	return conn.Close()
+}
+
+// tcpConn 逐层解开包装（捕获连接、TLS连接）获取底层TCP连接
+func tcpConn(conn net.Conn) (*net.TCPConn, bool) {
+	for {
+		switch v := conn.(type) {
+		case *net.TCPConn:
+			return v, true
+		case interface{ NetConn() net.Conn }:
+			conn = v.NetConn()
+		default:
+			return nil, false
+		}
+	}
+}
+
+// connContextKey 请求上下文中保存底层连接的键
+type connContextKey struct{}
+
+// ConnContext 将底层连接保存到请求上下文，用作http.Server.ConnContext
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, c)
+}
+
+// ConnFromContext 获取请求所在的底层连接，未通过ConnContext启动时返回nil
+func ConnFromContext(ctx context.Context) net.Conn {
+	conn, _ := ctx.Value(connContextKey{}).(net.Conn)
+	return conn
+}
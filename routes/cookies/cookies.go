@@ -0,0 +1,298 @@
+package cookies
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CookieModule Cookie和会话测试模块
+type CookieModule struct{}
+
+// RegisterRoutes 注册路由
+func (m *CookieModule) RegisterRoutes(r *gin.Engine) {
+	api := r.Group("/api/cookies")
+	{
+		// Cookie测试
+		api.GET("", handleListCookies)
+		api.Any("/set", handleSetCookie)
+		api.Any("/update", handleUpdateCookie)
+		api.Any("/delete", handleDeleteCookie)
+		api.Any("/clear", handleClearCookies)
+		api.GET("/matrix", handleCookieMatrix)
+
+		// 服务端会话测试
+		api.GET("/session", handleSession)
+		api.Any("/session/set", handleSessionSet)
+		api.Any("/session/destroy", handleSessionDestroy)
+	}
+}
+
+// GetPrefix 获取前缀
+func (m *CookieModule) GetPrefix() string {
+	return "/api/cookies"
+}
+
+// GetDescription 获取描述
+func (m *CookieModule) GetDescription() string {
+	return "Cookie属性和服务端会话测试接口"
+}
+
+// 显示收到的Cookie，包括原始Cookie头和重复的名称
+func handleListCookies(c *gin.Context) {
+	received := c.Request.Cookies()
+	cookies := make([]map[string]string, 0, len(received))
+	counts := make(map[string]int)
+	for _, cookie := range received {
+		cookies = append(cookies, map[string]string{"name": cookie.Name, "value": cookie.Value})
+		counts[cookie.Name]++
+	}
+
+	duplicates := make([]string, 0)
+	for name, count := range counts {
+		if count > 1 {
+			duplicates = append(duplicates, name)
+		}
+	}
+
+	response := routes.CreateSuccessResponse("Cookie信息", map[string]interface{}{
+		"cookies":        cookies,
+		"count":          len(cookies),
+		"duplicates":     duplicates,
+		"cookie_headers": c.Request.Header["Cookie"], // HTTP/1.1只应有一个Cookie头，HTTP/2可拆分为多个
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// 设置Cookie
+// name、value 以及属性 domain、path、max_age、expires、samesite=strict|lax|none、secure、httponly、partitioned
+func handleSetCookie(c *gin.Context) {
+	name := c.DefaultQuery("name", "test_cookie")
+	cookie, err := cookieFromQuery(c, name, c.DefaultQuery("value", "test_value"))
+	if err != nil {
+		response := routes.CreateErrorResponse(400, err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	http.SetCookie(c.Writer, cookie)
+	respondSetCookies(c, "Cookie设置成功", nil)
+}
+
+// 更新已有Cookie：value未指定时将数值加1，否则追加版本号
+func handleUpdateCookie(c *gin.Context) {
+	name := c.DefaultQuery("name", "test_cookie")
+	existing, err := c.Request.Cookie(name)
+	if err != nil {
+		response := routes.CreateErrorResponse(404, "请求中没有名为"+name+"的Cookie")
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	value := c.Query("value")
+	if value == "" {
+		if number, err := strconv.Atoi(existing.Value); err == nil {
+			value = strconv.Itoa(number + 1)
+		} else {
+			value = existing.Value + "_v" + strconv.FormatInt(time.Now().Unix(), 10)
+		}
+	}
+
+	cookie, err := cookieFromQuery(c, name, value)
+	if err != nil {
+		response := routes.CreateErrorResponse(400, err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	http.SetCookie(c.Writer, cookie)
+	respondSetCookies(c, "Cookie更新成功", map[string]interface{}{
+		"previous_value": existing.Value,
+		"new_value":      value,
+	})
+}
+
+// 删除Cookie，Domain和Path需与设置时一致才能生效
+func handleDeleteCookie(c *gin.Context) {
+	name := c.Query("name")
+	if name == "" {
+		response := routes.CreateErrorResponse(400, "缺少name参数")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	http.SetCookie(c.Writer, expiredCookie(name, c.DefaultQuery("path", "/"), c.Query("domain")))
+	respondSetCookies(c, "Cookie删除成功", nil)
+}
+
+// 删除请求中携带的所有Cookie
+func handleClearCookies(c *gin.Context) {
+	path := c.DefaultQuery("path", "/")
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, cookie := range c.Request.Cookies() {
+		if seen[cookie.Name] {
+			continue
+		}
+		seen[cookie.Name] = true
+		names = append(names, cookie.Name)
+		http.SetCookie(c.Writer, expiredCookie(cookie.Name, path, c.Query("domain")))
+	}
+	respondSetCookies(c, "Cookie已全部清除", map[string]interface{}{"cleared": names})
+}
+
+// 一次设置所有属性组合的Cookie，用于检查代理是否改写、丢弃或合并Set-Cookie
+func handleCookieMatrix(c *gin.Context) {
+	prefix := c.DefaultQuery("prefix", "m")
+	sameSites := []struct {
+		name  string
+		value http.SameSite
+	}{
+		{"default", http.SameSiteDefaultMode},
+		{"strict", http.SameSiteStrictMode},
+		{"lax", http.SameSiteLaxMode},
+		{"none", http.SameSiteNoneMode},
+	}
+
+	count := 0
+	for _, sameSite := range sameSites {
+		for flags := 0; flags < 8; flags++ {
+			secure, httpOnly, partitioned := flags&1 != 0, flags&2 != 0, flags&4 != 0
+			name := prefix + "_" + sameSite.name
+			if secure {
+				name += "_secure"
+			}
+			if httpOnly {
+				name += "_httponly"
+			}
+			if partitioned {
+				name += "_partitioned"
+			}
+			http.SetCookie(c.Writer, &http.Cookie{
+				Name:        name,
+				Value:       strconv.Itoa(count),
+				Path:        "/",
+				SameSite:    sameSite.value,
+				Secure:      secure,
+				HttpOnly:    httpOnly,
+				Partitioned: partitioned,
+			})
+			count++
+		}
+	}
+
+	// 过期方式和Cookie名称前缀
+	expires := time.Now().Add(time.Hour)
+	extra := []*http.Cookie{
+		{Name: prefix + "_session", Value: "session", Path: "/"},
+		{Name: prefix + "_max_age", Value: "max_age", Path: "/", MaxAge: 3600},
+		{Name: prefix + "_expires", Value: "expires", Path: "/", Expires: expires},
+		{Name: prefix + "_max_age_expires", Value: "both", Path: "/", MaxAge: 60, Expires: expires},
+		{Name: prefix + "_path", Value: "path", Path: "/api/cookies"},
+		{Name: "__Secure-" + prefix, Value: "secure_prefix", Path: "/", Secure: true},
+		{Name: "__Host-" + prefix, Value: "host_prefix", Path: "/", Secure: true},
+	}
+	for _, cookie := range extra {
+		http.SetCookie(c.Writer, cookie)
+	}
+
+	respondSetCookies(c, "Cookie属性组合已设置", nil)
+}
+
+// cookieFromQuery 从查询参数构造Cookie
+func cookieFromQuery(c *gin.Context, name, value string) (*http.Cookie, error) {
+	cookie := &http.Cookie{
+		Name:        name,
+		Value:       value,
+		Path:        c.DefaultQuery("path", "/"),
+		Domain:      c.Query("domain"),
+		Secure:      queryBool(c, "secure"),
+		HttpOnly:    queryBool(c, "httponly"),
+		Partitioned: queryBool(c, "partitioned"),
+	}
+
+	if maxAge := c.Query("max_age"); maxAge != "" {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil {
+			return nil, fmt.Errorf("max_age必须为整数: %s", maxAge)
+		}
+		// net/http中MaxAge为0表示不发送该属性，负数表示Max-Age=0
+		if seconds <= 0 {
+			seconds = -1
+		}
+		cookie.MaxAge = seconds
+	}
+
+	// expires 可以是相对秒数（可为负数）或HTTP日期
+	if expires := c.Query("expires"); expires != "" {
+		if seconds, err := strconv.Atoi(expires); err == nil {
+			cookie.Expires = time.Now().Add(time.Duration(seconds) * time.Second)
+		} else if date, err := http.ParseTime(expires); err == nil {
+			cookie.Expires = date
+		} else {
+			return nil, fmt.Errorf("expires必须为秒数或HTTP日期: %s", expires)
+		}
+	}
+
+	switch strings.ToLower(c.Query("samesite")) {
+	case "":
+	case "strict":
+		cookie.SameSite = http.SameSiteStrictMode
+	case "lax":
+		cookie.SameSite = http.SameSiteLaxMode
+	case "none":
+		cookie.SameSite = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("samesite必须为strict、lax或none: %s", c.Query("samesite"))
+	}
+
+	if err := cookie.Valid(); err != nil {
+		return nil, fmt.Errorf("Cookie无效: %v", err)
+	}
+	return cookie, nil
+}
+
+// expiredCookie 构造用于删除的Cookie
+func expiredCookie(name, path, domain string) *http.Cookie {
+	return &http.Cookie{
+		Name:    name,
+		Value:   "",
+		Path:    path,
+		Domain:  domain,
+		MaxAge:  -1,
+		Expires: time.Unix(0, 0),
+	}
+}
+
+// respondSetCookies 返回本次响应发送的Set-Cookie头
+func respondSetCookies(c *gin.Context, message string, extra map[string]interface{}) {
+	setCookies := c.Writer.Header()["Set-Cookie"]
+	data := map[string]interface{}{
+		"set_cookie": setCookies,
+		"count":      len(setCookies),
+	}
+	for key, value := range extra {
+		data[key] = value
+	}
+	response := routes.CreateSuccessResponse(message, data)
+	c.JSON(http.StatusOK, response)
+}
+
+// queryBool 解析布尔查询参数，仅出现参数名也视为true
+func queryBool(c *gin.Context, name string) bool {
+	value, exists := c.GetQuery(name)
+	if !exists {
+		return false
+	}
+	switch strings.ToLower(value) {
+	case "", "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package cookies
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 会话Cookie名称和空闲过期时间
+const (
+	sessionCookie = "session_id"
+	sessionTTL    = 30 * time.Minute
+)
+
+// session 服务端会话
+type session struct {
+	ID        string            `json:"id"`
+	CreatedAt time.Time         `json:"created_at"`
+	LastSeen  time.Time         `json:"last_seen"`
+	Visits    int               `json:"visits"`
+	Data      map[string]string `json:"data"`
+}
+
+var (
+	sessions    = make(map[string]*session)
+	sessionLock sync.Mutex
+)
+
+// loadSession 获取请求对应的会话，不存在或已过期时创建新会话并设置Cookie
+// 返回会话快照、是否新建以及请求携带的会话ID
+func loadSession(c *gin.Context, update func(*session)) (session, bool, string) {
+	sessionLock.Lock()
+	defer sessionLock.Unlock()
+
+	now := time.Now()
+	for id, s := range sessions {
+		if now.Sub(s.LastSeen) > sessionTTL {
+			delete(sessions, id)
+		}
+	}
+
+	presented := ""
+	if cookie, err := c.Request.Cookie(sessionCookie); err == nil {
+		presented = cookie.Value
+	}
+
+	s, exists := sessions[presented]
+	if !exists {
+		s = &session{ID: newSessionID(), CreatedAt: now, Data: make(map[string]string)}
+		sessions[s.ID] = s
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     sessionCookie,
+			Value:    s.ID,
+			Path:     "/",
+			MaxAge:   int(sessionTTL.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	s.LastSeen = now
+	s.Visits++
+	if update != nil {
+		update(s)
+	}
+
+	snapshot := *s
+	snapshot.Data = make(map[string]string, len(s.Data))
+	for key, value := range s.Data {
+		snapshot.Data[key] = value
+	}
+	return snapshot, !exists, presented
+}
+
+// 获取或创建会话，每次访问计数加1
+func handleSession(c *gin.Context) {
+	s, created, presented := loadSession(c, nil)
+	respondSession(c, "会话信息", s, created, presented)
+}
+
+// 在会话中保存数据
+func handleSessionSet(c *gin.Context) {
+	key := c.Query("key")
+	if key == "" {
+		response := routes.CreateErrorResponse(400, "缺少key参数")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	s, created, presented := loadSession(c, func(s *session) {
+		s.Data[key] = c.Query("value")
+	})
+	respondSession(c, "会话数据已保存", s, created, presented)
+}
+
+// 销毁会话并删除Cookie
+func handleSessionDestroy(c *gin.Context) {
+	destroyed := false
+	if cookie, err := c.Request.Cookie(sessionCookie); err == nil {
+		sessionLock.Lock()
+		_, destroyed = sessions[cookie.Value]
+		delete(sessions, cookie.Value)
+		sessionLock.Unlock()
+	}
+
+	http.SetCookie(c.Writer, expiredCookie(sessionCookie, "/", ""))
+	respondSetCookies(c, "会话已销毁", map[string]interface{}{"destroyed": destroyed})
+}
+
+// respondSession 返回会话信息
+func respondSession(c *gin.Context, message string, s session, created bool, presented string) {
+	sessionLock.Lock()
+	active := len(sessions)
+	sessionLock.Unlock()
+
+	response := routes.CreateSuccessResponse(message, map[string]interface{}{
+		"session":           s,
+		"created":           created,
+		"presented_id":      presented,
+		"presented_unknown": presented != "" && created, // 携带了会话Cookie但服务端不认识（已过期或被篡改）
+		"active_sessions":   active,
+		"set_cookie":        c.Writer.Header()["Set-Cookie"],
+		"idle_timeout_secs": int(sessionTTL.Seconds()),
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// newSessionID 生成随机会话ID
+func newSessionID() string {
+	data := make([]byte, 16)
+	if _, err := rand.Read(data); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(data)
+}
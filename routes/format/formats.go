@@ -13,8 +13,11 @@ import (
 	"time"
 
 	"http_proxy_tool_test_web_demo/routes"
+	"http_proxy_tool_test_web_demo/routes/compression"
+	"http_proxy_tool_test_web_demo/routes/integrity"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 // FormatModule 格式测试模块
@@ -88,7 +91,7 @@ func handleJSON(c *gin.Context) {
 	}
 
 	response := routes.CreateSuccessResponse("JSON响应测试", data)
-	c.JSON(http.StatusOK, response)
+	integrity.JSON(c, http.StatusOK, response)
 }
 
 // XML响应测试
@@ -100,7 +103,7 @@ func handleXML(c *gin.Context) {
 		Time:    time.Now().Format("2006-01-02 15:04:05"),
 	}
 
-	c.XML(http.StatusOK, xmlResp)
+	integrity.XML(c, http.StatusOK, xmlResp)
 }
 
 // HTML响应测试
@@ -141,7 +144,7 @@ func handleHTML(c *gin.Context) {
 </body>
 </html>`
 
-	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
+	integrity.Data(c, http.StatusOK, "text/html; charset=utf-8", []byte(html))
 }
 
 // 文本响应测试
@@ -167,7 +170,7 @@ func handleText(c *gin.Context) {
 
 结束标记: [END]`, time.Now().Format("2006-01-02 15:04:05"))
 
-	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(text))
+	integrity.Data(c, http.StatusOK, "text/plain; charset=utf-8", []byte(text))
 }
 
 // 二进制响应测试
@@ -178,22 +181,39 @@ func handleBinary(c *gin.Context) {
 		data[i] = byte(i % 256)
 	}
 
-	c.Data(http.StatusOK, "application/octet-stream", data)
+	integrity.Data(c, http.StatusOK, "application/octet-stream", data)
 }
 
 // JSON解析测试
 func handleParseJSON(c *gin.Context) {
+	// 摘要针对解码前的请求体，需先于解码包装
+	verifier := integrity.NewVerifier(c.Request)
+	// 按Content-Encoding解码请求体
+	body := compression.DecodeRequest(c.Request, compression.DefaultDecodeLimit)
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		status := compression.ErrorStatus(err)
+		response := routes.CreateErrorResponse(status, "请求体解码失败: "+err.Error())
+		response.Data = map[string]interface{}{"request_encoding": body.Info()}
+		c.JSON(status, response)
+		return
+	}
+
 	var jsonData interface{}
-	if err := c.ShouldBindJSON(&jsonData); err != nil {
+	if err := binding.JSON.BindBody(data, &jsonData); err != nil {
 		response := routes.CreateErrorResponse(400, "JSON解析失败: "+err.Error())
+		response.Data = map[string]interface{}{"request_encoding": body.Info()}
 		c.JSON(http.StatusBadRequest, response)
 		return
 	}
 
 	result := map[string]interface{}{
-		"parsed_data": jsonData,
-		"data_type":   fmt.Sprintf("%T", jsonData),
-		"parse_time":  time.Now().Unix(),
+		"parsed_data":      jsonData,
+		"data_type":        fmt.Sprintf("%T", jsonData),
+		"parse_time":       time.Now().Unix(),
+		"request_encoding": body.Info(),
+		"integrity":        verifier.Result(),
 	}
 
 	response := routes.CreateSuccessResponse("JSON解析成功", result)
@@ -273,20 +293,27 @@ func handleParseMultipart(c *gin.Context) {
 
 // 二进制解析测试
 func handleParseBinary(c *gin.Context) {
-	binaryData, err := io.ReadAll(c.Request.Body)
+	verifier := integrity.NewVerifier(c.Request)
+	body := compression.DecodeRequest(c.Request, compression.DefaultDecodeLimit)
+	defer body.Close()
+	binaryData, err := io.ReadAll(body)
 	if err != nil {
-		response := routes.CreateErrorResponse(400, "读取二进制数据失败: "+err.Error())
-		c.JSON(http.StatusBadRequest, response)
+		status := compression.ErrorStatus(err)
+		response := routes.CreateErrorResponse(status, "读取二进制数据失败: "+err.Error())
+		response.Data = map[string]interface{}{"request_encoding": body.Info()}
+		c.JSON(status, response)
 		return
 	}
 
-	// 分析二进制数据
+	// 分析二进制数据（解码后）
 	analysis := map[string]interface{}{
-		"size":         len(binaryData),
-		"first_bytes":  binaryData[:min(16, len(binaryData))],
-		"last_bytes":   binaryData[max(0, len(binaryData)-16):],
-		"content_type": c.GetHeader("Content-Type"),
-		"parse_time":   time.Now().Unix(),
+		"size":             len(binaryData),
+		"first_bytes":      binaryData[:min(16, len(binaryData))],
+		"last_bytes":       binaryData[max(0, len(binaryData)-16):],
+		"content_type":     c.GetHeader("Content-Type"),
+		"parse_time":       time.Now().Unix(),
+		"request_encoding": body.Info(),
+		"integrity":        verifier.Result(),
 	}
 
 	// 检查是否是文本数据
@@ -383,7 +410,7 @@ func handleLargeXML(c *gin.Context) {
 	buffer.WriteString(`</data>`)
 	buffer.WriteString(`</large_xml_response>`)
 
-	c.Data(http.StatusOK, "application/xml; charset=utf-8", buffer.Bytes())
+	integrity.Data(c, http.StatusOK, "application/xml; charset=utf-8", buffer.Bytes())
 }
 
 // 混合Multipart测试
@@ -412,7 +439,7 @@ func handleMixedMultipart(c *gin.Context) {
 		log.Printf("关闭multipart writer失败: %v", err)
 	}
 
-	c.Data(http.StatusOK, writer.FormDataContentType(), buffer.Bytes())
+	integrity.Data(c, http.StatusOK, writer.FormDataContentType(), buffer.Bytes())
 }
 
 // Gzip压缩测试
@@ -423,10 +450,8 @@ func handleGzip(c *gin.Context) {
 		"time":    time.Now().Unix(),
 	}
 
-	// Gin会自动处理gzip压缩
-	c.Header("Content-Encoding", "gzip")
 	response := routes.CreateSuccessResponse("Gzip压缩测试", data)
-	c.JSON(http.StatusOK, response)
+	compression.RespondJSON(c, http.StatusOK, response, compression.Gzip, compression.DefaultLevel)
 }
 
 // Deflate压缩测试
@@ -437,9 +462,14 @@ func handleDeflate(c *gin.Context) {
 		"time":    time.Now().Unix(),
 	}
 
-	c.Header("Content-Encoding", "deflate")
+	// 默认按RFC 9110使用zlib封装，variant=raw 发送不带zlib头的原始deflate流
+	encoding := compression.Deflate
+	if c.Query("variant") == "raw" {
+		encoding = compression.DeflateRaw
+	}
+
 	response := routes.CreateSuccessResponse("Deflate压缩测试", data)
-	c.JSON(http.StatusOK, response)
+	compression.RespondJSON(c, http.StatusOK, response, encoding, compression.DefaultLevel)
 }
 
 // Base64编码测试
@@ -453,7 +483,7 @@ func handleBase64(c *gin.Context) {
 	}
 
 	response := routes.CreateSuccessResponse("Base64编码测试", result)
-	c.JSON(http.StatusOK, response)
+	integrity.JSON(c, http.StatusOK, response)
 }
 
 // 流式数据测试
@@ -486,7 +516,7 @@ func handleBytes(c *gin.Context) {
 		data[i] = byte(i % 256)
 	}
 
-	c.Data(http.StatusOK, "application/octet-stream", data)
+	integrity.Data(c, http.StatusOK, "application/octet-stream", data)
 }
 
 // 辅助函数
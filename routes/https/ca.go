@@ -0,0 +1,297 @@
+package https
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	caCertFile = "ca.pem"     // CA证书文件名
+	caKeyFile  = "ca-key.pem" // CA私钥文件名
+
+	caValidity   = 10 * 365 * 24 * time.Hour // CA有效期
+	leafValidity = 397 * 24 * time.Hour      // 叶子证书有效期，不超过浏览器接受的398天
+	maxLeafCache = 1000                      // 缓存的叶子证书数量上限，超出时全部清空
+)
+
+// CA 本地证书颁发机构，按SNI即时签发叶子证书
+type CA struct {
+	Cert   *x509.Certificate
+	key    crypto.Signer
+	Source string // generated 或 loaded
+	Dir    string // 证书所在目录，仅在内存中生成时为空
+
+	lock   sync.Mutex
+	leaves map[string]*tls.Certificate
+}
+
+// LeafInfo 已签发的叶子证书
+type LeafInfo struct {
+	Name        string    `json:"name"`
+	Serial      string    `json:"serial"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	Fingerprint string    `json:"sha256_fingerprint"`
+}
+
+// NewCA 在内存中生成CA
+func NewCA() (*CA, error) {
+	return newAuthority("HTTP Proxy Test Tool Local CA", nil, 1)
+}
+
+// newAuthority 生成CA，parent为nil时为自签名的根CA，maxPathLen为其下允许的中间CA层数
+func newAuthority(commonName string, parent *CA, maxPathLen int) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成CA私钥失败: %v", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   commonName,
+			Organization: []string{"HTTP Proxy Test Tool"},
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            maxPathLen,
+		MaxPathLenZero:        maxPathLen == 0,
+	}
+	issuer, issuerKey := template, crypto.Signer(key)
+	if parent != nil {
+		issuer, issuerKey = parent.Cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), issuerKey)
+	if err != nil {
+		return nil, fmt.Errorf("生成CA证书失败: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, key: key, Source: "generated", leaves: make(map[string]*tls.Certificate)}, nil
+}
+
+// LoadOrCreateCA 从目录加载ca.pem和ca-key.pem，两者都不存在时生成并保存
+func LoadOrCreateCA(dir string) (*CA, error) {
+	certPath := filepath.Join(dir, caCertFile)
+	keyPath := filepath.Join(dir, caKeyFile)
+	_, certErr := os.Stat(certPath)
+	_, keyErr := os.Stat(keyPath)
+
+	switch {
+	case certErr == nil && keyErr == nil:
+		ca, err := loadCA(certPath, keyPath)
+		if err != nil {
+			return nil, err
+		}
+		ca.Dir = dir
+		return ca, nil
+	case errors.Is(certErr, os.ErrNotExist) && errors.Is(keyErr, os.ErrNotExist):
+	default:
+		return nil, fmt.Errorf("CA证书和私钥必须同时存在: %s、%s", certPath, keyPath)
+	}
+
+	ca, err := NewCA()
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(ca.key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建CA目录失败: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return nil, fmt.Errorf("保存CA私钥失败: %v", err)
+	}
+	if err := os.WriteFile(certPath, ca.PEM(), 0o644); err != nil {
+		return nil, fmt.Errorf("保存CA证书失败: %v", err)
+	}
+	ca.Dir = dir
+	return ca, nil
+}
+
+// loadCA 读取PEM格式的CA证书和私钥（PKCS#8、PKCS#1或EC）
+func loadCA(certPath, keyPath string) (*CA, error) {
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("加载CA失败: %v", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("解析CA证书失败: %v", err)
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("%s 不是CA证书", certPath)
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("不支持的CA私钥类型")
+	}
+	return &CA{Cert: cert, key: key, Source: "loaded", leaves: make(map[string]*tls.Certificate)}, nil
+}
+
+// PEM CA证书的PEM编码
+func (ca *CA) PEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Cert.Raw})
+}
+
+// Fingerprint CA证书的SHA-256指纹
+func (ca *CA) Fingerprint() string {
+	return fingerprint(ca.Cert.Raw)
+}
+
+// GetCertificate 用作tls.Config.GetCertificate，按SNI签发证书
+func (ca *CA) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return ca.Leaf(serverName(hello))
+}
+
+// serverName 客户端请求的主机名，没有SNI时（按IP访问）使用连接的本地地址
+func serverName(hello *tls.ClientHelloInfo) string {
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if name != "" {
+		return name
+	}
+	if hello.Conn != nil {
+		if host, _, err := net.SplitHostPort(hello.Conn.LocalAddr().String()); err == nil {
+			return host
+		}
+	}
+	return "localhost"
+}
+
+// Leaf 获取或签发name（域名或IP）的叶子证书
+func (ca *CA) Leaf(name string) (*tls.Certificate, error) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if cert, ok := ca.leaves[name]; ok && time.Now().Before(cert.Leaf.NotAfter) {
+		return cert, nil
+	}
+
+	cert, err := ca.issue(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(ca.leaves) >= maxLeafCache {
+		ca.leaves = make(map[string]*tls.Certificate)
+	}
+	ca.leaves[name] = cert
+	return cert, nil
+}
+
+// issue 签发叶子证书，证书链包含CA证书
+func (ca *CA) issue(name string) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template, err := leafTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := ca.sign(template, key)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{leaf.Raw, ca.Cert.Raw}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// leafTemplate name（域名或IP）的服务器证书模板
+func leafTemplate(name string) (*x509.Certificate, error) {
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(leafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(name); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{name}
+	}
+	return template, nil
+}
+
+// sign 用CA签发证书，有效期不超过CA本身
+func (ca *CA) sign(template *x509.Certificate, key crypto.Signer) (*x509.Certificate, error) {
+	if template.NotAfter.After(ca.Cert.NotAfter) {
+		template.NotAfter = ca.Cert.NotAfter
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, key.Public(), ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("签发证书失败: %v", err)
+	}
+	return x509.ParseCertificate(der)
+}
+
+// Leaves 已签发且仍在缓存中的叶子证书，按名称排序
+func (ca *CA) Leaves() []LeafInfo {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	list := make([]LeafInfo, 0, len(ca.leaves))
+	for name, cert := range ca.leaves {
+		list = append(list, LeafInfo{
+			Name:        name,
+			Serial:      cert.Leaf.SerialNumber.Text(16),
+			NotBefore:   cert.Leaf.NotBefore,
+			NotAfter:    cert.Leaf.NotAfter,
+			Fingerprint: fingerprint(cert.Leaf.Raw),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// randomSerial 128位随机序列号
+func randomSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("生成序列号失败: %v", err)
+	}
+	return serial, nil
+}
+
+// fingerprint 证书DER编码的SHA-256，冒号分隔的大写十六进制
+func fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	encoded := strings.ToUpper(hex.EncodeToString(sum[:]))
+	parts := make([]string, 0, len(sum))
+	for i := 0; i < len(encoded); i += 2 {
+		parts = append(parts, encoded[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,161 @@
+package https
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ClientAuthModes 客户端证书策略，对应-tls-client-auth的取值
+var ClientAuthModes = []struct {
+	Name        string
+	Type        tls.ClientAuthType
+	Description string
+}{
+	{"none", tls.NoClientCert, "不请求客户端证书"},
+	{"request", tls.RequestClientCert, "请求客户端证书，未提供或无法校验时仍然握手成功"},
+	{"require", tls.RequireAnyClientCert, "必须提供客户端证书，但不校验签发者"},
+	{"verify", tls.RequireAndVerifyClientCert, "必须提供由本地CA签发的客户端证书"},
+}
+
+// ParseClientAuth 解析客户端证书策略名称
+func ParseClientAuth(name string) (tls.ClientAuthType, error) {
+	for _, mode := range ClientAuthModes {
+		if mode.Name == name {
+			return mode.Type, nil
+		}
+	}
+	return tls.NoClientCert, fmt.Errorf("未知的客户端证书策略: %s（可选none、request、require、verify）", name)
+}
+
+// clientAuthName 客户端证书策略的名称
+func clientAuthName(clientAuth tls.ClientAuthType) string {
+	for _, mode := range ClientAuthModes {
+		if mode.Type == clientAuth {
+			return mode.Name
+		}
+	}
+	return clientAuth.String()
+}
+
+// ClientCertInfo 客户端证书链中的一张证书
+type ClientCertInfo struct {
+	Subject        string    `json:"subject"`
+	Issuer         string    `json:"issuer"`
+	Serial         string    `json:"serial"`
+	DNSNames       []string  `json:"dns_names"`
+	EmailAddresses []string  `json:"email_addresses"`
+	IPAddresses    []string  `json:"ip_addresses"`
+	URIs           []string  `json:"uris"`
+	NotBefore      time.Time `json:"not_before"`
+	NotAfter       time.Time `json:"not_after"`
+	IsCA           bool      `json:"is_ca"`
+	Fingerprint    string    `json:"sha256_fingerprint"`
+}
+
+// clientCertInfo 提取证书信息
+func clientCertInfo(cert *x509.Certificate) ClientCertInfo {
+	info := ClientCertInfo{
+		Subject:        cert.Subject.String(),
+		Issuer:         cert.Issuer.String(),
+		Serial:         cert.SerialNumber.Text(16),
+		DNSNames:       append([]string{}, cert.DNSNames...),
+		EmailAddresses: append([]string{}, cert.EmailAddresses...),
+		IPAddresses:    make([]string, 0, len(cert.IPAddresses)),
+		URIs:           make([]string, 0, len(cert.URIs)),
+		NotBefore:      cert.NotBefore,
+		NotAfter:       cert.NotAfter,
+		IsCA:           cert.IsCA,
+		Fingerprint:    fingerprint(cert.Raw),
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		info.URIs = append(info.URIs, uri.String())
+	}
+	return info
+}
+
+// verifyClientChain 用本地CA校验客户端证书链，request和require策略下TLS层不做校验
+func (ca *CA) verifyClientChain(chain []*x509.Certificate) error {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err
+}
+
+// ClientRequest 客户端证书的签发参数，SAN按格式自动归类为域名、IP、邮箱或URI
+type ClientRequest struct {
+	CommonName string
+	SANs       []string
+	Validity   time.Duration
+}
+
+// IssueClient 签发由本地CA签名的客户端证书，返回PEM编码的证书和PKCS#8私钥
+func (ca *CA) IssueClient(request ClientRequest) (*x509.Certificate, []byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   request.CommonName,
+			Organization: []string{"HTTP Proxy Test Tool"},
+		},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(request.Validity),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, san := range request.SANs {
+		switch {
+		case net.ParseIP(san) != nil:
+			template.IPAddresses = append(template.IPAddresses, net.ParseIP(san))
+		case strings.Contains(san, "://"):
+			uri, err := url.Parse(san)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("无效的URI: %s", san)
+			}
+			template.URIs = append(template.URIs, uri)
+		case strings.Contains(san, "@"):
+			template.EmailAddresses = append(template.EmailAddresses, san)
+		default:
+			template.DNSNames = append(template.DNSNames, san)
+		}
+	}
+	cert, err := ca.sign(template, key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return cert, certPEM, keyPEM, nil
+}
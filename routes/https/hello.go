@@ -0,0 +1,408 @@
+package https
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"http_proxy_tool_test_web_demo/routes"
+)
+
+const maxClientHelloSize = 64 * 1024 // 记录ClientHello的最大字节数，超出时放弃解析
+
+// TLS扩展类型
+const (
+	extServerName          uint16 = 0
+	extSupportedGroups     uint16 = 10
+	extECPointFormats      uint16 = 11
+	extSignatureAlgorithms uint16 = 13
+	extALPN                uint16 = 16
+	extSupportedVersions   uint16 = 43
+)
+
+// ClientHello 从原始ClientHello中解析的字段，保留客户端发送的顺序，包含GREASE取值
+type ClientHello struct {
+	Version             uint16   `json:"version"` // legacy_version
+	CipherSuites        []uint16 `json:"cipher_suites"`
+	Extensions          []uint16 `json:"extensions"`
+	ServerName          string   `json:"server_name"`
+	SupportedGroups     []uint16 `json:"supported_groups"`
+	PointFormats        []uint16 `json:"point_formats"`
+	SignatureAlgorithms []uint16 `json:"signature_algorithms"`
+	ALPN                []string `json:"alpn"`
+	SupportedVersions   []uint16 `json:"supported_versions"`
+	Size                int      `json:"size"` // 握手消息的字节数
+}
+
+// NewListener 包装HTTPS的TCP监听器，记录每个连接的原始ClientHello，供/api/tls/info计算指纹
+func NewListener(inner net.Listener) net.Listener {
+	return &helloListener{Listener: inner}
+}
+
+// helloListener 返回helloConn的监听器
+type helloListener struct {
+	net.Listener
+}
+
+// Accept 接受连接
+func (l *helloListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &helloConn{Conn: conn}, nil
+}
+
+// helloConn 在读取时记录TLS握手记录，直到拿到完整的ClientHello
+type helloConn struct {
+	net.Conn
+
+	lock  sync.Mutex
+	buf   []byte
+	done  bool
+	hello *ClientHello
+	err   error
+}
+
+// NetConn 返回被包装的连接，与tls.Conn.NetConn一致，供接管连接时找到底层TCP连接
+func (c *helloConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// Read 读取数据，同时记录ClientHello
+func (c *helloConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.capture(p[:n])
+	}
+	return n, err
+}
+
+// capture 追加读到的字节并尝试解析
+func (c *helloConn) capture(data []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.done {
+		return
+	}
+	c.buf = append(c.buf, data...)
+	message, complete, err := handshakeMessage(c.buf)
+	if err == nil && !complete {
+		if len(c.buf) <= maxClientHelloSize {
+			return
+		}
+		err = fmt.Errorf("ClientHello超过%d字节", maxClientHelloSize)
+	}
+	if err == nil {
+		c.hello, err = ParseClientHello(message)
+	}
+	c.done, c.err, c.buf = true, err, nil
+}
+
+// ClientHelloFromContext 获取请求所在TLS连接的ClientHello，连接未经NewListener接受时返回错误
+func ClientHelloFromContext(ctx context.Context) (*ClientHello, error) {
+	tlsConn, ok := routes.ConnFromContext(ctx).(*tls.Conn)
+	if !ok {
+		return nil, errors.New("不是TLS连接")
+	}
+	conn, ok := tlsConn.NetConn().(*helloConn)
+	if !ok {
+		return nil, errors.New("HTTPS监听器未记录ClientHello")
+	}
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+	if !conn.done {
+		return nil, errors.New("ClientHello不完整")
+	}
+	return conn.hello, conn.err
+}
+
+// handshakeMessage 从TLS记录中拼出第一条握手消息，complete为false表示还需要更多数据
+func handshakeMessage(buf []byte) ([]byte, bool, error) {
+	var payload []byte
+	for len(buf) >= 5 {
+		if buf[0] != 22 {
+			return nil, false, fmt.Errorf("第一条TLS记录的类型为%d，不是握手", buf[0])
+		}
+		length := int(binary.BigEndian.Uint16(buf[3:5]))
+		if len(buf) < 5+length {
+			break
+		}
+		payload = append(payload, buf[5:5+length]...)
+		buf = buf[5+length:]
+		if len(payload) >= 4 {
+			size := 4 + (int(payload[1])<<16 | int(payload[2])<<8 | int(payload[3]))
+			if payload[0] != 1 {
+				return nil, false, fmt.Errorf("第一条握手消息的类型为%d，不是ClientHello", payload[0])
+			}
+			if len(payload) >= size {
+				return payload[:size], true, nil
+			}
+		}
+	}
+	return nil, false, nil
+}
+
+// helloReader 按TLS编码规则读取字段
+type helloReader struct {
+	data []byte
+	err  bool
+}
+
+// bytes 读取n个字节
+func (r *helloReader) bytes(n int) []byte {
+	if r.err || n > len(r.data) {
+		r.err = true
+		return nil
+	}
+	value := r.data[:n]
+	r.data = r.data[n:]
+	return value
+}
+
+// uint8 读取1字节整数
+func (r *helloReader) uint8() int {
+	if value := r.bytes(1); value != nil {
+		return int(value[0])
+	}
+	return 0
+}
+
+// uint16 读取2字节整数
+func (r *helloReader) uint16() int {
+	if value := r.bytes(2); value != nil {
+		return int(binary.BigEndian.Uint16(value))
+	}
+	return 0
+}
+
+// uint16List 读取以长度前缀的2字节整数列表，prefix为长度字段的字节数
+func (r *helloReader) uint16List(prefix int) []uint16 {
+	length := r.uint8()
+	if prefix == 2 {
+		length = length<<8 | r.uint8()
+	}
+	data := r.bytes(length)
+	list := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		list = append(list, binary.BigEndian.Uint16(data[i:]))
+	}
+	return list
+}
+
+// ParseClientHello 解析握手消息（包含4字节握手头）
+func ParseClientHello(message []byte) (*ClientHello, error) {
+	hello := &ClientHello{Size: len(message), CipherSuites: []uint16{}, Extensions: []uint16{}}
+	r := &helloReader{data: message}
+	if r.uint8() != 1 {
+		return nil, errors.New("不是ClientHello")
+	}
+	r.bytes(3)
+	hello.Version = uint16(r.uint16())
+	r.bytes(32)        // random
+	r.bytes(r.uint8()) // legacy_session_id
+	hello.CipherSuites = r.uint16List(2)
+	r.bytes(r.uint8()) // legacy_compression_methods
+	if r.err {
+		return nil, errors.New("ClientHello格式错误")
+	}
+	if len(r.data) == 0 {
+		return hello, nil
+	}
+
+	extensions := &helloReader{data: r.bytes(r.uint16())}
+	for len(extensions.data) > 0 && !extensions.err {
+		extension := uint16(extensions.uint16())
+		body := &helloReader{data: extensions.bytes(extensions.uint16())}
+		hello.Extensions = append(hello.Extensions, extension)
+		switch extension {
+		case extServerName:
+			names := &helloReader{data: body.bytes(body.uint16())}
+			for len(names.data) > 0 && !names.err {
+				nameType := names.uint8()
+				name := names.bytes(names.uint16())
+				if nameType == 0 {
+					hello.ServerName = string(name)
+				}
+			}
+		case extSupportedGroups:
+			hello.SupportedGroups = body.uint16List(2)
+		case extECPointFormats:
+			for _, format := range body.bytes(body.uint8()) {
+				hello.PointFormats = append(hello.PointFormats, uint16(format))
+			}
+		case extSignatureAlgorithms:
+			hello.SignatureAlgorithms = body.uint16List(2)
+		case extALPN:
+			protocols := &helloReader{data: body.bytes(body.uint16())}
+			for len(protocols.data) > 0 && !protocols.err {
+				hello.ALPN = append(hello.ALPN, string(protocols.bytes(protocols.uint8())))
+			}
+		case extSupportedVersions:
+			hello.SupportedVersions = body.uint16List(1)
+		}
+	}
+	if r.err || extensions.err {
+		return nil, errors.New("ClientHello扩展格式错误")
+	}
+	return hello, nil
+}
+
+// isGREASE 判断是否为RFC 8701保留的GREASE取值
+func isGREASE(value uint16) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+// withoutGREASE 去除GREASE取值
+func withoutGREASE(values []uint16) []uint16 {
+	list := make([]uint16, 0, len(values))
+	for _, value := range values {
+		if !isGREASE(value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// joinValues 按格式连接整数列表
+func joinValues(values []uint16, format func(uint16) string, sep string) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		parts = append(parts, format(value))
+	}
+	return strings.Join(parts, sep)
+}
+
+// decimal 十进制
+func decimal(value uint16) string {
+	return strconv.Itoa(int(value))
+}
+
+// hex4 4位小写十六进制
+func hex4(value uint16) string {
+	return fmt.Sprintf("%04x", value)
+}
+
+// JA3 JA3原始字符串：版本,加密套件,扩展,椭圆曲线,点格式，去除GREASE
+func (h *ClientHello) JA3() string {
+	return strings.Join([]string{
+		decimal(h.Version),
+		joinValues(withoutGREASE(h.CipherSuites), decimal, "-"),
+		joinValues(withoutGREASE(h.Extensions), decimal, "-"),
+		joinValues(withoutGREASE(h.SupportedGroups), decimal, "-"),
+		joinValues(h.PointFormats, decimal, "-"),
+	}, ",")
+}
+
+// JA3Hash JA3字符串的MD5
+func (h *ClientHello) JA3Hash() string {
+	sum := md5.Sum([]byte(h.JA3()))
+	return hex.EncodeToString(sum[:])
+}
+
+// JA4 TCP上的JA4指纹，raw为true时返回排序后未哈希的JA4_r
+func (h *ClientHello) JA4(raw bool) string {
+	ciphers := withoutGREASE(h.CipherSuites)
+	extensions := withoutGREASE(h.Extensions)
+
+	// 有supported_versions扩展时取其中的最高版本
+	version := h.Version
+	if supported := withoutGREASE(h.SupportedVersions); len(supported) > 0 {
+		version = 0
+		for _, value := range supported {
+			version = max(version, value)
+		}
+	}
+	sni := "i"
+	if containsValue(h.Extensions, extServerName) {
+		sni = "d"
+	}
+	prefix := fmt.Sprintf("t%s%s%02d%02d%s", ja4Version(version), sni, min(len(ciphers), 99), min(len(extensions), 99), ja4ALPN(h.ALPN))
+
+	sortedCiphers := append([]uint16{}, ciphers...)
+	sort.Slice(sortedCiphers, func(i, j int) bool { return sortedCiphers[i] < sortedCiphers[j] })
+	sortedExtensions := make([]uint16, 0, len(extensions))
+	for _, extension := range extensions {
+		if extension != extServerName && extension != extALPN {
+			sortedExtensions = append(sortedExtensions, extension)
+		}
+	}
+	sort.Slice(sortedExtensions, func(i, j int) bool { return sortedExtensions[i] < sortedExtensions[j] })
+
+	cipherPart := joinValues(sortedCiphers, hex4, ",")
+	extensionPart := joinValues(sortedExtensions, hex4, ",")
+	if len(h.SignatureAlgorithms) > 0 {
+		extensionPart += "_" + joinValues(h.SignatureAlgorithms, hex4, ",")
+	}
+	if raw {
+		return prefix + "_" + cipherPart + "_" + extensionPart
+	}
+	return prefix + "_" + ja4Hash(cipherPart, len(sortedCiphers)) + "_" + ja4Hash(extensionPart, len(sortedExtensions))
+}
+
+// ja4Version JA4中的TLS版本
+func ja4Version(version uint16) string {
+	switch version {
+	case tls.VersionTLS13:
+		return "13"
+	case tls.VersionTLS12:
+		return "12"
+	case tls.VersionTLS11:
+		return "11"
+	case tls.VersionTLS10:
+		return "10"
+	case 0x0300:
+		return "s3"
+	case 0x0002:
+		return "s2"
+	}
+	return "00"
+}
+
+// ja4ALPN 第一个ALPN取值的首尾字符，非字母数字时取其十六进制的首尾字符
+func ja4ALPN(protocols []string) string {
+	if len(protocols) == 0 || protocols[0] == "" {
+		return "00"
+	}
+	value := protocols[0]
+	first, last := value[0], value[len(value)-1]
+	if isAlphanumeric(first) && isAlphanumeric(last) {
+		return string([]byte{first, last})
+	}
+	encoded := hex.EncodeToString([]byte(value))
+	return string([]byte{encoded[0], encoded[len(encoded)-1]})
+}
+
+// isAlphanumeric 判断ASCII字母或数字
+func isAlphanumeric(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// ja4Hash SHA-256的前12个十六进制字符，列表为空时为12个0
+func ja4Hash(value string, count int) string {
+	if count == 0 {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// containsValue 判断列表是否包含value
+func containsValue(values []uint16, value uint16) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,305 @@
+package https
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPSModule 本地CA和HTTPS监听器相关接口
+type HTTPSModule struct {
+	Hosts   *VirtualHosts // 未启用-tls-port时为nil
+	TLSPort string        // HTTPS端口
+}
+
+// RegisterRoutes 注册路由
+func (m *HTTPSModule) RegisterRoutes(r *gin.Engine) {
+	api := r.Group("/api/tls")
+	{
+		api.GET("/info", m.handleInfo)
+		api.GET("/ca", m.handleCA)
+		api.GET("/ca.pem", m.handleCAPEM)
+		api.GET("/ca.crt", m.handleCADER)
+		api.GET("/certificates", m.handleCertificates)
+		api.GET("/scenarios", m.handleScenarios)
+		api.GET("/crl", m.handleCRL)
+		api.GET("/client", m.handleClientCertificate)
+		api.GET("/client-certificate", m.handleIssueClient)
+		api.POST("/client-certificate", m.handleIssueClient)
+	}
+}
+
+// GetPrefix 获取前缀
+func (m *HTTPSModule) GetPrefix() string {
+	return "/api/tls"
+}
+
+// GetDescription 获取描述
+func (m *HTTPSModule) GetDescription() string {
+	return "HTTPS监听器的握手信息和ClientHello指纹、本地CA下载、按SNI签发的证书、证书缺陷场景和客户端证书"
+}
+
+// requireCA 未启用HTTPS时返回404
+func (m *HTTPSModule) requireCA(c *gin.Context) bool {
+	if m.Hosts == nil {
+		response := routes.CreateErrorResponse(404, "未启用HTTPS监听器，请使用-tls-port启动")
+		c.JSON(http.StatusNotFound, response)
+		return false
+	}
+	return true
+}
+
+// TLS握手信息：协商的版本、加密套件、ALPN、SNI、会话恢复，以及由原始ClientHello计算的JA3/JA4指纹
+// ja3=、ja4=传入客户端自身的指纹时返回是否一致，用于判断代理是否保留了客户端的指纹
+func (m *HTTPSModule) handleInfo(c *gin.Context) {
+	state := c.Request.TLS
+	if state == nil {
+		response := routes.CreateErrorResponse(400, "不是TLS连接，请通过-tls-port指定的HTTPS端口访问")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	data := map[string]interface{}{
+		"version":             tls.VersionName(state.Version),
+		"cipher_suite":        tls.CipherSuiteName(state.CipherSuite),
+		"alpn":                state.NegotiatedProtocol,
+		"sni":                 state.ServerName,
+		"resumed":             state.DidResume,
+		"http_protocol":       c.Request.Proto,
+		"client_certificates": len(state.PeerCertificates),
+	}
+	hello, err := ClientHelloFromContext(c.Request.Context())
+	if err != nil {
+		data["client_hello_error"] = err.Error()
+	} else {
+		cipherNames := make([]string, 0, len(hello.CipherSuites))
+		for _, suite := range withoutGREASE(hello.CipherSuites) {
+			cipherNames = append(cipherNames, tls.CipherSuiteName(suite))
+		}
+		versionNames := make([]string, 0, len(hello.SupportedVersions))
+		for _, version := range withoutGREASE(hello.SupportedVersions) {
+			versionNames = append(versionNames, tls.VersionName(version))
+		}
+		data["client_hello"] = hello
+		data["offered_cipher_suites"] = cipherNames
+		data["offered_versions"] = versionNames
+		fingerprints := map[string]interface{}{
+			"ja3":      hello.JA3Hash(),
+			"ja3_full": hello.JA3(),
+			"ja4":      hello.JA4(false),
+			"ja4_r":    hello.JA4(true),
+		}
+		if expected := c.Query("ja3"); expected != "" {
+			fingerprints["ja3_match"] = expected == hello.JA3Hash() || expected == hello.JA3()
+		}
+		if expected := c.Query("ja4"); expected != "" {
+			fingerprints["ja4_match"] = expected == hello.JA4(false) || expected == hello.JA4(true)
+		}
+		data["fingerprints"] = fingerprints
+	}
+
+	response := routes.CreateSuccessResponse("TLS握手信息", data)
+	c.JSON(http.StatusOK, response)
+}
+
+// CA证书信息
+func (m *HTTPSModule) handleCA(c *gin.Context) {
+	if !m.requireCA(c) {
+		return
+	}
+	cert := m.Hosts.CA.Cert
+	response := routes.CreateSuccessResponse("本地CA", map[string]interface{}{
+		"subject":            cert.Subject.String(),
+		"serial":             cert.SerialNumber.Text(16),
+		"not_before":         cert.NotBefore,
+		"not_after":          cert.NotAfter,
+		"sha256_fingerprint": m.Hosts.CA.Fingerprint(),
+		"source":             m.Hosts.CA.Source,
+		"dir":                m.Hosts.CA.Dir,
+		"tls_port":           m.TLSPort,
+		"downloads": map[string]string{
+			"pem": "/api/tls/ca.pem",
+			"der": "/api/tls/ca.crt",
+		},
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// 下载PEM格式的CA证书
+func (m *HTTPSModule) handleCAPEM(c *gin.Context) {
+	if !m.requireCA(c) {
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="http-proxy-test-ca.pem"`)
+	c.Data(http.StatusOK, "application/x-pem-file", m.Hosts.CA.PEM())
+}
+
+// 下载DER格式的CA证书，便于在操作系统和浏览器中导入
+func (m *HTTPSModule) handleCADER(c *gin.Context) {
+	if !m.requireCA(c) {
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="http-proxy-test-ca.crt"`)
+	c.Data(http.StatusOK, "application/pkix-cert", m.Hosts.CA.Cert.Raw)
+}
+
+// 已按SNI签发的叶子证书
+func (m *HTTPSModule) handleCertificates(c *gin.Context) {
+	if !m.requireCA(c) {
+		return
+	}
+	leaves := m.Hosts.CA.Leaves()
+	response := routes.CreateSuccessResponse("已签发的证书", map[string]interface{}{
+		"certificates": leaves,
+		"count":        len(leaves),
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// 证书缺陷场景，domain为示例主机名使用的域名，默认取本次请求的主机名（IP时为localhost）
+func (m *HTTPSModule) handleScenarios(c *gin.Context) {
+	if !m.requireCA(c) {
+		return
+	}
+	domain := c.Query("domain")
+	if domain == "" {
+		domain = c.Request.Host
+		if host, _, err := net.SplitHostPort(domain); err == nil {
+			domain = host
+		}
+		if net.ParseIP(domain) != nil {
+			domain = "localhost"
+		}
+	}
+
+	scenarios := make([]map[string]string, 0, len(Scenarios))
+	for _, scenario := range Scenarios {
+		host := scenario.Name + "." + domain
+		scenarios = append(scenarios, map[string]string{
+			"name":        scenario.Name,
+			"host":        host,
+			"description": scenario.Description,
+			"expect":      scenario.Expect,
+			"example":     fmt.Sprintf("curl --cacert ca.pem --resolve %s:%s:127.0.0.1 https://%s:%s/api/test", host, m.TLSPort, host, m.TLSPort),
+		})
+	}
+
+	response := routes.CreateSuccessResponse("证书缺陷场景", map[string]interface{}{
+		"scenarios": scenarios,
+		"count":     len(scenarios),
+		"tls_port":  m.TLSPort,
+		"usage":     "以场景名作为SNI的第一段访问HTTPS端口（如expired.example.com），其他主机名返回本地CA签发的有效证书；先从/api/tls/ca.pem下载并信任本地CA",
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// 本地CA的吊销列表（DER），revoked场景签发的证书在其中
+func (m *HTTPSModule) handleCRL(c *gin.Context) {
+	if !m.requireCA(c) {
+		return
+	}
+	crl, err := m.Hosts.CRL()
+	if err != nil {
+		response := routes.CreateErrorResponse(500, err.Error())
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	c.Data(http.StatusOK, "application/pkix-crl", crl)
+}
+
+// 终止TLS的代理转发客户端证书时常用的请求头
+var clientCertHeaders = []string{
+	"Client-Cert",
+	"Client-Cert-Chain",
+	"X-Client-Cert",
+	"X-Client-Verify",
+	"X-Forwarded-Client-Cert",
+	"X-SSL-Client-Cert",
+	"X-SSL-Client-DN",
+	"X-SSL-Client-Verify",
+	"Ssl-Client-Cert",
+}
+
+// 服务器收到的客户端证书链，按-tls-client-auth策略请求；
+// request和require策略下TLS层不校验，这里另外用本地CA校验并给出结果
+func (m *HTTPSModule) handleClientCertificate(c *gin.Context) {
+	forwarded := make(map[string]string)
+	for _, name := range clientCertHeaders {
+		if value := c.GetHeader(name); value != "" {
+			forwarded[name] = value
+		}
+	}
+
+	data := map[string]interface{}{
+		"tls":               c.Request.TLS != nil,
+		"presented":         false,
+		"chain":             []ClientCertInfo{},
+		"forwarded_headers": forwarded,
+	}
+	if m.Hosts != nil {
+		data["mode"] = clientAuthName(m.Hosts.ClientAuth)
+	}
+	if state := c.Request.TLS; state != nil && len(state.PeerCertificates) > 0 {
+		chain := make([]ClientCertInfo, 0, len(state.PeerCertificates))
+		for _, cert := range state.PeerCertificates {
+			chain = append(chain, clientCertInfo(cert))
+		}
+		data["presented"] = true
+		data["chain"] = chain
+		if m.Hosts != nil {
+			err := m.Hosts.CA.verifyClientChain(state.PeerCertificates)
+			data["verified"] = err == nil
+			if err != nil {
+				data["verify_error"] = err.Error()
+			}
+		}
+	}
+
+	response := routes.CreateSuccessResponse("客户端证书", data)
+	c.JSON(http.StatusOK, response)
+}
+
+// 签发由本地CA签名的客户端证书，cn=通用名；san=可重复，域名、IP、邮箱或URI；days=有效天数；
+// format=pem 时返回证书和私钥拼接的PEM，可直接用于curl --cert
+func (m *HTTPSModule) handleIssueClient(c *gin.Context) {
+	if !m.requireCA(c) {
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > 825 {
+		response := routes.CreateErrorResponse(400, "days必须在1到825之间")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	request := ClientRequest{
+		CommonName: c.DefaultQuery("cn", "test-client"),
+		SANs:       c.QueryArray("san"),
+		Validity:   time.Duration(days) * 24 * time.Hour,
+	}
+	cert, certPEM, keyPEM, err := m.Hosts.CA.IssueClient(request)
+	if err != nil {
+		response := routes.CreateErrorResponse(400, err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	if c.Query("format") == "pem" {
+		c.Header("Content-Disposition", `attachment; filename="client.pem"`)
+		c.Data(http.StatusOK, "application/x-pem-file", append(certPEM, keyPEM...))
+		return
+	}
+	response := routes.CreateSuccessResponse("客户端证书已签发", map[string]interface{}{
+		"certificate": clientCertInfo(cert),
+		"cert_pem":    string(certPEM),
+		"key_pem":     string(keyPEM),
+		"ca_pem":      string(m.Hosts.CA.PEM()),
+	})
+	c.JSON(http.StatusOK, response)
+}
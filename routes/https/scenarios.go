@@ -0,0 +1,308 @@
+package https
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// crlPath CRL的下载路径，revoked场景的证书在CRL分发点中指向它
+const crlPath = "/api/tls/crl"
+
+// Scenario 证书缺陷场景，按SNI的第一段选择，如 expired.example.com
+type Scenario struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Expect      string `json:"expect"` // 正确校验证书的客户端应有的表现
+
+	issue func(h *VirtualHosts, name, crlURL string) (*tls.Certificate, error)
+}
+
+// Scenarios 所有证书场景
+var Scenarios = []Scenario{
+	{
+		Name:        "valid",
+		Description: "由本地CA签发的有效证书，用作对照",
+		Expect:      "信任本地CA时握手成功",
+		issue: func(h *VirtualHosts, name, _ string) (*tls.Certificate, error) {
+			return h.CA.issue(name)
+		},
+	},
+	{
+		Name:        "expired",
+		Description: "证书已在一天前过期",
+		Expect:      "拒绝：证书已过期",
+		issue: func(h *VirtualHosts, name, _ string) (*tls.Certificate, error) {
+			return h.issueFromCA(name, func(t *x509.Certificate) {
+				t.NotBefore = time.Now().Add(-30 * 24 * time.Hour)
+				t.NotAfter = time.Now().Add(-24 * time.Hour)
+			}, nil)
+		},
+	},
+	{
+		Name:        "not-yet-valid",
+		Description: "证书的生效时间在30天之后",
+		Expect:      "拒绝：证书尚未生效",
+		issue: func(h *VirtualHosts, name, _ string) (*tls.Certificate, error) {
+			return h.issueFromCA(name, func(t *x509.Certificate) {
+				t.NotBefore = time.Now().Add(30 * 24 * time.Hour)
+			}, nil)
+		},
+	},
+	{
+		Name:        "wronghost",
+		Description: "证书只包含wrong.host.invalid，与请求的主机名不符",
+		Expect:      "拒绝：主机名不匹配",
+		issue: func(h *VirtualHosts, name, _ string) (*tls.Certificate, error) {
+			return h.issueFromCA(name, func(t *x509.Certificate) {
+				t.Subject.CommonName = "wrong.host.invalid"
+				t.DNSNames = []string{"wrong.host.invalid"}
+				t.IPAddresses = nil
+			}, nil)
+		},
+	},
+	{
+		Name:        "selfsigned",
+		Description: "用自身私钥签名的证书，不属于任何CA",
+		Expect:      "拒绝：证书由未知机构签发",
+		issue: func(h *VirtualHosts, name, _ string) (*tls.Certificate, error) {
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			if err != nil {
+				return nil, err
+			}
+			template, err := leafTemplate(name)
+			if err != nil {
+				return nil, err
+			}
+			der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+			if err != nil {
+				return nil, fmt.Errorf("签发证书失败: %v", err)
+			}
+			return chain(der, key)
+		},
+	},
+	{
+		Name:        "untrusted-root",
+		Description: "由启动时生成的另一个根CA签发，证书链完整但根CA不受信任",
+		Expect:      "拒绝：证书由未知机构签发",
+		issue: func(h *VirtualHosts, name, _ string) (*tls.Certificate, error) {
+			return h.untrusted.issue(name)
+		},
+	},
+	{
+		Name:        "incomplete-chain",
+		Description: "由中间CA签发，但握手时只发送叶子证书、不发送中间CA",
+		Expect:      "拒绝：无法构建到本地CA的证书链（会通过AIA补全证书链的客户端除外，这里不提供AIA）",
+		issue: func(h *VirtualHosts, name, _ string) (*tls.Certificate, error) {
+			cert, err := h.intermediate.issue(name)
+			if err != nil {
+				return nil, err
+			}
+			cert.Certificate = cert.Certificate[:1]
+			return cert, nil
+		},
+	},
+	{
+		Name:        "sha1",
+		Description: "由本地CA以SHA-1签名的证书",
+		Expect:      "拒绝：不安全的签名算法",
+		issue: func(h *VirtualHosts, name, _ string) (*tls.Certificate, error) {
+			algorithm := x509.ECDSAWithSHA1
+			if _, ok := h.CA.key.(*rsa.PrivateKey); ok {
+				algorithm = x509.SHA1WithRSA
+			}
+			return h.issueFromCA(name, func(t *x509.Certificate) {
+				t.SignatureAlgorithm = algorithm
+			}, nil)
+		},
+	},
+	{
+		Name:        "weak-key",
+		Description: "由本地CA签发、使用1024位RSA私钥的证书",
+		Expect:      "拒绝：公钥长度不足",
+		issue: func(h *VirtualHosts, name, _ string) (*tls.Certificate, error) {
+			return h.issueFromCA(name, nil, h.weakKey)
+		},
+	},
+	{
+		Name:        "revoked",
+		Description: "由本地CA签发后立即吊销，证书的CRL分发点指向" + crlPath,
+		Expect:      "检查吊销状态的客户端拒绝：证书已吊销；不检查CRL的客户端会握手成功",
+		issue: func(h *VirtualHosts, name, crlURL string) (*tls.Certificate, error) {
+			cert, err := h.issueFromCA(name, func(t *x509.Certificate) {
+				if crlURL != "" {
+					t.CRLDistributionPoints = []string{crlURL}
+				}
+			}, nil)
+			if err != nil {
+				return nil, err
+			}
+			h.revoke(cert.Leaf.SerialNumber)
+			return cert, nil
+		},
+	},
+}
+
+// FindScenario 按名称查找场景
+func FindScenario(name string) (Scenario, bool) {
+	for _, scenario := range Scenarios {
+		if scenario.Name == name {
+			return scenario, true
+		}
+	}
+	return Scenario{}, false
+}
+
+// VirtualHosts 按SNI选择证书：第一段为场景名时使用对应的缺陷证书，否则由本地CA签发有效证书
+type VirtualHosts struct {
+	CA         *CA
+	PlainPort  string             // 明文HTTP端口，用于生成CRL地址
+	ClientAuth tls.ClientAuthType // 客户端证书策略，verify时只接受本地CA签发的证书
+
+	untrusted    *CA             // 不受信任的根CA
+	intermediate *CA             // 本地CA签发的中间CA
+	weakKey      *rsa.PrivateKey // weak-key场景共用的1024位RSA私钥，避免握手时在锁内生成
+
+	lock    sync.Mutex
+	leaves  map[string]*tls.Certificate
+	revoked []x509.RevocationListEntry
+	crl     []byte
+}
+
+// NewVirtualHosts 生成场景使用的根CA和中间CA，全部在本地生成
+func NewVirtualHosts(ca *CA, plainPort string) (*VirtualHosts, error) {
+	untrusted, err := newAuthority("HTTP Proxy Test Tool Untrusted Root", nil, 1)
+	if err != nil {
+		return nil, err
+	}
+	intermediate, err := newAuthority("HTTP Proxy Test Tool Intermediate CA", ca, 0)
+	if err != nil {
+		return nil, err
+	}
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		return nil, err
+	}
+	return &VirtualHosts{
+		CA:           ca,
+		PlainPort:    plainPort,
+		untrusted:    untrusted,
+		intermediate: intermediate,
+		weakKey:      weakKey,
+		leaves:       make(map[string]*tls.Certificate),
+	}, nil
+}
+
+// TLSConfig 按SNI选择证书的TLS配置，ALPN支持h2和http/1.1
+func (h *VirtualHosts) TLSConfig() *tls.Config {
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(h.CA.Cert)
+	return &tls.Config{
+		GetCertificate: h.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+		ClientAuth:     h.ClientAuth,
+		ClientCAs:      clientCAs,
+	}
+}
+
+// GetCertificate 用作tls.Config.GetCertificate
+func (h *VirtualHosts) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := serverName(hello)
+	label, rest, found := strings.Cut(name, ".")
+	scenario, ok := FindScenario(label)
+	if !found || rest == "" || !ok {
+		return h.CA.Leaf(name)
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if cert, ok := h.leaves[name]; ok {
+		return cert, nil
+	}
+	crlURL := ""
+	if h.PlainPort != "" && hello.Conn != nil {
+		if host, _, err := net.SplitHostPort(hello.Conn.LocalAddr().String()); err == nil {
+			crlURL = "http://" + net.JoinHostPort(host, h.PlainPort) + crlPath
+		}
+	}
+	if len(h.leaves) >= maxLeafCache {
+		h.leaves = make(map[string]*tls.Certificate)
+		h.revoked = nil
+		h.crl = nil
+	}
+	cert, err := scenario.issue(h, name, crlURL)
+	if err != nil {
+		return nil, err
+	}
+	h.leaves[name] = cert
+	return cert, nil
+}
+
+// issueFromCA 由本地CA签发证书，modify调整模板，key为nil时生成ECDSA私钥
+func (h *VirtualHosts) issueFromCA(name string, modify func(*x509.Certificate), key crypto.Signer) (*tls.Certificate, error) {
+	if key == nil {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return nil, err
+		}
+	}
+	template, err := leafTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	if modify != nil {
+		modify(template)
+	}
+	leaf, err := h.CA.sign(template, key)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{leaf.Raw, h.CA.Cert.Raw}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// revoke 吊销序列号，调用方持有锁
+func (h *VirtualHosts) revoke(serial *big.Int) {
+	h.revoked = append(h.revoked, x509.RevocationListEntry{SerialNumber: serial, RevocationTime: time.Now()})
+	h.crl = nil
+}
+
+// CRL 本地CA的吊销列表（DER），包含revoked场景签发的所有证书
+func (h *VirtualHosts) CRL() ([]byte, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.crl != nil {
+		return h.crl, nil
+	}
+	now := time.Now()
+	template := &x509.RevocationList{
+		Number:                    big.NewInt(now.UnixNano()),
+		ThisUpdate:                now.Add(-time.Minute),
+		NextUpdate:                now.Add(24 * time.Hour),
+		RevokedCertificateEntries: h.revoked,
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, template, h.CA.Cert, h.CA.key)
+	if err != nil {
+		return nil, fmt.Errorf("生成CRL失败: %v", err)
+	}
+	h.crl = crl
+	return crl, nil
+}
+
+// chain 由单个证书和私钥组成tls.Certificate
+func chain(der []byte, key crypto.Signer) (*tls.Certificate, error) {
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
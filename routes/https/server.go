@@ -0,0 +1,17 @@
+package https
+
+import (
+	"net/http"
+
+	"http_proxy_tool_test_web_demo/routes"
+)
+
+// NewServer 创建HTTPS服务器，使用ServeTLS(NewListener(listener), "", "")启动
+// TLS连接不经过原始字节捕获监听器：net/http需要*tls.Conn才能协商HTTP/2
+func NewServer(handler http.Handler, hosts *VirtualHosts) *http.Server {
+	return &http.Server{
+		Handler:     handler,
+		TLSConfig:   hosts.TLSConfig(),
+		ConnContext: routes.ConnContext,
+	}
+}
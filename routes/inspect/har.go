@@ -0,0 +1,194 @@
+package inspect
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HAR 1.2 结构，见 http://www.softwareishard.com/blog/har-12-spec/
+
+// HAR 根对象
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog 日志
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator 生成工具
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry 一次请求
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Connection      string      `json:"connection,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// HARRequest 请求
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARResponse 响应，服务端不保存响应内容，只记录大小
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARNameValue 名称和值
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData 请求体
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARContent 响应内容
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+// HARTimings 耗时，服务端只能测得处理时间，记为wait
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// buildHAR 将记录转换为HAR
+func buildHAR(selected []*Entry, version string) HAR {
+	har := HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "http_proxy_tool_test_web_demo", Version: version},
+		Entries: make([]HAREntry, 0, len(selected)),
+	}}
+	for _, entry := range selected {
+		har.Log.Entries = append(har.Log.Entries, harEntry(entry))
+	}
+	return har
+}
+
+// harEntry 转换单条记录
+func harEntry(entry *Entry) HAREntry {
+	request := HARRequest{
+		Method:      entry.Method,
+		URL:         entry.URL,
+		HTTPVersion: entry.Proto,
+		Cookies:     make([]HARNameValue, 0),
+		Headers:     make([]HARNameValue, 0, len(entry.Headers)),
+		QueryString: make([]HARNameValue, 0),
+		HeadersSize: -1,
+		BodySize:    entry.BodySize,
+	}
+
+	contentType := ""
+	for _, header := range entry.Headers {
+		request.Headers = append(request.Headers, HARNameValue{Name: header.Name, Value: header.Value})
+		switch strings.ToLower(header.Name) {
+		case "content-type":
+			contentType = header.Value
+		case "cookie":
+			cookies, _ := http.ParseCookie(header.Value)
+			for _, cookie := range cookies {
+				request.Cookies = append(request.Cookies, HARNameValue{Name: cookie.Name, Value: cookie.Value})
+			}
+		}
+	}
+
+	if parsed, err := url.Parse(entry.URL); err == nil {
+		for _, pair := range strings.Split(parsed.RawQuery, "&") {
+			if pair == "" {
+				continue
+			}
+			name, value, _ := strings.Cut(pair, "=")
+			name, _ = url.QueryUnescape(name)
+			value, _ = url.QueryUnescape(value)
+			request.QueryString = append(request.QueryString, HARNameValue{Name: name, Value: value})
+		}
+	}
+
+	if entry.BodySize > 0 {
+		request.PostData = &HARPostData{MimeType: contentType, Text: entry.BodyPreview}
+		switch {
+		case entry.BodyEncoding == "base64":
+			// HAR 1.2的postData没有编码字段，二进制内容以base64文本保存并加注释
+			request.PostData.Comment = "base64编码的二进制请求体"
+		case entry.BodyTruncated:
+			request.PostData.Comment = "请求体已截断"
+		}
+	}
+
+	response := HARResponse{
+		Status:      entry.Status,
+		StatusText:  http.StatusText(entry.Status),
+		HTTPVersion: entry.Proto,
+		Cookies:     make([]HARNameValue, 0),
+		Headers:     make([]HARNameValue, 0, len(entry.ResponseHeaders)),
+		HeadersSize: -1,
+		BodySize:    entry.ResponseSize,
+	}
+	for _, header := range entry.ResponseHeaders {
+		response.Headers = append(response.Headers, HARNameValue{Name: header.Name, Value: header.Value})
+		switch strings.ToLower(header.Name) {
+		case "content-type":
+			response.Content.MimeType = header.Value
+		case "location":
+			response.RedirectURL = header.Value
+		case "set-cookie":
+			if cookie, err := http.ParseSetCookie(header.Value); err == nil {
+				response.Cookies = append(response.Cookies, HARNameValue{Name: cookie.Name, Value: cookie.Value})
+			}
+		}
+	}
+	response.Content.Size = entry.ResponseSize
+
+	harEntry := HAREntry{
+		StartedDateTime: entry.StartedAt.Format(time.RFC3339Nano),
+		Time:            entry.DurationMs,
+		Request:         request,
+		Response:        response,
+		Timings:         HARTimings{Send: 0, Wait: entry.DurationMs, Receive: 0},
+		Comment:         "客户端 " + entry.ClientIP,
+	}
+	if entry.ConnID != 0 {
+		harEntry.Connection = strconv.FormatInt(entry.ConnID, 10)
+	}
+	return harEntry
+}
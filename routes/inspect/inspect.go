@@ -0,0 +1,169 @@
+package inspect
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InspectModule 请求记录查询模块，记录由Recorder中间件产生
+type InspectModule struct {
+	Version string // HAR中的工具版本
+}
+
+// RegisterRoutes 注册路由
+func (m *InspectModule) RegisterRoutes(r *gin.Engine) {
+	api := r.Group("/api/inspect")
+	{
+		// 请求记录
+		api.GET("/requests", handleList)
+		api.GET("/requests/:id", handleGet)
+		api.DELETE("/requests", handleClear)
+
+		// HAR导出
+		api.GET("/har", m.handleHAR)
+	}
+}
+
+// GetPrefix 获取前缀
+func (m *InspectModule) GetPrefix() string {
+	return "/api/inspect"
+}
+
+// GetDescription 获取描述
+func (m *InspectModule) GetDescription() string {
+	return "请求记录查询、过滤和HAR导出接口"
+}
+
+// filter 记录过滤条件
+type filter struct {
+	path    string
+	method  string
+	headers []string // Name 或 Name:子串
+	status  int
+	sinceID int64
+	limit   int
+}
+
+// parseFilter 从查询参数解析过滤条件
+// path=前缀，method=GET，header=Name或Name:Value（可重复，均需满足），status=200，since_id=N，limit=N
+func parseFilter(c *gin.Context, defaultLimit int) filter {
+	f := filter{
+		path:    c.Query("path"),
+		method:  strings.ToUpper(c.Query("method")),
+		headers: c.QueryArray("header"),
+		limit:   defaultLimit,
+	}
+	f.status, _ = strconv.Atoi(c.Query("status"))
+	f.sinceID, _ = strconv.ParseInt(c.Query("since_id"), 10, 64)
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 && limit <= maxEntries {
+		f.limit = limit
+	}
+	return f
+}
+
+// match 判断记录是否满足过滤条件
+func (f filter) match(entry *Entry) bool {
+	if f.path != "" && !strings.HasPrefix(entry.Path, f.path) {
+		return false
+	}
+	if f.method != "" && entry.Method != f.method {
+		return false
+	}
+	if f.status != 0 && entry.Status != f.status {
+		return false
+	}
+	if entry.ID <= f.sinceID {
+		return false
+	}
+	for _, condition := range f.headers {
+		if !hasHeader(entry.Headers, condition) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasHeader 头部名称不区分大小写，值按子串匹配
+func hasHeader(headers []Header, condition string) bool {
+	name, value, withValue := strings.Cut(condition, ":")
+	name = strings.TrimSpace(name)
+	value = strings.TrimSpace(value)
+	for _, header := range headers {
+		if strings.EqualFold(header.Name, name) && (!withValue || strings.Contains(header.Value, value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectEntries 按时间顺序返回满足条件的最近limit条记录
+func selectEntries(f filter) []*Entry {
+	selected := make([]*Entry, 0)
+	for _, entry := range snapshot() {
+		if f.match(entry) {
+			selected = append(selected, entry)
+		}
+	}
+	if len(selected) > f.limit {
+		selected = selected[len(selected)-f.limit:]
+	}
+	return selected
+}
+
+// 请求记录列表，最新的在前
+func handleList(c *gin.Context) {
+	selected := selectEntries(parseFilter(c, 100))
+	summaries := make([]Summary, 0, len(selected))
+	for i := len(selected) - 1; i >= 0; i-- {
+		summaries = append(summaries, selected[i].summary())
+	}
+
+	response := routes.CreateSuccessResponse("请求记录", map[string]interface{}{
+		"requests":   summaries,
+		"count":      len(summaries),
+		"capacity":   maxEntries,
+		"body_limit": previewLimit,
+		"har_url":    "/api/inspect/har?" + c.Request.URL.RawQuery,
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// 单条请求记录
+func handleGet(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response := routes.CreateErrorResponse(400, "无效的记录ID: "+c.Param("id"))
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	entry := find(id)
+	if entry == nil {
+		response := routes.CreateErrorResponse(404, "记录不存在或已被淘汰: "+c.Param("id"))
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	response := routes.CreateSuccessResponse("请求详情", entry)
+	c.JSON(http.StatusOK, response)
+}
+
+// 清空请求记录
+func handleClear(c *gin.Context) {
+	response := routes.CreateSuccessResponse("请求记录已清空", map[string]interface{}{"cleared": clearEntries()})
+	c.JSON(http.StatusOK, response)
+}
+
+// 以HAR 1.2导出满足条件的记录，过滤参数与列表相同，默认导出全部
+func (m *InspectModule) handleHAR(c *gin.Context) {
+	version := m.Version
+	if version == "" {
+		version = "dev"
+	}
+	c.Header("Content-Disposition", `attachment; filename="requests.har"`)
+	c.JSON(http.StatusOK, buildHAR(selectEntries(parseFilter(c, maxEntries)), version))
+}
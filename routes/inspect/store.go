@@ -0,0 +1,283 @@
+package inspect
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"http_proxy_tool_test_web_demo/routes/raw"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxEntries 内存中保留的请求数，超出后丢弃最早的记录
+	maxEntries = 1000
+	// previewLimit 每个请求保存的消息体字节数
+	previewLimit = 16 * 1024
+	// inspectPrefix 查询接口自身的请求不记录
+	inspectPrefix = "/api/inspect"
+)
+
+// Header 保持顺序的头部
+type Header struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// TLSInfo 请求所在TLS连接的信息
+type TLSInfo struct {
+	Version            string `json:"version"`
+	CipherSuite        string `json:"cipher_suite"`
+	ServerName         string `json:"server_name"`
+	NegotiatedProtocol string `json:"negotiated_protocol"`
+	DidResume          bool   `json:"did_resume"`
+	PeerCertificates   int    `json:"peer_certificates"`
+}
+
+// Entry 一条请求记录
+type Entry struct {
+	ID           int64     `json:"id"`
+	StartedAt    time.Time `json:"started_at"`
+	DurationMs   float64   `json:"duration_ms"`
+	Method       string    `json:"method"`
+	URL          string    `json:"url"`
+	Path         string    `json:"path"`
+	Proto        string    `json:"proto"`
+	Host         string    `json:"host"`
+	RemoteAddr   string    `json:"remote_addr"`
+	ClientIP     string    `json:"client_ip"`
+	ConnID       int64     `json:"conn_id,omitempty"`
+	RequestIndex int       `json:"request_index,omitempty"`
+	TLS          *TLSInfo  `json:"tls"`
+
+	Headers      []Header `json:"headers"`
+	HeaderSource string   `json:"header_source"` // wire：线上原始顺序和大小写；sorted：net/http解析后按名称排序
+
+	ContentLength int64  `json:"content_length"`
+	BodySize      int64  `json:"body_size"` // 实际读到的字节数，处理函数未读完时只包含预览部分
+	BodyPreview   string `json:"body_preview"`
+	BodyEncoding  string `json:"body_encoding"` // utf-8 或 base64
+	BodyTruncated bool   `json:"body_truncated"`
+
+	Status          int      `json:"status"`
+	ResponseSize    int      `json:"response_size"`
+	ResponseHeaders []Header `json:"response_headers"`
+}
+
+// Summary 列表中显示的摘要
+type Summary struct {
+	ID         int64     `json:"id"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs float64   `json:"duration_ms"`
+	Method     string    `json:"method"`
+	URL        string    `json:"url"`
+	Status     int       `json:"status"`
+	ClientIP   string    `json:"client_ip"`
+	BodySize   int64     `json:"body_size"`
+	TLS        bool      `json:"tls"`
+}
+
+// summary 生成列表摘要
+func (e *Entry) summary() Summary {
+	return Summary{
+		ID:         e.ID,
+		StartedAt:  e.StartedAt,
+		DurationMs: e.DurationMs,
+		Method:     e.Method,
+		URL:        e.URL,
+		Status:     e.Status,
+		ClientIP:   e.ClientIP,
+		BodySize:   e.BodySize,
+		TLS:        e.TLS != nil,
+	}
+}
+
+var (
+	entries   = make([]*Entry, 0, maxEntries)
+	nextID    int64
+	storeLock sync.Mutex
+)
+
+// record 保存记录并分配ID
+func record(entry *Entry) {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+
+	nextID++
+	entry.ID = nextID
+	if len(entries) >= maxEntries {
+		entries = append(entries[:0], entries[len(entries)-maxEntries+1:]...)
+	}
+	entries = append(entries, entry)
+}
+
+// snapshot 按时间顺序返回当前所有记录
+func snapshot() []*Entry {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+	return append([]*Entry(nil), entries...)
+}
+
+// find 按ID查找记录
+func find(id int64) *Entry {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry
+		}
+	}
+	return nil
+}
+
+// clearEntries 清空记录，返回清除的数量
+func clearEntries() int {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+	count := len(entries)
+	entries = make([]*Entry, 0, maxEntries)
+	return count
+}
+
+// previewBody 读取时保存前previewLimit个字节并统计总大小
+type previewBody struct {
+	io.ReadCloser
+	preview []byte
+	size    int64
+}
+
+func (b *previewBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if remaining := previewLimit - len(b.preview); remaining > 0 {
+		b.preview = append(b.preview, p[:min(n, remaining)]...)
+	}
+	b.size += int64(n)
+	return n, err
+}
+
+// Recorder 请求记录中间件，需放在raw.Capture之后以获取线上的头部顺序
+func Recorder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, inspectPrefix) {
+			c.Next()
+			return
+		}
+
+		startedAt := time.Now()
+		if c.Request.Body == nil {
+			c.Request.Body = http.NoBody
+		}
+		body := &previewBody{ReadCloser: c.Request.Body}
+		c.Request.Body = body
+
+		c.Next()
+
+		// 处理函数没有读取的消息体只补读预览部分，剩余部分由raw.Capture或net/http处理
+		if c.GetHeader("Upgrade") == "" && len(body.preview) < previewLimit {
+			_, _ = io.Copy(io.Discard, io.LimitReader(body, int64(previewLimit-len(body.preview))))
+		}
+
+		record(newEntry(c, startedAt, body))
+	}
+}
+
+// newEntry 根据请求和响应生成记录
+func newEntry(c *gin.Context, startedAt time.Time, body *previewBody) *Entry {
+	r := c.Request
+	entry := &Entry{
+		StartedAt:     startedAt,
+		DurationMs:    float64(time.Since(startedAt).Microseconds()) / 1000,
+		Method:        r.Method,
+		URL:           requestURL(r),
+		Path:          r.URL.Path,
+		Proto:         r.Proto,
+		Host:          r.Host,
+		RemoteAddr:    r.RemoteAddr,
+		ClientIP:      c.ClientIP(),
+		TLS:           tlsInfo(r.TLS),
+		ContentLength: r.ContentLength,
+		BodySize:      body.size,
+		BodyTruncated: body.size > int64(len(body.preview)),
+		Status:        c.Writer.Status(),
+		ResponseSize:  max(c.Writer.Size(), 0),
+	}
+	entry.ConnID, entry.RequestIndex, _ = raw.ConnInfo(c)
+
+	if utf8.Valid(body.preview) {
+		entry.BodyPreview = string(body.preview)
+		entry.BodyEncoding = "utf-8"
+	} else {
+		entry.BodyPreview = base64.StdEncoding.EncodeToString(body.preview)
+		entry.BodyEncoding = "base64"
+	}
+
+	entry.Headers, entry.HeaderSource = requestHeaders(c)
+	entry.ResponseHeaders = sortedHeaders(c.Writer.Header())
+	return entry
+}
+
+// requestURL 还原完整URL，代理发来的绝对形式请求保持原样
+func requestURL(r *http.Request) string {
+	if r.URL.IsAbs() {
+		return r.URL.String()
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+// requestHeaders 优先使用原始捕获中的头部顺序和大小写
+func requestHeaders(c *gin.Context) ([]Header, string) {
+	if captured, err := raw.Current(c); err == nil {
+		headers := make([]Header, 0, len(captured.Headers))
+		for _, header := range captured.Headers {
+			headers = append(headers, Header{Name: header.Name, Value: header.Value})
+		}
+		return headers, "wire"
+	}
+
+	// net/http把Host从头部中移出，这里放回首位
+	headers := []Header{{Name: "Host", Value: c.Request.Host}}
+	return append(headers, sortedHeaders(c.Request.Header)...), "sorted"
+}
+
+// sortedHeaders 按名称排序展开头部
+func sortedHeaders(header http.Header) []Header {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := make([]Header, 0, len(names))
+	for _, name := range names {
+		for _, value := range header[name] {
+			headers = append(headers, Header{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+// tlsInfo 提取TLS连接信息，明文请求返回nil
+func tlsInfo(state *tls.ConnectionState) *TLSInfo {
+	if state == nil {
+		return nil
+	}
+	return &TLSInfo{
+		Version:            tls.VersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		ServerName:         state.ServerName,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		DidResume:          state.DidResume,
+		PeerCertificates:   len(state.PeerCertificates),
+	}
+}
@@ -0,0 +1,112 @@
+package integrity
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// 摘要相关的头部
+const (
+	HeaderReprDigest    = "Repr-Digest"      // RFC 9530，所选表示的摘要
+	HeaderContentDigest = "Content-Digest"   // RFC 9530，消息内容的摘要
+	HeaderDigest        = "Digest"           // RFC 3230，已被RFC 9530废弃，兼容旧客户端
+	HeaderContentMD5    = "Content-MD5"      // RFC 1864，已被RFC 7231移除，兼容旧客户端
+	HeaderSHA256        = "X-Content-SHA256" // 十六进制SHA-256
+)
+
+// HeaderNames 所有摘要头，顺序即输出顺序
+var HeaderNames = []string{HeaderReprDigest, HeaderContentDigest, HeaderDigest, HeaderContentMD5, HeaderSHA256}
+
+// Digests 一段载荷的摘要
+type Digests struct {
+	MD5    []byte
+	SHA256 []byte
+	SHA512 []byte
+}
+
+// Hasher 流式计算摘要
+type Hasher struct {
+	md5    hash.Hash
+	sha256 hash.Hash
+	sha512 hash.Hash
+	size   int64
+}
+
+// NewHasher 创建摘要计算器
+func NewHasher() *Hasher {
+	return &Hasher{md5: md5.New(), sha256: sha256.New(), sha512: sha512.New()}
+}
+
+// Write 追加数据
+func (h *Hasher) Write(data []byte) (int, error) {
+	h.md5.Write(data)
+	h.sha256.Write(data)
+	h.sha512.Write(data)
+	h.size += int64(len(data))
+	return len(data), nil
+}
+
+// Size 已写入的字节数
+func (h *Hasher) Size() int64 {
+	return h.size
+}
+
+// Sum 返回当前摘要，不影响后续写入
+func (h *Hasher) Sum() Digests {
+	return Digests{MD5: h.md5.Sum(nil), SHA256: h.sha256.Sum(nil), SHA512: h.sha512.Sum(nil)}
+}
+
+// Compute 计算数据的摘要
+func Compute(data []byte) Digests {
+	h := NewHasher()
+	_, _ = h.Write(data)
+	return h.Sum()
+}
+
+// Values 按各头部的格式生成取值
+func (d Digests) Values() map[string]string {
+	sha256Value := base64.StdEncoding.EncodeToString(d.SHA256)
+	sha512Value := base64.StdEncoding.EncodeToString(d.SHA512)
+	md5Value := base64.StdEncoding.EncodeToString(d.MD5)
+
+	// RFC 9530使用结构化字段字典，字节序列以冒号包裹
+	structured := "sha-256=:" + sha256Value + ":, sha-512=:" + sha512Value + ":"
+	return map[string]string{
+		HeaderReprDigest:    structured,
+		HeaderContentDigest: structured,
+		HeaderDigest:        "SHA-256=" + sha256Value + ", SHA-512=" + sha512Value + ", MD5=" + md5Value,
+		HeaderContentMD5:    md5Value,
+		HeaderSHA256:        hex.EncodeToString(d.SHA256),
+	}
+}
+
+// Apply 设置所有摘要头，也可用于设置trailer
+func (d Digests) Apply(header http.Header) {
+	values := d.Values()
+	for _, name := range HeaderNames {
+		header.Set(name, values[name])
+	}
+}
+
+// ApplyRepresentation 只设置Repr-Digest，用于206等消息内容只是表示一部分的响应
+func (d Digests) ApplyRepresentation(header http.Header) {
+	header.Set(HeaderReprDigest, d.Values()[HeaderReprDigest])
+}
+
+// Remove 删除所有摘要头，内容被改写（如压缩）后原摘要不再成立
+func Remove(header http.Header) {
+	for _, name := range HeaderNames {
+		header.Del(name)
+	}
+}
+
+// TrailerNames Trailer头的取值
+func TrailerNames() string {
+	return strings.Join(HeaderNames, ", ")
+}
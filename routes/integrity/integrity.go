@@ -0,0 +1,137 @@
+package integrity
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 摘要输出方式，由查询参数 digest= 指定
+const (
+	ModeHeader  = "header"  // 默认，以响应头发送
+	ModeTrailer = "trailer" // 流式输出后以trailer发送
+	ModeNone    = "none"    // 不发送
+)
+
+// IntegrityModule 内容完整性校验模块
+type IntegrityModule struct{}
+
+// RegisterRoutes 注册路由
+func (m *IntegrityModule) RegisterRoutes(r *gin.Engine) {
+	api := r.Group("/api/integrity")
+	{
+		// 上传摘要校验
+		api.Any("/verify", handleVerify)
+	}
+}
+
+// GetPrefix 获取前缀
+func (m *IntegrityModule) GetPrefix() string {
+	return "/api/integrity"
+}
+
+// GetDescription 获取描述
+func (m *IntegrityModule) GetDescription() string {
+	return "Repr-Digest、Content-Digest、Digest、Content-MD5内容完整性校验接口"
+}
+
+// Mode 获取请求指定的摘要输出方式
+func Mode(c *gin.Context) string {
+	switch c.Query("digest") {
+	case ModeTrailer:
+		return ModeTrailer
+	case ModeNone:
+		return ModeNone
+	}
+	return ModeHeader
+}
+
+// Requested 请求是否用digest=显式指定了输出方式
+// 摘要计算代价高的接口据此判断是否必须以响应头发送
+func Requested(c *gin.Context) bool {
+	_, ok := c.GetQuery("digest")
+	return ok
+}
+
+// SetHeaders 按数据设置摘要响应头，digest=none 时不设置
+// 用于必须声明Content-Length、无法发送trailer的响应
+func SetHeaders(c *gin.Context, body []byte) {
+	if Mode(c) == ModeNone {
+		return
+	}
+	Compute(body).Apply(c.Writer.Header())
+}
+
+// Data 输出数据并附带摘要
+// digest=trailer 时先声明Trailer，在消息体之后发送摘要（HTTP/1.1下改用分块编码）
+func Data(c *gin.Context, status int, contentType string, body []byte) {
+	mode := Mode(c)
+	if mode != ModeTrailer {
+		if mode == ModeHeader {
+			Compute(body).Apply(c.Writer.Header())
+		}
+		c.Data(status, contentType, body)
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Trailer", TrailerNames())
+	c.Status(status)
+	hasher := NewHasher()
+	if _, err := io.MultiWriter(c.Writer, hasher).Write(body); err != nil {
+		return
+	}
+	// 压缩中间件改写了消息内容，按原始数据计算的摘要不再适用
+	if header.Get("Content-Encoding") == "" {
+		hasher.Sum().Apply(header)
+	}
+}
+
+// JSON 输出JSON并附带摘要，序列化结果与c.JSON一致
+func JSON(c *gin.Context, status int, obj interface{}) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		response := routes.CreateErrorResponse(500, "JSON序列化失败: "+err.Error())
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	Data(c, status, "application/json; charset=utf-8", body)
+}
+
+// XML 输出XML并附带摘要，序列化结果与c.XML一致
+func XML(c *gin.Context, status int, obj interface{}) {
+	body, err := xml.Marshal(obj)
+	if err != nil {
+		response := routes.CreateErrorResponse(500, "XML序列化失败: "+err.Error())
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	Data(c, status, "application/xml; charset=utf-8", body)
+}
+
+// 校验请求体摘要，支持Repr-Digest、Content-Digest、Digest、Content-MD5、X-Content-SHA256及分块trailer
+func handleVerify(c *gin.Context) {
+	verifier := NewVerifier(c.Request)
+	if _, err := io.Copy(io.Discard, c.Request.Body); err != nil {
+		response := routes.CreateErrorResponse(400, "读取请求体失败: "+err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	result := verifier.Result()
+
+	message := "请求未携带摘要"
+	switch {
+	case result.Verified:
+		message = "摘要校验通过"
+	case result.Present:
+		message = "摘要校验失败"
+	}
+	response := routes.CreateSuccessResponse(message, result)
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,190 @@
+package integrity
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Check 单个摘要值的校验结果
+type Check struct {
+	Header    string `json:"header"`
+	Source    string `json:"source"` // header 或 trailer
+	Algorithm string `json:"algorithm"`
+	Expected  string `json:"expected"`
+	Actual    string `json:"actual,omitempty"`
+	Supported bool   `json:"supported"` // 不支持的算法按RFC 9530忽略，不影响校验结论
+	Match     bool   `json:"match"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Result 请求体摘要校验结果
+type Result struct {
+	Present  bool              `json:"present"`  // 请求是否携带了摘要
+	Verified bool              `json:"verified"` // 至少有一个支持的摘要且全部匹配
+	Checks   []Check           `json:"checks"`
+	Size     int64             `json:"size"`
+	Computed map[string]string `json:"computed"` // 服务端按各头部格式计算的摘要
+}
+
+// Verifier 在读取请求体的同时计算摘要
+// 摘要针对线上收到的字节，即Content-Encoding解码之前的内容
+type Verifier struct {
+	request *http.Request
+	hasher  *Hasher
+}
+
+// verifyingBody 读取时同步计算摘要
+type verifyingBody struct {
+	io.ReadCloser
+	hasher *Hasher
+}
+
+func (b *verifyingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	_, _ = b.hasher.Write(p[:n])
+	return n, err
+}
+
+// NewVerifier 包装请求体，需在其他读取请求体的代码（如compression.DecodeRequest）之前调用
+func NewVerifier(r *http.Request) *Verifier {
+	v := &Verifier{request: r, hasher: NewHasher()}
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	r.Body = &verifyingBody{ReadCloser: r.Body, hasher: v.hasher}
+	return v
+}
+
+// Result 读完请求体后获取校验结果，分块请求的trailer中的摘要也会参与校验
+func (v *Verifier) Result() Result {
+	digests := v.hasher.Sum()
+	result := Result{Checks: make([]Check, 0), Size: v.hasher.Size(), Computed: digests.Values()}
+
+	sources := []struct {
+		name   string
+		header http.Header
+	}{
+		{"header", v.request.Header},
+		{"trailer", v.request.Trailer},
+	}
+	for _, source := range sources {
+		for _, check := range verifyHeader(source.header, digests) {
+			check.Source = source.name
+			result.Checks = append(result.Checks, check)
+		}
+	}
+
+	matched := 0
+	failed := 0
+	for _, check := range result.Checks {
+		switch {
+		case check.Match:
+			matched++
+		case check.Supported:
+			failed++
+		}
+	}
+	result.Present = len(result.Checks) > 0
+	result.Verified = matched > 0 && failed == 0
+	return result
+}
+
+// verifyHeader 校验头部中的所有摘要值
+func verifyHeader(header http.Header, digests Digests) []Check {
+	checks := make([]Check, 0)
+	if header == nil {
+		return checks
+	}
+
+	// RFC 9530字典：sha-256=:base64:, sha-512=:base64:
+	for _, name := range []string{HeaderReprDigest, HeaderContentDigest} {
+		for _, value := range header.Values(name) {
+			for _, member := range strings.Split(value, ",") {
+				algorithm, encoded, found := strings.Cut(strings.TrimSpace(member), "=")
+				if !found {
+					continue
+				}
+				// 忽略成员参数
+				encoded, _, _ = strings.Cut(encoded, ";")
+				check := newCheck(name, algorithm, encoded)
+				if len(encoded) < 2 || encoded[0] != ':' || encoded[len(encoded)-1] != ':' {
+					check.Error = "摘要值必须是以冒号包裹的字节序列"
+					checks = append(checks, check)
+					continue
+				}
+				checks = append(checks, compareBase64(check, encoded[1:len(encoded)-1], digests))
+			}
+		}
+	}
+
+	// RFC 3230：SHA-256=base64, MD5=base64
+	for _, value := range header.Values(HeaderDigest) {
+		for _, member := range strings.Split(value, ",") {
+			algorithm, encoded, found := strings.Cut(strings.TrimSpace(member), "=")
+			if !found {
+				continue
+			}
+			check := newCheck(HeaderDigest, algorithm, encoded)
+			checks = append(checks, compareBase64(check, encoded, digests))
+		}
+	}
+
+	if value := strings.TrimSpace(header.Get(HeaderContentMD5)); value != "" {
+		check := newCheck(HeaderContentMD5, "md5", value)
+		checks = append(checks, compareBase64(check, value, digests))
+	}
+
+	if value := strings.TrimSpace(header.Get(HeaderSHA256)); value != "" {
+		check := newCheck(HeaderSHA256, "sha-256", value)
+		check.Actual = hex.EncodeToString(digests.SHA256)
+		expected, err := hex.DecodeString(value)
+		if err != nil {
+			check.Error = "摘要值必须是十六进制: " + err.Error()
+		} else {
+			check.Match = bytes.Equal(expected, digests.SHA256)
+		}
+		checks = append(checks, check)
+	}
+
+	return checks
+}
+
+// newCheck 创建校验项，算法名不区分大小写
+func newCheck(header, algorithm, expected string) Check {
+	algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+	check := Check{Header: header, Algorithm: algorithm, Expected: expected}
+	switch algorithm {
+	case "sha-256", "sha-512", "md5":
+		check.Supported = true
+	}
+	return check
+}
+
+// compareBase64 比较base64编码的摘要，不支持的算法只记录不判定
+func compareBase64(check Check, encoded string, digests Digests) Check {
+	var actual []byte
+	switch check.Algorithm {
+	case "sha-256":
+		actual = digests.SHA256
+	case "sha-512":
+		actual = digests.SHA512
+	case "md5":
+		actual = digests.MD5
+	default:
+		check.Error = "不支持的算法: " + check.Algorithm
+		return check
+	}
+	check.Actual = base64.StdEncoding.EncodeToString(actual)
+
+	expected, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		check.Error = "摘要值不是有效的base64: " + err.Error()
+		return check
+	}
+	check.Match = bytes.Equal(expected, actual)
+	return check
+}
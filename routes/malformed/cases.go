@@ -0,0 +1,145 @@
+package malformed
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Case 一个畸形响应用例
+type Case struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Expect      string `json:"expect"` // 健壮的代理应有的处理方式
+	Raw         string `json:"raw"`    // 线上字节，写出后关闭连接
+}
+
+// payload 所有用例共用的消息体
+const payload = "malformed response body\n"
+
+// head 状态行和通用头部，后面接用例特有的头部、空行和消息体
+func head(extra ...string) string {
+	parts := append([]string{"HTTP/1.1 200 OK", "Content-Type: text/plain; charset=utf-8", "Connection: close"}, extra...)
+	return strings.Join(parts, "\r\n") + "\r\n"
+}
+
+// length 消息体长度的Content-Length头
+func length(n int) string {
+	return "Content-Length: " + strconv.Itoa(n)
+}
+
+// chunk 格式正确的分块
+func chunk(data string) string {
+	return strconv.FormatInt(int64(len(data)), 16) + "\r\n" + data + "\r\n"
+}
+
+// Cases 所有用例，按名称访问
+var Cases = buildCases()
+
+// buildCases 构造用例，各用例共用payload以便比较代理转发的结果
+func buildCases() []Case {
+	n := len(payload)
+	cases := []Case{
+		{
+			Name:        "duplicate-content-length",
+			Description: "两个取值相同的Content-Length",
+			Expect:      "RFC 9110允许合并相同取值，也可拒绝",
+			Raw:         head(length(n), length(n)) + "\r\n" + payload,
+		},
+		{
+			Name:        "conflicting-content-length",
+			Description: "两个取值不同的Content-Length",
+			Expect:      "必须视为不可恢复的错误（502），不能任选其一",
+			Raw:         head(length(n), length(n+10)) + "\r\n" + payload + strings.Repeat("x", 10),
+		},
+		{
+			Name:        "content-length-list",
+			Description: "Content-Length取值为逗号分隔的列表且不一致",
+			Expect:      "必须拒绝（502）",
+			Raw:         head(fmt.Sprintf("Content-Length: %d, %d", n, n+10)) + "\r\n" + payload,
+		},
+		{
+			Name:        "negative-content-length",
+			Description: "负数的Content-Length",
+			Expect:      "必须拒绝（502）",
+			Raw:         head("Content-Length: -1") + "\r\n" + payload,
+		},
+		{
+			Name:        "content-length-and-chunked",
+			Description: "同时带有Content-Length和Transfer-Encoding: chunked",
+			Expect:      "按分块解析并删除Content-Length，或拒绝；转发时不能同时保留两者",
+			Raw:         head(length(n*2), "Transfer-Encoding: chunked") + "\r\n" + chunk(payload) + "0\r\n\r\n",
+		},
+		{
+			Name:        "obs-fold",
+			Description: "以空白开头的头部续行（obs-fold）",
+			Expect:      "拒绝（502），或把续行替换为空格后转发",
+			Raw:         head(length(n), "X-Folded: first line", " continued line", "\tand another") + "\r\n" + payload,
+		},
+		{
+			Name:        "bare-lf",
+			Description: "所有行以单独的LF结束",
+			Expect:      "宽松解析或拒绝，转发时应规范为CRLF",
+			Raw:         strings.ReplaceAll(head(length(n))+"\r\n", "\r\n", "\n") + payload,
+		},
+		{
+			Name:        "space-before-colon",
+			Description: "头部名称和冒号之间有空白",
+			Expect:      "必须拒绝（502），或删除空白后转发",
+			Raw:         head(fmt.Sprintf("Content-Length : %d", n)) + "\r\n" + payload,
+		},
+		{
+			Name:        "invalid-chunk-size",
+			Description: "分块大小不是十六进制数",
+			Expect:      "中断响应，不能把后续字节当作消息体",
+			Raw:         head("Transfer-Encoding: chunked") + "\r\nzz\r\n" + payload + "\r\n0\r\n\r\n",
+		},
+		{
+			Name:        "chunk-size-overflow",
+			Description: "分块大小超出64位整数",
+			Expect:      "中断响应，不能溢出",
+			Raw:         head("Transfer-Encoding: chunked") + "\r\n1ffffffffffffffff\r\n" + payload + "\r\n0\r\n\r\n",
+		},
+		{
+			Name:        "missing-final-chunk",
+			Description: "缺少结束分块（0 CRLF CRLF）就关闭连接",
+			Expect:      "视为不完整的响应，不能缓存，向客户端发出的分块也不能正常结束",
+			Raw:         head("Transfer-Encoding: chunked") + "\r\n" + chunk(payload),
+		},
+		{
+			Name:        "body-longer-than-content-length",
+			Description: "消息体比Content-Length长",
+			Expect:      "只转发Content-Length字节并丢弃多余数据，不能把多余数据当作下一个响应",
+			Raw:         head(length(n)) + "\r\n" + payload + "EXTRA DATA AFTER CONTENT-LENGTH\n",
+		},
+		{
+			Name:        "body-shorter-than-content-length",
+			Description: "消息体比Content-Length短，随后关闭连接",
+			Expect:      "视为不完整的响应，不能缓存，向客户端的连接也应中断",
+			Raw:         head(length(n+100)) + "\r\n" + payload,
+		},
+		{
+			Name:        "non-numeric-status",
+			Description: "状态码不是数字",
+			Expect:      "必须拒绝（502）",
+			Raw:         strings.Replace(head(length(n)), "200 OK", "2OO OK", 1) + "\r\n" + payload,
+		},
+		{
+			Name:        "http09",
+			Description: "HTTP/0.9风格的响应，没有状态行和头部，直接发送消息体",
+			Expect:      "拒绝（502），现代代理不应接受HTTP/0.9响应",
+			Raw:         payload,
+		},
+	}
+	return cases
+}
+
+// Find 按名称查找用例
+func Find(name string) (Case, bool) {
+	for _, c := range Cases {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Case{}, false
+}
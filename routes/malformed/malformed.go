@@ -0,0 +1,97 @@
+package malformed
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// malformedPrefix 畸形响应接口的路径前缀
+const malformedPrefix = "/api/malformed"
+
+// MalformedModule 畸形响应模块，接管连接直接写出不符合HTTP规范的响应
+type MalformedModule struct{}
+
+// RegisterRoutes 注册路由
+func (m *MalformedModule) RegisterRoutes(r *gin.Engine) {
+	api := r.Group(malformedPrefix)
+	{
+		api.GET("", handleList)
+		api.Any("/:name", handleCase)
+	}
+}
+
+// GetPrefix 获取前缀
+func (m *MalformedModule) GetPrefix() string {
+	return malformedPrefix
+}
+
+// GetDescription 获取描述
+func (m *MalformedModule) GetDescription() string {
+	return "重复Content-Length、obs-fold、非法分块等畸形响应，用于测试代理对异常上游的处理"
+}
+
+// Endpoints 每个用例一个接口说明，供/api-docs使用
+func Endpoints() []map[string]string {
+	endpoints := make([]map[string]string, 0, len(Cases))
+	for _, c := range Cases {
+		endpoints = append(endpoints, map[string]string{
+			"method": "GET",
+			"path":   malformedPrefix + "/" + c.Name,
+			"desc":   c.Description + "；" + c.Expect,
+		})
+	}
+	return endpoints
+}
+
+// 用例列表及线上字节
+func handleList(c *gin.Context) {
+	list := make([]map[string]interface{}, 0, len(Cases))
+	for _, item := range Cases {
+		list = append(list, map[string]interface{}{
+			"name":        item.Name,
+			"description": item.Description,
+			"expect":      item.Expect,
+			"url":         malformedPrefix + "/" + item.Name,
+			"raw":         item.Raw,
+		})
+	}
+
+	response := routes.CreateSuccessResponse("畸形响应用例", map[string]interface{}{
+		"cases": list,
+		"count": len(list),
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// 接管连接写出用例的原始字节后关闭连接
+func handleCase(c *gin.Context) {
+	item, ok := Find(c.Param("name"))
+	if !ok {
+		response := routes.CreateErrorResponse(404, "未知的畸形响应用例: "+c.Param("name")+"，用例列表见"+malformedPrefix)
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	conn, rw, err := routes.HijackConnection(c)
+	if errors.Is(err, routes.ErrHijackNotSupported) {
+		response := routes.CreateErrorResponse(501, "畸形响应需要接管连接: 仅支持HTTP/1.x，原始字节见data")
+		response.Data = item
+		c.JSON(http.StatusNotImplemented, response)
+		return
+	}
+	if err != nil {
+		response := routes.CreateErrorResponse(500, "接管连接失败: "+err.Error())
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	_, _ = rw.Writer.WriteString(item.Raw)
+	_ = rw.Writer.Flush()
+}
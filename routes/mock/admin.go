@@ -0,0 +1,225 @@
+package mock
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminPrefix 运行时管理接口的路径前缀
+const adminPrefix = "/admin/mocks"
+
+// replace 替换整个路由表并清空统计，返回被替换的路由数
+func (m *MockModule) replace(list []*Route) int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	replaced := len(m.routes)
+	m.routes = make([]*Route, 0, len(list))
+	m.stats = make(map[*Route]*RouteStats)
+	for _, route := range list {
+		m.insertLocked(route)
+	}
+	return replaced
+}
+
+// insertLocked 添加路由，未指定id时自动分配，调用方需持有写锁
+func (m *MockModule) insertLocked(route *Route) {
+	for route.ID == "" {
+		m.nextID++
+		if id := strconv.Itoa(m.nextID); m.indexLocked(id) < 0 {
+			route.ID = id
+		}
+	}
+	m.stats[route] = &RouteStats{Faults: make(map[string]int64)}
+	m.routes = append(m.routes, route)
+	m.sortLocked()
+}
+
+// sortLocked 按优先级从高到低排序，同优先级保持添加顺序
+func (m *MockModule) sortLocked() {
+	sort.SliceStable(m.routes, func(i, j int) bool {
+		return m.routes[i].Priority > m.routes[j].Priority
+	})
+}
+
+// indexLocked 按id查找路由位置，不存在时返回-1
+func (m *MockModule) indexLocked(id string) int {
+	for i, route := range m.routes {
+		if route.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// readBody 读取请求体，Content-Type包含yaml时按YAML解析，否则按JSON解析
+func readBody(c *gin.Context) ([]byte, string, error) {
+	format := "json"
+	if strings.Contains(strings.ToLower(c.ContentType()), "yaml") {
+		format = "yaml"
+	}
+	if c.Request.Body == nil {
+		return nil, format, errors.New("缺少请求体")
+	}
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, format, fmt.Errorf("读取请求体失败: %v", err)
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil, format, errors.New("缺少请求体")
+	}
+	return data, format, nil
+}
+
+// readRoute 解析并校验请求体中的单个路由
+func readRoute(c *gin.Context) (*Route, error) {
+	data, format, err := readBody(c)
+	if err != nil {
+		return nil, err
+	}
+	route := &Route{}
+	if err := decode(data, format, route); err != nil {
+		return nil, err
+	}
+	if err := route.compile(""); err != nil {
+		return nil, fmt.Errorf("路由(%s %s): %v", route.Method, route.Path, err)
+	}
+	return route, nil
+}
+
+// badRequest 返回400
+func badRequest(c *gin.Context, err error) {
+	response := routes.CreateErrorResponse(400, err.Error())
+	c.JSON(http.StatusBadRequest, response)
+}
+
+// notFound 返回404
+func notFound(c *gin.Context, id string) {
+	response := routes.CreateErrorResponse(404, "模拟路由不存在: "+id)
+	c.JSON(http.StatusNotFound, response)
+}
+
+// 添加模拟路由
+func (m *MockModule) handleCreate(c *gin.Context) {
+	route, err := readRoute(c)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if route.ID != "" && m.indexLocked(route.ID) >= 0 {
+		response := routes.CreateErrorResponse(409, "模拟路由已存在: "+route.ID+"，请使用PUT更新")
+		c.JSON(http.StatusConflict, response)
+		return
+	}
+	m.insertLocked(route)
+
+	c.Header("Location", adminPrefix+"/"+route.ID)
+	response := routes.CreateSuccessResponse("模拟路由已添加", m.describe(route))
+	c.JSON(http.StatusCreated, response)
+}
+
+// 整体替换模拟路由，请求体格式与配置文件相同
+func (m *MockModule) handleReplace(c *gin.Context) {
+	data, format, err := readBody(c)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	config, err := Parse(data, format, "")
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	m.replace(config.Routes)
+
+	response := routes.CreateSuccessResponse("模拟路由已替换", map[string]interface{}{
+		"count": len(config.Routes),
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// 删除全部模拟路由
+func (m *MockModule) handleClear(c *gin.Context) {
+	deleted := m.replace(nil)
+
+	response := routes.CreateSuccessResponse("模拟路由已清空", map[string]interface{}{"deleted": deleted})
+	c.JSON(http.StatusOK, response)
+}
+
+// 单个模拟路由和命中统计
+func (m *MockModule) handleGet(c *gin.Context) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	index := m.indexLocked(c.Param("id"))
+	if index < 0 {
+		notFound(c, c.Param("id"))
+		return
+	}
+	response := routes.CreateSuccessResponse("模拟路由", m.describe(m.routes[index]))
+	c.JSON(http.StatusOK, response)
+}
+
+// 更新模拟路由，不存在时创建；更新后命中统计重新计数
+func (m *MockModule) handleUpdate(c *gin.Context) {
+	id := c.Param("id")
+	route, err := readRoute(c)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	if route.ID != "" && route.ID != id {
+		badRequest(c, fmt.Errorf("请求体中的id(%s)与路径(%s)不一致", route.ID, id))
+		return
+	}
+	route.ID = id
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	index := m.indexLocked(id)
+	if index < 0 {
+		m.insertLocked(route)
+		c.Header("Location", adminPrefix+"/"+id)
+		response := routes.CreateSuccessResponse("模拟路由已添加", m.describe(route))
+		c.JSON(http.StatusCreated, response)
+		return
+	}
+	delete(m.stats, m.routes[index])
+	m.routes[index] = route
+	m.stats[route] = &RouteStats{Faults: make(map[string]int64)}
+	m.sortLocked()
+
+	response := routes.CreateSuccessResponse("模拟路由已更新", m.describe(route))
+	c.JSON(http.StatusOK, response)
+}
+
+// 删除模拟路由
+func (m *MockModule) handleDelete(c *gin.Context) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	index := m.indexLocked(c.Param("id"))
+	if index < 0 {
+		notFound(c, c.Param("id"))
+		return
+	}
+	deleted := m.routes[index]
+	description := m.describe(deleted)
+	delete(m.stats, deleted)
+	m.routes = append(m.routes[:index], m.routes[index+1:]...)
+
+	response := routes.CreateSuccessResponse("模拟路由已删除", description)
+	c.JSON(http.StatusOK, response)
+}
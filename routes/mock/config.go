@@ -0,0 +1,367 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 故障类型
+const (
+	FaultStatus   = "status"   // 返回指定状态码和内容
+	FaultDelay    = "delay"    // 额外延迟后正常响应
+	FaultReset    = "reset"    // 不发送响应，以RST断开连接
+	FaultEmpty    = "empty"    // 不发送响应，正常关闭连接
+	FaultHang     = "hang"     // 等待delay（默认30秒）后断开，模拟上游无响应
+	FaultTruncate = "truncate" // 按完整Content-Length只发送一半消息体后断开
+)
+
+// defaultHang hang故障未指定delay时的等待时间
+const defaultHang = 30 * time.Second
+
+// Config 模拟接口配置文件
+type Config struct {
+	Routes []*Route `json:"routes" yaml:"routes"`
+}
+
+// Route 一个模拟接口，按priority从高到低、同优先级按添加顺序匹配，先匹配的优先
+type Route struct {
+	ID         string       `json:"id,omitempty" yaml:"id"` // 未指定时自动分配
+	Name       string       `json:"name,omitempty" yaml:"name"`
+	Priority   int          `json:"priority,omitempty" yaml:"priority"`
+	Method     string       `json:"method,omitempty" yaml:"method"` // 为空或ANY匹配所有方法，多个方法用逗号分隔；GET同时匹配HEAD
+	Path       string       `json:"path" yaml:"path"`               // 相对/mock，支持 :name 参数和末尾的 *name 通配
+	Match      *Match       `json:"match,omitempty" yaml:"match"`
+	Status     int          `json:"status,omitempty" yaml:"status"` // 默认200
+	Headers    HeaderValues `json:"headers,omitempty" yaml:"headers"`
+	Body       interface{}  `json:"body,omitempty" yaml:"body"`               // 字符串原样输出，其他结构序列化为JSON
+	BodyBase64 string       `json:"body_base64,omitempty" yaml:"body_base64"` // 二进制消息体
+	BodyFile   string       `json:"body_file,omitempty" yaml:"body_file"`     // 相对配置文件所在目录
+	Delay      Duration     `json:"delay,omitempty" yaml:"delay"`
+	DelayMax   Duration     `json:"delay_max,omitempty" yaml:"delay_max"` // 设置时在delay和delay_max之间随机
+	Faults     []*Fault     `json:"faults,omitempty" yaml:"faults"`
+
+	methods     []string
+	segments    []string
+	body        []byte
+	contentType string // 未配置Content-Type时使用
+}
+
+// Match 路径之外的匹配条件，所有条件都满足才匹配
+// 值为"*"表示只要求存在，否则要求完全相等；头部名称不区分大小写
+type Match struct {
+	Query   map[string]string `json:"query,omitempty" yaml:"query"`
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers"`
+}
+
+// Fault 按概率触发的故障，各故障的概率之和不能超过1
+type Fault struct {
+	Probability float64      `json:"probability" yaml:"probability"`
+	Type        string       `json:"type" yaml:"type"`
+	Status      int          `json:"status,omitempty" yaml:"status"` // status故障默认500
+	Headers     HeaderValues `json:"headers,omitempty" yaml:"headers"`
+	Body        interface{}  `json:"body,omitempty" yaml:"body"`
+	Delay       Duration     `json:"delay,omitempty" yaml:"delay"`
+
+	body        []byte
+	contentType string
+}
+
+// HeaderValues 响应头，值可以是字符串或字符串列表（如多个Set-Cookie）
+type HeaderValues map[string]StringList
+
+// StringList 可从单个字符串或列表解析
+type StringList []string
+
+// UnmarshalJSON 支持字符串或字符串数组
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = StringList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("头部取值必须是字符串或字符串列表")
+	}
+	*l = list
+	return nil
+}
+
+// UnmarshalYAML 支持标量或序列
+func (l *StringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = StringList{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return errors.New("头部取值必须是字符串或字符串列表")
+	}
+	*l = list
+	return nil
+}
+
+// Duration 时长，可写作"500ms"、"2s"或毫秒数
+type Duration time.Duration
+
+// parseDuration 解析字符串时长，纯数字按毫秒处理
+func parseDuration(value string) (Duration, error) {
+	value = strings.TrimSpace(value)
+	if ms, err := strconv.ParseFloat(value, 64); err == nil {
+		return Duration(ms * float64(time.Millisecond)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("无效的时长: %s", value)
+	}
+	return Duration(d), nil
+}
+
+// UnmarshalJSON 支持字符串或毫秒数
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		value = string(data)
+	}
+	parsed, err := parseDuration(value)
+	*d = parsed
+	return err
+}
+
+// UnmarshalYAML 支持字符串或毫秒数
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := parseDuration(node.Value)
+	*d = parsed
+	return err
+}
+
+// MarshalJSON 以"500ms"形式输出
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Load 读取配置文件，.json按JSON解析，其他扩展名按YAML解析
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取模拟配置失败: %v", err)
+	}
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = "json"
+	}
+	return Parse(data, format, filepath.Dir(path))
+}
+
+// Parse 解析并校验配置，baseDir用于解析body_file的相对路径，为空时不允许body_file
+// 未知字段视为错误，避免拼写错误被静默忽略
+func Parse(data []byte, format, baseDir string) (*Config, error) {
+	config := &Config{}
+	if err := decode(data, format, config); err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool)
+	for i, route := range config.Routes {
+		if route == nil {
+			return nil, fmt.Errorf("第%d个路由为空", i+1)
+		}
+		if err := route.compile(baseDir); err != nil {
+			return nil, fmt.Errorf("第%d个路由(%s %s): %v", i+1, route.Method, route.Path, err)
+		}
+		if route.ID != "" {
+			if ids[route.ID] {
+				return nil, fmt.Errorf("第%d个路由: 重复的id: %s", i+1, route.ID)
+			}
+			ids[route.ID] = true
+		}
+	}
+	return config, nil
+}
+
+// decode 按格式严格解码，未知字段视为错误
+func decode(data []byte, format string, v interface{}) error {
+	switch format {
+	case "json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(v); err != nil {
+			return fmt.Errorf("JSON配置解析失败: %v", err)
+		}
+	case "yaml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("YAML配置解析失败: %v", err)
+		}
+	default:
+		return fmt.Errorf("不支持的配置格式: %s", format)
+	}
+	return nil
+}
+
+// compile 校验路由并预先解析路径、方法和消息体
+func (r *Route) compile(baseDir string) error {
+	if !strings.HasPrefix(r.Path, "/") {
+		return errors.New("path必须以/开头")
+	}
+	r.segments = strings.Split(strings.Trim(r.Path, "/"), "/")
+	for i, segment := range r.segments {
+		if strings.HasPrefix(segment, "*") && i != len(r.segments)-1 {
+			return errors.New("通配符*只能出现在路径末尾")
+		}
+		if (strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*")) && len(segment) == 1 {
+			return errors.New("参数需要名称，如 :id")
+		}
+	}
+
+	if strings.ContainsAny(r.ID, "/?#") {
+		return errors.New("id不能包含/、?或#")
+	}
+	if r.Match != nil {
+		for name := range r.Match.Headers {
+			if strings.TrimSpace(name) == "" {
+				return errors.New("match.headers的头部名称不能为空")
+			}
+		}
+	}
+
+	r.methods = nil
+	for _, method := range strings.Split(r.Method, ",") {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if method == "ANY" {
+			r.methods = nil
+			break
+		}
+		if method != "" {
+			r.methods = append(r.methods, method)
+		}
+	}
+
+	if r.Status == 0 {
+		r.Status = http.StatusOK
+	}
+	if r.Status < 100 || r.Status > 999 {
+		return fmt.Errorf("无效的状态码: %d", r.Status)
+	}
+	if r.Delay < 0 || (r.DelayMax != 0 && r.DelayMax < r.Delay) {
+		return errors.New("delay_max不能小于delay")
+	}
+
+	bodies := 0
+	for _, set := range []bool{r.Body != nil, r.BodyBase64 != "", r.BodyFile != ""} {
+		if set {
+			bodies++
+		}
+	}
+	if bodies > 1 {
+		return errors.New("body、body_base64和body_file只能设置一个")
+	}
+	switch {
+	case r.BodyBase64 != "":
+		data, err := base64.StdEncoding.DecodeString(r.BodyBase64)
+		if err != nil {
+			return fmt.Errorf("body_base64解码失败: %v", err)
+		}
+		r.body = data
+		r.contentType = http.DetectContentType(data)
+	case r.BodyFile != "":
+		if baseDir == "" {
+			// 管理接口不允许读取服务器上的文件
+			return errors.New("body_file只能在配置文件中使用，请改用body或body_base64")
+		}
+		path := r.BodyFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("读取body_file失败: %v", err)
+		}
+		r.body = data
+		r.contentType = mime.TypeByExtension(filepath.Ext(path))
+		if r.contentType == "" {
+			r.contentType = http.DetectContentType(data)
+		}
+	default:
+		body, contentType, err := encodeBody(r.Body)
+		if err != nil {
+			return err
+		}
+		r.body = body
+		r.contentType = contentType
+	}
+
+	total := 0.0
+	for i, fault := range r.Faults {
+		if fault == nil {
+			return fmt.Errorf("第%d个故障为空", i+1)
+		}
+		if err := fault.compile(); err != nil {
+			return fmt.Errorf("第%d个故障: %v", i+1, err)
+		}
+		total += fault.Probability
+	}
+	if total > 1.000001 {
+		return fmt.Errorf("故障概率之和%.3f超过1", total)
+	}
+	return nil
+}
+
+// compile 校验故障配置
+func (f *Fault) compile() error {
+	if f.Probability < 0 || f.Probability > 1 {
+		return fmt.Errorf("probability必须在0到1之间: %v", f.Probability)
+	}
+	switch f.Type {
+	case FaultStatus:
+		if f.Status == 0 {
+			f.Status = http.StatusInternalServerError
+		}
+		if f.Status < 100 || f.Status > 999 {
+			return fmt.Errorf("无效的状态码: %d", f.Status)
+		}
+	case FaultDelay, FaultReset, FaultEmpty, FaultHang, FaultTruncate:
+	default:
+		return fmt.Errorf("未知的故障类型: %s（支持status、delay、reset、empty、hang、truncate）", f.Type)
+	}
+	if f.Delay < 0 {
+		return errors.New("delay不能为负数")
+	}
+	body, contentType, err := encodeBody(f.Body)
+	if err != nil {
+		return err
+	}
+	f.body = body
+	f.contentType = contentType
+	return nil
+}
+
+// encodeBody 字符串原样使用，其他结构序列化为JSON，返回默认Content-Type
+func encodeBody(body interface{}) ([]byte, string, error) {
+	switch value := body.(type) {
+	case nil:
+		return nil, "", nil
+	case string:
+		return []byte(value), "text/plain; charset=utf-8", nil
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, "", fmt.Errorf("body无法序列化为JSON: %v", err)
+		}
+		return data, "application/json; charset=utf-8", nil
+	}
+}
@@ -0,0 +1,340 @@
+package mock
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mockPrefix 模拟接口的路径前缀
+const mockPrefix = "/mock"
+
+// MockModule 声明式模拟接口模块，初始路由来自启动时加载的YAML/JSON配置，
+// 运行时可通过/admin/mocks增删改
+type MockModule struct {
+	Config *Config // 初始路由，为nil时没有模拟路由
+	Source string  // 配置文件路径，仅用于显示
+
+	lock   sync.RWMutex
+	routes []*Route // 按优先级排序的当前路由
+	stats  map[*Route]*RouteStats
+	nextID int
+}
+
+// RouteStats 路由命中统计
+type RouteStats struct {
+	Hits   int64            `json:"hits"`
+	Faults map[string]int64 `json:"faults"`
+}
+
+// RegisterRoutes 注册路由
+func (m *MockModule) RegisterRoutes(r *gin.Engine) {
+	if m.Config == nil {
+		m.Config = &Config{}
+	}
+	m.replace(m.Config.Routes)
+
+	// gin的路由注册后不能再修改，统一由一个处理函数查找当前路由表
+	r.Any(mockPrefix+"/*path", m.handleMock)
+
+	api := r.Group("/api/mock")
+	{
+		api.GET("/routes", m.handleRoutes)
+	}
+
+	// 运行时管理接口
+	admin := r.Group("/admin/mocks")
+	{
+		admin.GET("", m.handleRoutes)
+		admin.POST("", m.handleCreate)
+		admin.PUT("", m.handleReplace)
+		admin.DELETE("", m.handleClear)
+		admin.GET("/:id", m.handleGet)
+		admin.PUT("/:id", m.handleUpdate)
+		admin.DELETE("/:id", m.handleDelete)
+	}
+}
+
+// GetPrefix 获取前缀
+func (m *MockModule) GetPrefix() string {
+	return mockPrefix
+}
+
+// GetDescription 获取描述
+func (m *MockModule) GetDescription() string {
+	return "由YAML/JSON配置或运行时管理接口定义的模拟接口，支持自定义状态码、头部、消息体、延迟和概率故障"
+}
+
+// match 按顺序查找匹配的路由，路径和条件匹配但方法不匹配时返回允许的方法
+func (m *MockModule) match(r *http.Request, path string) (*Route, []string) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	allowed := make([]string, 0)
+	for _, route := range m.routes {
+		if !matchPath(route.segments, segments) || !route.Match.matches(r) {
+			continue
+		}
+		if route.allows(r.Method) {
+			return route, nil
+		}
+		allowed = append(allowed, route.methods...)
+	}
+	return nil, allowed
+}
+
+// matches 判断查询参数和请求头是否满足条件，没有条件时总是满足
+func (m *Match) matches(r *http.Request) bool {
+	if m == nil {
+		return true
+	}
+	query := r.URL.Query()
+	for name, want := range m.Query {
+		values, ok := query[name]
+		if !ok || !matchValue(values, want) {
+			return false
+		}
+	}
+	for name, want := range m.Headers {
+		values := r.Header.Values(name)
+		if len(values) == 0 || !matchValue(values, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchValue "*"只要求存在，否则任一取值完全相等即可
+func matchValue(values []string, want string) bool {
+	if want == "*" {
+		return true
+	}
+	for _, value := range values {
+		if value == want {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath 匹配路径段，:name 匹配任意非空的单段，*name 匹配剩余所有段
+func matchPath(pattern, segments []string) bool {
+	for i, part := range pattern {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if strings.HasPrefix(part, ":") {
+			if segments[i] == "" {
+				return false
+			}
+		} else if part != segments[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(segments)
+}
+
+// allows 判断路由是否接受该方法，GET路由同时接受HEAD
+func (r *Route) allows(method string) bool {
+	if len(r.methods) == 0 {
+		return true
+	}
+	for _, allowed := range r.methods {
+		if allowed == method || (allowed == http.MethodGet && method == http.MethodHead) {
+			return true
+		}
+	}
+	return false
+}
+
+// 模拟接口处理
+func (m *MockModule) handleMock(c *gin.Context) {
+	route, allowed := m.match(c.Request, c.Param("path"))
+	if route == nil {
+		if len(allowed) > 0 {
+			c.Header("Allow", strings.Join(allowed, ", "))
+			response := routes.CreateErrorResponse(405, "模拟接口不支持该方法: "+c.Request.Method)
+			c.JSON(http.StatusMethodNotAllowed, response)
+			return
+		}
+		response := routes.CreateErrorResponse(404, "未定义的模拟接口: "+c.Request.URL.Path)
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+	fault := route.pickFault()
+	m.record(route, fault)
+
+	delay := route.delay()
+	if fault != nil && fault.Type == FaultDelay {
+		delay += time.Duration(fault.Delay)
+	}
+	if !sleep(c, delay) {
+		return
+	}
+
+	if fault != nil && fault.Type != FaultDelay {
+		applyFault(c, route, fault)
+		return
+	}
+	writeResponse(c, route.Status, route.Headers, route.contentType, route.body)
+}
+
+// pickFault 按概率选出本次触发的故障，没有触发时返回nil
+func (r *Route) pickFault() *Fault {
+	if len(r.Faults) == 0 {
+		return nil
+	}
+	roll := rand.Float64()
+	for _, fault := range r.Faults {
+		if roll < fault.Probability {
+			return fault
+		}
+		roll -= fault.Probability
+	}
+	return nil
+}
+
+// delay 本次响应的延迟
+func (r *Route) delay() time.Duration {
+	low, high := time.Duration(r.Delay), time.Duration(r.DelayMax)
+	if high <= low {
+		return low
+	}
+	return low + time.Duration(rand.Int63n(int64(high-low)))
+}
+
+// sleep 等待指定时间，客户端断开时返回false
+func sleep(c *gin.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
+
+// setHeaders 设置配置的头部，未配置Content-Type时使用根据消息体推断的类型
+func setHeaders(header http.Header, headers HeaderValues, contentType string) {
+	for name, values := range headers {
+		header.Del(name)
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+	if header.Get("Content-Type") == "" && contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+}
+
+// writeResponse 按配置输出响应
+func writeResponse(c *gin.Context, status int, headers HeaderValues, contentType string, body []byte) {
+	header := c.Writer.Header()
+	setHeaders(header, headers, contentType)
+	if header.Get("Content-Length") == "" && header.Get("Transfer-Encoding") == "" {
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	c.Status(status)
+	if len(body) == 0 {
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	_, _ = c.Writer.Write(body)
+}
+
+// applyFault 执行故障
+func applyFault(c *gin.Context, route *Route, fault *Fault) {
+	switch fault.Type {
+	case FaultStatus:
+		writeResponse(c, fault.Status, fault.Headers, fault.contentType, fault.body)
+
+	case FaultReset, FaultEmpty:
+		closeConnection(c, fault.Type == FaultReset)
+
+	case FaultHang:
+		wait := time.Duration(fault.Delay)
+		if wait == 0 {
+			wait = defaultHang
+		}
+		if sleep(c, wait) {
+			closeConnection(c, false)
+		}
+
+	case FaultTruncate:
+		// 声明完整长度，只发送前一半，然后断开连接
+		header := c.Writer.Header()
+		setHeaders(header, route.Headers, route.contentType)
+		header.Set("Content-Length", strconv.Itoa(len(route.body)))
+		c.Status(route.Status)
+		_, _ = c.Writer.Write(route.body[:len(route.body)/2])
+		c.Writer.Flush()
+		closeConnection(c, false)
+	}
+}
+
+// closeConnection 接管并关闭连接，reset为true时发送RST
+// 无法接管连接时（HTTP/2、测试环境）退回502，便于发现故障未能按预期模拟
+func closeConnection(c *gin.Context, reset bool) {
+	if err := routes.CloseConnection(c.Writer, reset); err != nil && !c.Writer.Written() {
+		response := routes.CreateErrorResponse(502, "无法接管连接模拟故障: "+err.Error())
+		c.JSON(http.StatusBadGateway, response)
+	}
+}
+
+// record 记录命中和触发的故障
+func (m *MockModule) record(route *Route, fault *Fault) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	stats, ok := m.stats[route]
+	if !ok {
+		// 路由已在处理期间被删除或替换
+		return
+	}
+	stats.Hits++
+	if fault != nil {
+		stats.Faults[fault.Type]++
+	}
+}
+
+// describe 路由及其命中统计，调用方需持有锁
+func (m *MockModule) describe(route *Route) map[string]interface{} {
+	return map[string]interface{}{
+		"id":    route.ID,
+		"route": route,
+		"url":   mockPrefix + route.Path,
+		"stats": m.stats[route],
+	}
+}
+
+// 当前的模拟路由和命中统计
+func (m *MockModule) handleRoutes(c *gin.Context) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	list := make([]map[string]interface{}, 0, len(m.routes))
+	for _, route := range m.routes {
+		list = append(list, m.describe(route))
+	}
+
+	response := routes.CreateSuccessResponse("模拟路由", map[string]interface{}{
+		"source": m.Source,
+		"routes": list,
+		"count":  len(list),
+	})
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,216 @@
+package raw
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCaptureSize 单个连接缓存的原始字节上限，超出后该连接停止捕获
+const maxCaptureSize = 32 * 1024 * 1024
+
+var (
+	// ErrNotCaptured 当前请求没有可用的原始字节（未使用捕获监听器、TLS连接或已超出上限）
+	ErrNotCaptured = errors.New("当前连接未启用原始字节捕获")
+
+	connCounter int64
+)
+
+// captureListener 包装监听器，记录每个连接读取到的原始字节
+type captureListener struct {
+	net.Listener
+}
+
+// NewCaptureListener 创建捕获监听器，需配合http.Server.ConnContext = routes.ConnContext使用
+// 捕获发生在net/http解析之前，因此能保留请求行、头部顺序与大小写以及分块结构
+func NewCaptureListener(ln net.Listener) net.Listener {
+	return &captureListener{Listener: ln}
+}
+
+// Accept 接受连接并包装为captureConn
+func (l *captureListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	id := atomic.AddInt64(&connCounter, 1)
+	openReport(id, conn.RemoteAddr().String())
+	return &captureConn{
+		Conn:    conn,
+		id:      id,
+		enabled: true,
+	}, nil
+}
+
+// captureConn 将读取的数据同时写入缓冲区
+type captureConn struct {
+	net.Conn
+	id int64
+
+	mu       sync.Mutex
+	buffer   []byte
+	enabled  bool
+	requests int
+	inFlight bool // 请求正在由处理函数处理
+}
+
+// NetConn 返回被包装的连接，与tls.Conn.NetConn一致
+func (c *captureConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// Read 读取数据并记录到缓冲区
+func (c *captureConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.mu.Lock()
+		if c.enabled {
+			if len(c.buffer)+n > maxCaptureSize {
+				c.disableLocked()
+			} else {
+				c.buffer = append(c.buffer, p[:n]...)
+			}
+		}
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+// disableLocked 停止捕获，之后无法再确定请求边界
+func (c *captureConn) disableLocked() {
+	c.enabled = false
+	c.buffer = nil
+}
+
+// stop 停止捕获（协议升级或连接被接管时）
+func (c *captureConn) stop() {
+	c.mu.Lock()
+	c.disableLocked()
+	c.inFlight = false
+	c.mu.Unlock()
+}
+
+// begin 请求进入处理函数
+func (c *captureConn) begin() {
+	c.mu.Lock()
+	c.inFlight = true
+	c.mu.Unlock()
+}
+
+// Close 关闭连接，缓冲区中仍有未交给处理函数的字节时记录下来：
+// 这些字节被net/http拒绝（如非法的分帧头）或是不完整的请求，常见于请求走私
+func (c *captureConn) Close() error {
+	c.mu.Lock()
+	if c.enabled && !c.inFlight && len(c.buffer) > 0 {
+		addRecord(c.id, unprocessedRecord(c.requests+1, c.buffer, "连接关闭时仍有未处理的字节"))
+		c.buffer = c.buffer[:0]
+	}
+	c.mu.Unlock()
+	closeReport(c.id)
+	return c.Conn.Close()
+}
+
+// current 解析缓冲区中的第一个请求
+func (c *captureConn) current() (*RawRequest, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.enabled {
+		return nil, ErrNotCaptured
+	}
+	data := make([]byte, len(c.buffer))
+	copy(data, c.buffer)
+	req, _, err := ParseRequest(data)
+	return req, err
+}
+
+// consume 请求处理完成后记录分帧情况并移除该请求的字节，剩余部分属于后续（管线化）请求
+func (c *captureConn) consume(status int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inFlight = false
+	if !c.enabled {
+		return
+	}
+	c.requests++
+	req, consumed, err := ParseRequest(c.buffer)
+	if err != nil {
+		// 无法确定请求边界，后续请求的捕获将不可靠
+		addRecord(c.id, unprocessedRecord(c.requests, c.buffer, "无法确定请求边界: "+err.Error()))
+		c.disableLocked()
+		return
+	}
+	record := frameRecord(c.requests, req)
+	record.Status = status
+	addRecord(c.id, record)
+	c.buffer = append(c.buffer[:0], c.buffer[consumed:]...)
+}
+
+// requestIndex 当前请求在连接上的序号（从1开始）
+func (c *captureConn) requestIndex() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests + 1
+}
+
+// connFromContext 获取请求对应的captureConn
+func connFromContext(c *gin.Context) *captureConn {
+	conn, _ := routes.ConnFromContext(c.Request.Context()).(*captureConn)
+	return conn
+}
+
+// Capture 原始请求捕获中间件，请求结束后读完剩余消息体并移除已处理的字节
+func Capture() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conn := connFromContext(c)
+		if conn == nil {
+			c.Next()
+			return
+		}
+
+		// 升级后的连接不再是HTTP报文
+		if c.GetHeader("Upgrade") != "" {
+			conn.stop()
+			c.Next()
+			return
+		}
+
+		conn.begin()
+		c.Next()
+
+		// 处理函数未读完的消息体仍需读入缓冲区才能找到下一个请求的起点
+		drained, err := io.Copy(io.Discard, io.LimitReader(c.Request.Body, maxCaptureSize))
+		if err != nil || drained == maxCaptureSize {
+			conn.stop()
+			return
+		}
+		conn.consume(c.Writer.Status())
+		if strings.EqualFold(c.Writer.Header().Get("Connection"), "close") {
+			conn.stop()
+		}
+	}
+}
+
+// Current 获取当前请求的原始字节，调用前需读完请求体
+func Current(c *gin.Context) (*RawRequest, error) {
+	conn := connFromContext(c)
+	if conn == nil {
+		return nil, ErrNotCaptured
+	}
+	return conn.current()
+}
+
+// ConnInfo 返回当前连接的ID和请求序号，未启用捕获时返回false
+func ConnInfo(c *gin.Context) (int64, int, bool) {
+	conn := connFromContext(c)
+	if conn == nil {
+		return 0, 0, false
+	}
+	return conn.id, conn.requestIndex(), true
+}
@@ -0,0 +1,213 @@
+package raw
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// desyncTarget 走私用例的目标路径
+const desyncTarget = "/api/raw/desync"
+
+// DesyncCase 请求走私用例，Raw通过代理发送后再在同一连接上发送FollowUp
+type DesyncCase struct {
+	Name        string `json:"name"`
+	Category    string `json:"category"` // CL.TE、TE.CL、TE.TE或CL.CL
+	Description string `json:"description"`
+	Expect      string `json:"expect"` // 存在漏洞时服务器端报告中的表现
+	Raw         string `json:"raw"`
+	FollowUp    string `json:"follow_up"`
+}
+
+// smuggledPrefix CL.TE用例留在服务器连接上的前缀，与下一个请求拼成GGET、GPOST
+const smuggledPrefix = "G"
+
+// clteCase 以CL.TE结构构造用例：代理按Content-Length转发"0\r\n\r\nG"，
+// 服务器按分块在0处结束，剩下的G成为下一个请求的前缀
+func clteCase(name, category, description, transferEncoding string) DesyncCase {
+	body := "0\r\n\r\n" + smuggledPrefix
+	return DesyncCase{
+		Name:        name,
+		Category:    category,
+		Description: description,
+		Expect:      "后续请求出现prefixed（方法为" + smuggledPrefix + "GET）说明代理按Content-Length转发而服务器按分块解析；出现unprocessed说明代理原样转发了服务器拒绝的分帧头",
+		Raw: "POST " + desyncTarget + "?case=" + name + " HTTP/1.1\r\n" +
+			"Host: {host}\r\n" +
+			"Content-Type: application/x-www-form-urlencoded\r\n" +
+			"Content-Length: " + strconv.Itoa(len(body)) + "\r\n" +
+			transferEncoding + "\r\n" +
+			"\r\n" + body,
+	}
+}
+
+// tecl TE.CL用例：代理按分块转发，服务器若按Content-Length只读4字节，分块内容成为下一个请求
+func teclCase() DesyncCase {
+	smuggled := "GPOST " + desyncTarget + "?case=te-cl&smuggled=1 HTTP/1.1\r\n" +
+		"Content-Type: application/x-www-form-urlencoded\r\n" +
+		"Content-Length: 15\r\n" +
+		"\r\n" +
+		"x=1"
+	return DesyncCase{
+		Name:        "te-cl",
+		Category:    "TE.CL",
+		Description: "代理按Transfer-Encoding解析，服务器按Content-Length解析",
+		Expect:      "出现方法为GPOST的prefixed请求说明服务器侧按Content-Length解析；Go服务器优先使用分块，正常情况下只会看到content_length_and_chunked",
+		Raw: "POST " + desyncTarget + "?case=te-cl HTTP/1.1\r\n" +
+			"Host: {host}\r\n" +
+			"Content-Type: application/x-www-form-urlencoded\r\n" +
+			"Content-Length: 4\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			strconv.FormatInt(int64(len(smuggled)), 16) + "\r\n" + smuggled + "\r\n" +
+			"0\r\n\r\n",
+	}
+}
+
+// clclCase CL.CL用例：两个不同的Content-Length
+func clclCase() DesyncCase {
+	return DesyncCase{
+		Name:        "cl-cl",
+		Category:    "CL.CL",
+		Description: "两个取值不同的Content-Length，代理和服务器可能各取其一",
+		Expect:      "代理应直接拒绝；出现unprocessed说明代理原样转发，prefixed说明代理取了较大的值",
+		Raw: "POST " + desyncTarget + "?case=cl-cl HTTP/1.1\r\n" +
+			"Host: {host}\r\n" +
+			"Content-Type: application/x-www-form-urlencoded\r\n" +
+			"Content-Length: 6\r\n" +
+			"Content-Length: 5\r\n" +
+			"\r\n" +
+			"x=1&y" + smuggledPrefix,
+	}
+}
+
+// DesyncCases 所有走私用例
+var DesyncCases = []DesyncCase{
+	clteCase("cl-te", "CL.TE", "代理按Content-Length解析，服务器按Transfer-Encoding解析", "Transfer-Encoding: chunked"),
+	teclCase(),
+	clteCase("te-te-xchunked", "TE.TE", "未知编码xchunked，按子串判断chunked的实现会误认为分块", "Transfer-Encoding: xchunked"),
+	clteCase("te-te-space-before-colon", "TE.TE", "头部名称与冒号之间有空格", "Transfer-Encoding : chunked"),
+	clteCase("te-te-tab", "TE.TE", "以制表符分隔的取值", "Transfer-Encoding:\tchunked"),
+	clteCase("te-te-duplicate", "TE.TE", "两个Transfer-Encoding头，第二个为无效编码", "Transfer-Encoding: chunked\r\nTransfer-Encoding: x"),
+	clteCase("te-te-obs-fold", "TE.TE", "取值写在续行中", "Transfer-Encoding:\r\n chunked"),
+	clteCase("te-te-identity", "TE.TE", "chunked后跟identity，最后一个编码不是chunked", "Transfer-Encoding: chunked, identity"),
+	clteCase("te-te-case", "TE.TE", "大写的CHUNKED", "Transfer-Encoding: CHUNKED"),
+	clteCase("te-te-quoted", "TE.TE", "带引号的取值", `Transfer-Encoding: "chunked"`),
+	clclCase(),
+}
+
+// followUp 在同一连接上发送的正常请求，被污染时会带上残留前缀
+func followUp(name string) string {
+	return "GET " + desyncTarget + "?case=" + name + "&follow_up=1 HTTP/1.1\r\nHost: {host}\r\n\r\n"
+}
+
+// 连接感知的走私探测接口，返回本次请求的分帧和所在连接此前的记录
+func handleDesync(c *gin.Context) {
+	if _, err := io.Copy(io.Discard, c.Request.Body); err != nil {
+		response := routes.CreateErrorResponse(400, "读取请求体失败: "+err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	captured, err := Current(c)
+	if errors.Is(err, ErrNotCaptured) {
+		response := routes.CreateErrorResponse(501, "分帧记录不可用: 需通过捕获监听器以明文HTTP/1.x访问")
+		c.JSON(http.StatusNotImplemented, response)
+		return
+	}
+	if err != nil {
+		response := routes.CreateErrorResponse(500, "解析原始请求失败: "+err.Error())
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	connID, index, _ := ConnInfo(c)
+	report, _ := Report(connID)
+	current := frameRecord(index, captured)
+
+	response := routes.CreateSuccessResponse("请求分帧", map[string]interface{}{
+		"conn_id":    connID,
+		"current":    current,
+		"previous":   report.Requests,
+		"findings":   report.Findings,
+		"suspicious": report.Suspicious || containsString(current.Flags, FlagPrefixed),
+		"report_url": fmt.Sprintf("/api/raw/connections/%d", connID),
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// 走私用例，host参数替换请求中的Host，默认为本次请求的Host
+func handleDesyncCases(c *gin.Context) {
+	host := c.DefaultQuery("host", c.Request.Host)
+	cases := make([]DesyncCase, 0, len(DesyncCases))
+	for _, item := range DesyncCases {
+		item.Raw = strings.ReplaceAll(item.Raw, "{host}", host)
+		item.FollowUp = strings.ReplaceAll(followUp(item.Name), "{host}", host)
+		cases = append(cases, item)
+	}
+
+	response := routes.CreateSuccessResponse("请求走私用例", map[string]interface{}{
+		"cases": cases,
+		"count": len(cases),
+		"usage": "通过代理在同一连接上依次发送raw和follow_up（如 printf '<raw><follow_up>' | nc 代理地址 端口），然后查看GET /api/raw/connections?suspicious=1",
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// 连接报告列表，suspicious=1只返回可疑连接
+func handleConnections(c *gin.Context) {
+	onlySuspicious := c.Query("suspicious") == "1" || c.Query("suspicious") == "true"
+	summaries := make([]map[string]interface{}, 0)
+	for _, report := range Reports() {
+		if onlySuspicious && !report.Suspicious {
+			continue
+		}
+		summaries = append(summaries, map[string]interface{}{
+			"conn_id":     report.ID,
+			"remote_addr": report.RemoteAddr,
+			"opened_at":   report.OpenedAt,
+			"closed_at":   report.ClosedAt,
+			"requests":    len(report.Requests) + report.Dropped,
+			"findings":    report.Findings,
+			"suspicious":  report.Suspicious,
+		})
+	}
+
+	response := routes.CreateSuccessResponse("连接分帧报告", map[string]interface{}{
+		"connections": summaries,
+		"count":       len(summaries),
+		"capacity":    maxConnReports,
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// 单个连接的分帧报告
+func handleConnection(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response := routes.CreateErrorResponse(400, "无效的连接ID: "+c.Param("id"))
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	report, ok := Report(id)
+	if !ok {
+		response := routes.CreateErrorResponse(404, "连接不存在或已被淘汰: "+c.Param("id"))
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	response := routes.CreateSuccessResponse("连接分帧报告", report)
+	c.JSON(http.StatusOK, response)
+}
+
+// 删除已关闭连接的报告
+func handleClearConnections(c *gin.Context) {
+	response := routes.CreateSuccessResponse("已清除关闭的连接报告", map[string]interface{}{"cleared": ClearReports()})
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,315 @@
+package raw
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxConnReports  = 256  // 保留的连接报告数，超出时淘汰最早的连接
+	maxConnRequests = 200  // 单个连接保留的请求记录数
+	maxPrefixSize   = 1024 // 记录的前缀和未处理字节上限
+)
+
+// 分帧异常标记
+const (
+	FlagPrefixed         = "prefixed"                     // 请求前带有不属于该请求的字节（如上一个请求的剩余消息体）
+	FlagUnknownMethod    = "unknown_method"               // 方法不是标准方法
+	FlagCLAndTE          = "content_length_and_chunked"   // 同时带有Content-Length和Transfer-Encoding
+	FlagMultipleCL       = "multiple_content_length"      // 多个Content-Length头
+	FlagMultipleTE       = "multiple_transfer_encoding"   // 多个Transfer-Encoding头
+	FlagObfuscatedTE     = "obfuscated_transfer_encoding" // Transfer-Encoding的写法不规范，不同实现可能理解不同
+	FlagHeaderWhitespace = "header_name_whitespace"       // 头部名称前后有空白
+	FlagObsFold          = "obs_fold"                     // 头部续行
+	FlagBareLF           = "bare_lf"                      // 行尾不是CRLF
+	FlagEmbeddedRequest  = "embedded_request"             // 消息体中包含请求行
+	FlagUnprocessed      = "unprocessed"                  // 连接关闭时仍有未处理的字节（被net/http拒绝或不完整）
+)
+
+// suspiciousFlags 出现即说明代理和服务器对请求边界的理解可能不一致
+var suspiciousFlags = map[string]bool{
+	FlagPrefixed:    true,
+	FlagUnprocessed: true,
+}
+
+// standardMethods 标准方法，用于识别被前缀污染的方法名（如GPOST）
+var standardMethods = []string{"GET", "HEAD", "POST", "PUT", "DELETE", "CONNECT", "OPTIONS", "TRACE", "PATCH", "PRI"}
+
+// transferCodings 已注册的传输编码
+var transferCodings = map[string]bool{"chunked": true, "compress": true, "deflate": true, "gzip": true, "identity": true}
+
+// requestLinePattern 匹配位于行首的请求行
+var requestLinePattern = regexp.MustCompile(`(?m)^(GET|HEAD|POST|PUT|DELETE|CONNECT|OPTIONS|TRACE|PATCH) \S+ HTTP/1\.[01]\r?$`)
+
+// FrameRecord 连接上一个请求的分帧情况
+type FrameRecord struct {
+	Index             int       `json:"index"`
+	Time              time.Time `json:"time"`
+	RequestLine       string    `json:"request_line,omitempty"`
+	Method            string    `json:"method,omitempty"`
+	Target            string    `json:"target,omitempty"`
+	Framing           string    `json:"framing,omitempty"`
+	ContentLength     int64     `json:"content_length"`
+	ContentLengths    []string  `json:"content_length_headers,omitempty"`    // 原始行
+	TransferEncodings []string  `json:"transfer_encoding_headers,omitempty"` // 原始行
+	HeadSize          int       `json:"head_size"`
+	BodySize          int       `json:"body_wire_size"`
+	Status            int       `json:"status,omitempty"`
+	Flags             []string  `json:"flags"`
+	Prefix            string    `json:"prefix,omitempty"`
+	Unprocessed       string    `json:"unprocessed,omitempty"`
+	Error             string    `json:"error,omitempty"`
+}
+
+// ConnReport 一个连接上所有请求的分帧报告
+type ConnReport struct {
+	ID         int64         `json:"conn_id"`
+	RemoteAddr string        `json:"remote_addr"`
+	OpenedAt   time.Time     `json:"opened_at"`
+	ClosedAt   *time.Time    `json:"closed_at,omitempty"`
+	Requests   []FrameRecord `json:"requests"`
+	Dropped    int           `json:"dropped"` // 超出上限未保留的记录数
+	Findings   []string      `json:"findings"`
+	Suspicious bool          `json:"suspicious"`
+}
+
+var (
+	reportLock  sync.Mutex
+	reports     = make(map[int64]*ConnReport)
+	reportOrder []int64
+)
+
+// openReport 新连接建立时创建报告
+func openReport(id int64, remoteAddr string) {
+	reportLock.Lock()
+	defer reportLock.Unlock()
+
+	reports[id] = &ConnReport{ID: id, RemoteAddr: remoteAddr, OpenedAt: time.Now(), Requests: make([]FrameRecord, 0), Findings: make([]string, 0)}
+	reportOrder = append(reportOrder, id)
+	for len(reportOrder) > maxConnReports {
+		delete(reports, reportOrder[0])
+		reportOrder = reportOrder[1:]
+	}
+}
+
+// closeReport 连接关闭时记录时间
+func closeReport(id int64) {
+	reportLock.Lock()
+	defer reportLock.Unlock()
+	if report, ok := reports[id]; ok && report.ClosedAt == nil {
+		now := time.Now()
+		report.ClosedAt = &now
+	}
+}
+
+// addRecord 追加请求记录并更新连接的发现
+func addRecord(id int64, record FrameRecord) {
+	reportLock.Lock()
+	defer reportLock.Unlock()
+	report, ok := reports[id]
+	if !ok {
+		return
+	}
+	if len(report.Requests) >= maxConnRequests {
+		report.Dropped++
+	} else {
+		report.Requests = append(report.Requests, record)
+	}
+	for _, flag := range record.Flags {
+		if !containsString(report.Findings, flag) {
+			report.Findings = append(report.Findings, flag)
+			sort.Strings(report.Findings)
+		}
+		if suspiciousFlags[flag] {
+			report.Suspicious = true
+		}
+	}
+}
+
+// Reports 按连接ID从新到旧返回报告副本
+func Reports() []ConnReport {
+	reportLock.Lock()
+	defer reportLock.Unlock()
+	list := make([]ConnReport, 0, len(reportOrder))
+	for i := len(reportOrder) - 1; i >= 0; i-- {
+		list = append(list, copyReport(reports[reportOrder[i]]))
+	}
+	return list
+}
+
+// Report 单个连接的报告副本
+func Report(id int64) (ConnReport, bool) {
+	reportLock.Lock()
+	defer reportLock.Unlock()
+	report, ok := reports[id]
+	if !ok {
+		return ConnReport{}, false
+	}
+	return copyReport(report), true
+}
+
+// ClearReports 删除已关闭连接的报告，返回删除数量
+func ClearReports() int {
+	reportLock.Lock()
+	defer reportLock.Unlock()
+	kept := reportOrder[:0]
+	cleared := 0
+	for _, id := range reportOrder {
+		if reports[id].ClosedAt != nil {
+			delete(reports, id)
+			cleared++
+			continue
+		}
+		kept = append(kept, id)
+	}
+	reportOrder = kept
+	return cleared
+}
+
+// copyReport 复制报告，避免返回后被并发修改
+func copyReport(report *ConnReport) ConnReport {
+	copied := *report
+	copied.Requests = append([]FrameRecord(nil), report.Requests...)
+	copied.Findings = append([]string(nil), report.Findings...)
+	return copied
+}
+
+// frameRecord 根据解析出的请求生成记录
+func frameRecord(index int, req *RawRequest) FrameRecord {
+	record := FrameRecord{
+		Index:         index,
+		Time:          time.Now(),
+		RequestLine:   req.RequestLine,
+		Method:        req.Method,
+		Target:        req.Target,
+		Framing:       req.Framing,
+		ContentLength: req.ContentLength,
+		HeadSize:      req.HeadSize,
+		BodySize:      req.BodySize,
+		Flags:         make([]string, 0),
+	}
+	flag := func(name string) {
+		if !containsString(record.Flags, name) {
+			record.Flags = append(record.Flags, name)
+		}
+	}
+
+	if !containsString(standardMethods, req.Method) {
+		flag(FlagUnknownMethod)
+		for _, method := range standardMethods {
+			if len(req.Method) > len(method) && strings.HasSuffix(req.Method, method) {
+				flag(FlagPrefixed)
+				record.Prefix = req.Method[:len(req.Method)-len(method)]
+				break
+			}
+		}
+	}
+
+	for _, header := range req.Headers {
+		name := strings.TrimSpace(header.Name)
+		if name != header.Name {
+			flag(FlagHeaderWhitespace)
+		}
+		if strings.Contains(header.Line, "\n") {
+			flag(FlagObsFold)
+		}
+		switch strings.ToLower(name) {
+		case "content-length":
+			record.ContentLengths = append(record.ContentLengths, header.Line)
+		case "transfer-encoding":
+			record.TransferEncodings = append(record.TransferEncodings, header.Line)
+			if obfuscatedTE(header) {
+				flag(FlagObfuscatedTE)
+			}
+		}
+	}
+	if len(record.ContentLengths) > 0 && len(record.TransferEncodings) > 0 {
+		flag(FlagCLAndTE)
+	}
+	if len(record.ContentLengths) > 1 {
+		flag(FlagMultipleCL)
+	}
+	if len(record.TransferEncodings) > 1 {
+		flag(FlagMultipleTE)
+	}
+	if req.LineEnding != "CRLF" {
+		flag(FlagBareLF)
+	}
+	if requestLinePattern.Match(req.Body) {
+		flag(FlagEmbeddedRequest)
+	}
+	return record
+}
+
+// obfuscatedTE 判断Transfer-Encoding是否为不规范写法：名称带空白、续行、
+// 引号或制表符、未注册的编码、大小写不一致或最后一个编码不是chunked
+func obfuscatedTE(header RawHeader) bool {
+	if strings.TrimSpace(header.Name) != header.Name || strings.ContainsAny(header.Line, "\n\t\"") {
+		return true
+	}
+	codings := strings.Split(header.Value, ",")
+	for _, coding := range codings {
+		coding = strings.TrimSpace(coding)
+		if !transferCodings[coding] {
+			return true
+		}
+	}
+	return strings.TrimSpace(codings[len(codings)-1]) != "chunked"
+}
+
+// unprocessedRecord 连接关闭时缓冲区中剩余字节的记录
+func unprocessedRecord(index int, data []byte, reason string) FrameRecord {
+	record := FrameRecord{
+		Index:         index,
+		Time:          time.Now(),
+		ContentLength: -1,
+		Flags:         []string{FlagUnprocessed},
+		Unprocessed:   truncate(string(data)),
+		Error:         reason,
+	}
+	func() {
+		// 在net/http关闭连接时调用，那里没有recover，解析器的缺陷只能产生一条错误记录而不能使进程退出
+		// 这里的recover会掩盖解析器的panic，由FuzzParseRequest覆盖
+		defer func() {
+			if r := recover(); r != nil {
+				record.Error = fmt.Sprintf("%s（解析失败: %v）", reason, r)
+			}
+		}()
+		if req, _, err := ParseRequest(data); err == nil {
+			parsed := frameRecord(index, req)
+			parsed.Time = record.Time
+			parsed.Flags = append(parsed.Flags, FlagUnprocessed)
+			parsed.Unprocessed = record.Unprocessed
+			parsed.Error = reason
+			record = parsed
+		}
+	}()
+	// 未处理的字节中间出现请求行，说明前面的字节是残留的前缀
+	if loc := requestLinePattern.FindIndex(data); loc != nil && loc[0] > 0 && record.Prefix == "" {
+		record.Flags = append(record.Flags, FlagPrefixed)
+		record.Prefix = truncate(string(data[:loc[0]]))
+	}
+	return record
+}
+
+// truncate 截断过长的文本
+func truncate(text string) string {
+	if len(text) > maxPrefixSize {
+		return text[:maxPrefixSize]
+	}
+	return text
+}
+
+// containsString 判断切片是否包含字符串
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,243 @@
+package raw
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// errIncomplete 缓冲区中的数据还不足以构成完整请求
+var errIncomplete = errors.New("请求数据不完整")
+
+// RawHeader 按线上原样保留的请求头
+type RawHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Line  string `json:"line"` // 原始行（含obs-fold续行），不含行尾
+}
+
+// RawChunk 线上的一个分块
+type RawChunk struct {
+	Size       int64    `json:"size"`
+	SizeLine   string   `json:"size_line"`
+	Extensions []string `json:"extensions,omitempty"`
+}
+
+// RawRequest 从线上字节解析出的请求
+type RawRequest struct {
+	RequestLine   string      `json:"request_line"`
+	Method        string      `json:"method"`
+	Target        string      `json:"target"`
+	Version       string      `json:"version"`
+	Headers       []RawHeader `json:"headers"`
+	LineEnding    string      `json:"line_ending"` // CRLF、LF或mixed
+	LeadingBlanks int         `json:"leading_blank_lines"`
+	HeadSize      int         `json:"head_size"`
+	Framing       string      `json:"framing"` // content-length、chunked或none
+	ContentLength int64       `json:"content_length"`
+	Chunks        []RawChunk  `json:"chunks,omitempty"`
+	Trailers      []RawHeader `json:"trailers,omitempty"`
+	BodySize      int         `json:"body_wire_size"` // 线上消息体字节数，分块时包含分块标记
+	Body          []byte      `json:"-"`              // 去除分块后的消息体
+	Raw           []byte      `json:"-"`              // 请求的完整原始字节
+}
+
+// ParseRequest 从原始字节解析一个完整请求，返回该请求占用的字节数
+func ParseRequest(data []byte) (*RawRequest, int, error) {
+	req := &RawRequest{ContentLength: -1}
+	endings := make(map[string]bool)
+	pos := 0
+
+	// RFC 9112 允许请求行前出现空行
+	for {
+		line, next, ending, ok := readLine(data, pos)
+		if !ok {
+			return nil, 0, errIncomplete
+		}
+		if line != "" {
+			break
+		}
+		endings[ending] = true
+		req.LeadingBlanks++
+		pos = next
+	}
+
+	line, next, ending, _ := readLine(data, pos)
+	endings[ending] = true
+	req.RequestLine = line
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) != 3 {
+		return nil, 0, fmt.Errorf("无法解析请求行: %q", line)
+	}
+	req.Method, req.Target, req.Version = parts[0], parts[1], parts[2]
+	pos = next
+
+	headers, next, err := readHeaders(data, pos, endings)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Headers = headers
+	pos = next
+	req.HeadSize = pos
+
+	req.Framing = "none"
+	if te := lastHeader(req.Headers, "Transfer-Encoding"); te != "" {
+		codings := strings.Split(te, ",")
+		if strings.EqualFold(strings.TrimSpace(codings[len(codings)-1]), "chunked") {
+			req.Framing = "chunked"
+		}
+	}
+	if req.Framing == "none" {
+		if cl := lastHeader(req.Headers, "Content-Length"); cl != "" {
+			length, err := strconv.ParseInt(strings.TrimSpace(cl), 10, 64)
+			if err != nil || length < 0 {
+				return nil, 0, fmt.Errorf("非法的Content-Length: %q", cl)
+			}
+			req.Framing = "content-length"
+			req.ContentLength = length
+		}
+	}
+
+	switch req.Framing {
+	case "content-length":
+		// 先按int64比较，超大的Content-Length转换为int时会溢出
+		if req.ContentLength > int64(len(data)-pos) {
+			return nil, 0, errIncomplete
+		}
+		end := pos + int(req.ContentLength)
+		req.Body = data[pos:end]
+		pos = end
+	case "chunked":
+		pos, err = readChunkedBody(data, pos, req, endings)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	req.BodySize = pos - req.HeadSize
+	req.Raw = data[:pos]
+	switch {
+	case len(endings) > 1:
+		req.LineEnding = "mixed"
+	case endings["LF"]:
+		req.LineEnding = "LF"
+	default:
+		req.LineEnding = "CRLF"
+	}
+	return req, pos, nil
+}
+
+// readChunkedBody 解析分块消息体和trailer，返回消息体结束位置
+func readChunkedBody(data []byte, pos int, req *RawRequest, endings map[string]bool) (int, error) {
+	var body bytes.Buffer
+	for {
+		sizeLine, next, ending, ok := readLine(data, pos)
+		if !ok {
+			return 0, errIncomplete
+		}
+		endings[ending] = true
+
+		sizePart, extPart, _ := strings.Cut(sizeLine, ";")
+		size, err := strconv.ParseInt(strings.TrimSpace(sizePart), 16, 64)
+		if err != nil || size < 0 {
+			return 0, fmt.Errorf("非法的分块大小: %q", sizeLine)
+		}
+
+		chunk := RawChunk{Size: size, SizeLine: sizeLine}
+		if extPart != "" {
+			for _, ext := range strings.Split(extPart, ";") {
+				chunk.Extensions = append(chunk.Extensions, strings.TrimSpace(ext))
+			}
+		}
+		req.Chunks = append(req.Chunks, chunk)
+		pos = next
+
+		if size == 0 {
+			trailers, next, err := readHeaders(data, pos, endings)
+			if err != nil {
+				return 0, err
+			}
+			req.Trailers = trailers
+			req.Body = body.Bytes()
+			return next, nil
+		}
+
+		if size > int64(len(data)-pos) {
+			return 0, errIncomplete
+		}
+		end := pos + int(size)
+		body.Write(data[pos:end])
+
+		terminator, next, ending, ok := readLine(data, end)
+		if !ok {
+			return 0, errIncomplete
+		}
+		if terminator != "" {
+			return 0, fmt.Errorf("分块数据后缺少行尾，实际为: %q", terminator)
+		}
+		endings[ending] = true
+		pos = next
+	}
+}
+
+// readHeaders 读取头部字段直到空行，支持obs-fold续行
+func readHeaders(data []byte, pos int, endings map[string]bool) ([]RawHeader, int, error) {
+	headers := make([]RawHeader, 0)
+	for {
+		line, next, ending, ok := readLine(data, pos)
+		if !ok {
+			return nil, 0, errIncomplete
+		}
+		endings[ending] = true
+		pos = next
+		if line == "" {
+			return headers, pos, nil
+		}
+
+		if (line[0] == ' ' || line[0] == '\t') && len(headers) > 0 {
+			last := &headers[len(headers)-1]
+			last.Line += "\n" + line
+			last.Value += " " + strings.TrimSpace(line)
+			continue
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, 0, fmt.Errorf("无法解析头部字段: %q", line)
+		}
+		headers = append(headers, RawHeader{
+			Name:  name,
+			Value: strings.TrimSpace(value),
+			Line:  line,
+		})
+	}
+}
+
+// readLine 读取一行，返回不含行尾的内容、下一行起始位置和行尾类型
+func readLine(data []byte, pos int) (string, int, string, bool) {
+	if pos > len(data) {
+		return "", pos, "", false
+	}
+	index := bytes.IndexByte(data[pos:], '\n')
+	if index < 0 {
+		return "", pos, "", false
+	}
+	end := pos + index
+	if end > pos && data[end-1] == '\r' {
+		return string(data[pos : end-1]), end + 1, "CRLF", true
+	}
+	return string(data[pos:end]), end + 1, "LF", true
+}
+
+// lastHeader 获取最后一个同名头部的值（名称不区分大小写）
+func lastHeader(headers []RawHeader, name string) string {
+	value := ""
+	for _, header := range headers {
+		if strings.EqualFold(strings.TrimSpace(header.Name), name) {
+			value = header.Value
+		}
+	}
+	return value
+}
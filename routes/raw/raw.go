@@ -0,0 +1,97 @@
+package raw
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"unicode/utf8"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RawModule 原始请求捕获模块
+type RawModule struct{}
+
+// RegisterRoutes 注册路由
+func (m *RawModule) RegisterRoutes(r *gin.Engine) {
+	api := r.Group("/api/raw")
+	{
+		api.Any("/echo", handleRawEcho)
+		api.Any("/desync", handleDesync)
+		api.GET("/desync/cases", handleDesyncCases)
+		api.GET("/connections", handleConnections)
+		api.GET("/connections/:id", handleConnection)
+		api.DELETE("/connections", handleClearConnections)
+	}
+}
+
+// GetPrefix 获取前缀
+func (m *RawModule) GetPrefix() string {
+	return "/api/raw"
+}
+
+// GetDescription 获取描述
+func (m *RawModule) GetDescription() string {
+	return "原始请求字节捕获接口"
+}
+
+// 原始请求回显，返回代理实际转发到服务器的字节
+func handleRawEcho(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		response := routes.CreateErrorResponse(400, "读取请求体失败: "+err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	captured, err := Current(c)
+	if errors.Is(err, ErrNotCaptured) {
+		response := routes.CreateErrorResponse(501, "原始字节不可用: 需通过捕获监听器以明文HTTP/1.x访问，TLS和HTTP/2连接不支持")
+		c.JSON(http.StatusNotImplemented, response)
+		return
+	}
+	if err != nil {
+		response := routes.CreateErrorResponse(500, "解析原始请求失败: "+err.Error())
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	// format=raw 原样返回线上字节
+	if c.Query("format") == "raw" {
+		c.Data(http.StatusOK, "message/http", captured.Raw)
+		return
+	}
+
+	connID, index, _ := ConnInfo(c)
+	headerOrder := make([]string, 0, len(captured.Headers))
+	for _, header := range captured.Headers {
+		headerOrder = append(headerOrder, header.Name)
+	}
+
+	result := map[string]interface{}{
+		"conn_id":       connID,
+		"request_index": index,
+		"remote_addr":   c.Request.RemoteAddr,
+		"request":       captured,
+		"header_order":  headerOrder,
+		"body_size":     len(body),
+		"body":          printable(body),
+		"raw_size":      len(captured.Raw),
+		"raw_text":      printable(captured.Raw),
+		"raw_base64":    base64.StdEncoding.EncodeToString(captured.Raw),
+	}
+
+	response := routes.CreateSuccessResponse("原始请求捕获成功", result)
+	c.JSON(http.StatusOK, response)
+}
+
+// printable 非UTF-8内容不以文本返回，避免JSON中出现替换字符
+func printable(data []byte) string {
+	if !utf8.Valid(data) {
+		return ""
+	}
+	return string(data)
+}
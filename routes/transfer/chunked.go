@@ -1,7 +1,6 @@
 package transfer
 
 import (
-	"bufio"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,10 +9,17 @@ import (
 	"time"
 
 	"http_proxy_tool_test_web_demo/routes"
+	"http_proxy_tool_test_web_demo/routes/compression"
+	"http_proxy_tool_test_web_demo/routes/integrity"
+	"http_proxy_tool_test_web_demo/routes/raw"
+	"http_proxy_tool_test_web_demo/routes/websocket"
 
 	"github.com/gin-gonic/gin"
 )
 
+// maxLargeDecoded 大文件接收时解码后的大小上限，数据直接丢弃不占用内存
+const maxLargeDecoded = 4 * 1024 * 1024 * 1024
+
 // TransferModule 传输编码测试模块
 type TransferModule struct{}
 
@@ -27,6 +33,11 @@ func (m *TransferModule) RegisterRoutes(r *gin.Engine) {
 		api.GET("/chunked/stream", handleChunkedStream)
 		api.POST("/chunked/upload", handleChunkedUpload)
 
+		// trailer测试（HTTP/1.1分块和HTTP/2）
+		api.GET("/trailers", handleTrailerStream)
+		api.POST("/trailers/echo", handleTrailerEcho)
+		api.PUT("/trailers/echo", handleTrailerEcho)
+
 		// 传输编码测试
 		api.GET("/identity", handleIdentityTransfer)
 		api.GET("/deflate", handleDeflateTransfer)
@@ -34,6 +45,7 @@ func (m *TransferModule) RegisterRoutes(r *gin.Engine) {
 
 		// 大文件传输测试
 		api.GET("/large/:size", handleLargeTransfer)
+		api.HEAD("/large/:size", handleLargeTransfer)
 		api.POST("/large", handleLargeReceive)
 
 		// 流式传输测试
@@ -66,17 +78,10 @@ func handleChunkedTransfer(c *gin.Context) {
 		delay = 500
 	}
 
-	// 设置分块传输头
-	c.Header("Transfer-Encoding", "chunked")
-	c.Header("Content-Type", "application/json")
-	c.Status(http.StatusOK)
-
-	// 获取底层的ResponseWriter
-	w := c.Writer
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		response := routes.CreateErrorResponse(500, "不支持分块传输")
-		c.JSON(http.StatusInternalServerError, response)
+	// 接管连接写出真实的分块编码
+	emitter, err := startChunked(c, "application/json", parseChunkOptions(c))
+	if err != nil {
+		chunkedFailed(c, err)
 		return
 	}
 
@@ -94,12 +99,9 @@ func handleChunkedTransfer(c *gin.Context) {
 		chunkData := fmt.Sprintf(`{"chunk_id":%d,"timestamp":%d,"data":"这是第%d个分块数据","size":%d,"remaining":%d}`,
 			chunk["chunk_id"], chunk["timestamp"], i+1, chunk["size"], chunk["remaining"])
 
-		// 写入分块大小（十六进制）
-		fmt.Fprintf(w, "%x\r\n", len(chunkData))
-		// 写入分块数据
-		fmt.Fprintf(w, "%s\r\n", chunkData)
-
-		flusher.Flush()
+		if err := emitter.write([]byte(chunkData)); err != nil {
+			return
+		}
 
 		// 延迟发送下一个分块
 		if i < chunks-1 {
@@ -108,92 +110,46 @@ func handleChunkedTransfer(c *gin.Context) {
 	}
 
 	// 结束分块传输
-	fmt.Fprintf(w, "0\r\n\r\n")
-	flusher.Flush()
+	_ = emitter.finish()
 }
 
 // 分块接收测试
 func handleChunkedReceive(c *gin.Context) {
-	// 检查是否为分块传输
-	transferEncoding := c.GetHeader("Transfer-Encoding")
-	isChunked := strings.Contains(strings.ToLower(transferEncoding), "chunked")
+	// net/http已去除分块编码，分块信息从原始捕获中获取
+	isChunked := isChunkedRequest(c)
 
-	var receivedData strings.Builder
-	var chunks []map[string]interface{}
-	chunkCount := 0
-
-	if isChunked {
-		// 处理分块传输数据
-		reader := bufio.NewReader(c.Request.Body)
-
-		for {
-			// 读取分块大小
-			line, _, err := reader.ReadLine()
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
-				response := routes.CreateErrorResponse(400, "读取分块大小失败: "+err.Error())
-				c.JSON(http.StatusBadRequest, response)
-				return
-			}
-
-			// 解析分块大小
-			sizeStr := strings.TrimSpace(string(line))
-			if sizeStr == "" {
-				continue
-			}
-
-			size, err := strconv.ParseInt(sizeStr, 16, 64)
-			if err != nil {
-				response := routes.CreateErrorResponse(400, "解析分块大小失败: "+err.Error())
-				c.JSON(http.StatusBadRequest, response)
-				return
-			}
-
-			// 如果大小为0，表示传输结束
-			if size == 0 {
-				break
-			}
-
-			// 读取分块数据
-			chunkData := make([]byte, size)
-			_, err = io.ReadFull(reader, chunkData)
-			if err != nil {
-				response := routes.CreateErrorResponse(400, "读取分块数据失败: "+err.Error())
-				c.JSON(http.StatusBadRequest, response)
-				return
-			}
-
-			// 读取分块结束标记
-			_, _, _ = reader.ReadLine()
-
-			chunkCount++
-			chunks = append(chunks, map[string]interface{}{
-				"chunk_id": chunkCount,
-				"size":     size,
-				"data":     string(chunkData),
-			})
+	bodyData, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		response := routes.CreateErrorResponse(400, "读取请求体失败: "+err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
 
-			receivedData.Write(chunkData)
-		}
-	} else {
-		// 普通传输方式
-		bodyData, err := io.ReadAll(c.Request.Body)
-		if err != nil {
-			response := routes.CreateErrorResponse(400, "读取请求体失败: "+err.Error())
-			c.JSON(http.StatusBadRequest, response)
-			return
+	var chunks []map[string]interface{}
+	wire, captured := wireChunks(c)
+	offset := int64(0)
+	for i, chunk := range wire {
+		end := offset + chunk.Size
+		if end > int64(len(bodyData)) {
+			end = int64(len(bodyData))
 		}
-		receivedData.Write(bodyData)
+		chunks = append(chunks, map[string]interface{}{
+			"chunk_id":   i + 1,
+			"size":       chunk.Size,
+			"size_line":  chunk.SizeLine,
+			"extensions": chunk.Extensions,
+			"data":       string(bodyData[offset:end]),
+		})
+		offset = end
 	}
 
 	result := map[string]interface{}{
 		"is_chunked":        isChunked,
-		"transfer_encoding": transferEncoding,
-		"chunk_count":       chunkCount,
-		"total_size":        receivedData.Len(),
-		"received_data":     receivedData.String(),
+		"transfer_encoding": strings.Join(c.Request.TransferEncoding, ", "),
+		"chunk_count":       len(wire),
+		"chunk_source":      chunkSource(captured),
+		"total_size":        len(bodyData),
+		"received_data":     string(bodyData),
 		"chunks":            chunks,
 		"content_type":      c.GetHeader("Content-Type"),
 		"content_length":    c.GetHeader("Content-Length"),
@@ -218,16 +174,9 @@ func handleChunkedStream(c *gin.Context) {
 		interval = 1000
 	}
 
-	// 设置分块传输头
-	c.Header("Transfer-Encoding", "chunked")
-	c.Header("Content-Type", "application/json")
-	c.Status(http.StatusOK)
-
-	w := c.Writer
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		response := routes.CreateErrorResponse(500, "不支持分块传输")
-		c.JSON(http.StatusInternalServerError, response)
+	emitter, err := startChunked(c, "application/json", parseChunkOptions(c))
+	if err != nil {
+		chunkedFailed(c, err)
 		return
 	}
 
@@ -254,92 +203,59 @@ func handleChunkedStream(c *gin.Context) {
 			chunkID, chunk["timestamp"], chunk["elapsed_ms"], chunk["remaining_ms"], chunkID, chunk["server_time"])
 
 		// 写入分块
-		fmt.Fprintf(w, "%x\r\n", len(chunkData))
-		fmt.Fprintf(w, "%s\r\n", chunkData)
-		flusher.Flush()
+		if err := emitter.write([]byte(chunkData)); err != nil {
+			return
+		}
 	}
 
 	// 结束分块传输
-	fmt.Fprintf(w, "0\r\n\r\n")
-	flusher.Flush()
+	_ = emitter.finish()
 }
 
 // 分块上传测试
 func handleChunkedUpload(c *gin.Context) {
-	// 检查是否为分块传输
-	transferEncoding := c.GetHeader("Transfer-Encoding")
-	isChunked := strings.Contains(strings.ToLower(transferEncoding), "chunked")
-
-	if !isChunked {
+	if !isChunkedRequest(c) {
 		response := routes.CreateErrorResponse(400, "需要分块传输编码")
 		c.JSON(http.StatusBadRequest, response)
 		return
 	}
 
-	var totalSize int64
-	var chunkCount int
-	var chunks []map[string]interface{}
-
-	reader := bufio.NewReader(c.Request.Body)
 	startTime := time.Now()
+	verifier := integrity.NewVerifier(c.Request)
+	totalSize, err := io.Copy(io.Discard, c.Request.Body)
+	if err != nil {
+		response := routes.CreateErrorResponse(400, "读取分块数据失败: "+err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	uploadTime := time.Since(startTime)
 
-	for {
-		// 读取分块大小
-		line, _, err := reader.ReadLine()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			response := routes.CreateErrorResponse(400, "读取分块失败: "+err.Error())
-			c.JSON(http.StatusBadRequest, response)
-			return
-		}
-
-		sizeStr := strings.TrimSpace(string(line))
-		if sizeStr == "" {
-			continue
-		}
-
-		size, err := strconv.ParseInt(sizeStr, 16, 64)
-		if err != nil {
-			response := routes.CreateErrorResponse(400, "解析分块大小失败: "+err.Error())
-			c.JSON(http.StatusBadRequest, response)
-			return
-		}
-
-		if size == 0 {
-			break
-		}
-
-		// 读取分块数据
-		chunkData := make([]byte, size)
-		_, err = io.ReadFull(reader, chunkData)
-		if err != nil {
-			response := routes.CreateErrorResponse(400, "读取分块数据失败: "+err.Error())
-			c.JSON(http.StatusBadRequest, response)
-			return
-		}
-
-		// 读取分块结束标记
-		_, _, _ = reader.ReadLine()
-
-		chunkCount++
-		totalSize += size
+	var chunks []map[string]interface{}
+	wire, captured := wireChunks(c)
+	for i, chunk := range wire {
 		chunks = append(chunks, map[string]interface{}{
-			"chunk_id":    chunkCount,
-			"size":        size,
-			"received_at": time.Now().Unix(),
+			"chunk_id":   i + 1,
+			"size":       chunk.Size,
+			"size_line":  chunk.SizeLine,
+			"extensions": chunk.Extensions,
 		})
 	}
 
+	averageChunkSize := 0.0
+	if len(wire) > 0 {
+		averageChunkSize = float64(totalSize) / float64(len(wire))
+	}
+
 	uploadStats := map[string]interface{}{
-		"chunk_count":        chunkCount,
+		"chunk_count":        len(wire),
+		"chunk_source":       chunkSource(captured),
 		"total_size":         totalSize,
-		"upload_time_ms":     time.Since(startTime).Milliseconds(),
-		"average_chunk_size": float64(totalSize) / float64(chunkCount),
-		"upload_speed_bps":   float64(totalSize) / time.Since(startTime).Seconds(),
+		"upload_time_ms":     uploadTime.Milliseconds(),
+		"average_chunk_size": averageChunkSize,
+		"upload_speed_bps":   float64(totalSize) / uploadTime.Seconds(),
 		"chunks":             chunks,
 		"content_type":       c.GetHeader("Content-Type"),
+		"integrity":          verifier.Result(),
 		"completed_at":       time.Now().Unix(),
 	}
 
@@ -347,6 +263,36 @@ func handleChunkedUpload(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// isChunkedRequest 判断请求是否使用分块编码
+// net/http会把Transfer-Encoding从Header移到Request.TransferEncoding
+func isChunkedRequest(c *gin.Context) bool {
+	codings := c.Request.TransferEncoding
+	return len(codings) > 0 && strings.EqualFold(codings[len(codings)-1], "chunked")
+}
+
+// wireChunks 从原始捕获中获取线上的数据分块（不含last-chunk），需在读完请求体后调用
+func wireChunks(c *gin.Context) ([]raw.RawChunk, bool) {
+	captured, err := raw.Current(c)
+	if err != nil {
+		return nil, false
+	}
+	chunks := make([]raw.RawChunk, 0, len(captured.Chunks))
+	for _, chunk := range captured.Chunks {
+		if chunk.Size > 0 {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks, true
+}
+
+// chunkSource 说明分块信息的来源
+func chunkSource(captured bool) string {
+	if captured {
+		return "wire"
+	}
+	return "unavailable"
+}
+
 // Identity传输编码测试
 func handleIdentityTransfer(c *gin.Context) {
 	c.Header("Transfer-Encoding", "identity")
@@ -401,123 +347,81 @@ func handleLargeTransfer(c *gin.Context) {
 
 	useChunked := c.Query("chunked") == "true"
 
-	if useChunked {
-		c.Header("Transfer-Encoding", "chunked")
-	}
-
-	c.Header("Content-Type", "application/octet-stream")
-	c.Status(http.StatusOK)
-
-	w := c.Writer
-	flusher, ok := w.(http.Flusher)
-
 	// 生成大文件数据
 	chunkSize := 1024 * 1024 // 1MB chunks
 	totalChunks := size
 
-	for i := 0; i < totalChunks; i++ {
-		chunk := make([]byte, chunkSize)
-		for j := 0; j < chunkSize; j++ {
-			chunk[j] = byte((i + j) % 256)
+	if useChunked {
+		// 内容是确定的，摘要已缓存或显式指定digest=header时提前以响应头发送；
+		// 否则由输出器边发送边计算，以trailer发送，避免首字节前先对整个文件做哈希
+		options := parseChunkOptions(c)
+		if integrity.Mode(c) == integrity.ModeHeader {
+			if digests, ok := upfrontLargeDigests(c, int64(totalChunks*chunkSize), false); ok {
+				digests.Apply(c.Writer.Header())
+			} else {
+				options.digest = true
+			}
 		}
-
-		if useChunked && ok {
-			// 分块传输
-			fmt.Fprintf(w, "%x\r\n", len(chunk))
-			_, _ = w.Write(chunk)
-			fmt.Fprintf(w, "\r\n")
-			flusher.Flush()
-		} else {
-			// 普通传输
-			_, _ = w.Write(chunk)
-			if ok {
-				flusher.Flush()
+		emitter, err := startChunked(c, "application/octet-stream", options)
+		if err != nil {
+			chunkedFailed(c, err)
+			return
+		}
+		for i := 0; i < totalChunks; i++ {
+			if err := emitter.write(generateLargeChunk(i, chunkSize)); err != nil {
+				return
 			}
 		}
+		_ = emitter.finish()
+		return
 	}
 
-	if useChunked && ok {
-		fmt.Fprintf(w, "0\r\n\r\n")
-		flusher.Flush()
+	// 普通传输支持Range请求，内容与分块下载逐字节一致
+	serveLargeContent(c, int64(totalChunks*chunkSize))
+}
+
+// generateLargeChunk 生成第i个大文件数据块
+func generateLargeChunk(i, chunkSize int) []byte {
+	chunk := make([]byte, chunkSize)
+	for j := 0; j < chunkSize; j++ {
+		chunk[j] = largeContentByte(int64(i*chunkSize + j))
 	}
+	return chunk
 }
 
 // 大文件接收测试
 func handleLargeReceive(c *gin.Context) {
 	startTime := time.Now()
-
-	// 检查传输编码
-	transferEncoding := c.GetHeader("Transfer-Encoding")
-	isChunked := strings.Contains(strings.ToLower(transferEncoding), "chunked")
-
-	var totalSize int64
-	var chunkCount int
-
-	if isChunked {
-		// 处理分块传输
-		reader := bufio.NewReader(c.Request.Body)
-
-		for {
-			line, _, err := reader.ReadLine()
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
-				response := routes.CreateErrorResponse(400, "读取分块失败: "+err.Error())
-				c.JSON(http.StatusBadRequest, response)
-				return
-			}
-
-			sizeStr := strings.TrimSpace(string(line))
-			if sizeStr == "" {
-				continue
-			}
-
-			size, err := strconv.ParseInt(sizeStr, 16, 64)
-			if err != nil {
-				response := routes.CreateErrorResponse(400, "解析分块大小失败: "+err.Error())
-				c.JSON(http.StatusBadRequest, response)
-				return
-			}
-
-			if size == 0 {
-				break
-			}
-
-			// 读取分块数据（但不保存到内存）
-			_, err = io.CopyN(io.Discard, reader, size)
-			if err != nil {
-				response := routes.CreateErrorResponse(400, "读取分块数据失败: "+err.Error())
-				c.JSON(http.StatusBadRequest, response)
-				return
-			}
-
-			// 读取分块结束标记
-			_, _, _ = reader.ReadLine()
-
-			chunkCount++
-			totalSize += size
-		}
-	} else {
-		// 普通传输
-		size, err := io.Copy(io.Discard, c.Request.Body)
-		if err != nil {
-			response := routes.CreateErrorResponse(400, "读取数据失败: "+err.Error())
-			c.JSON(http.StatusBadRequest, response)
-			return
-		}
-		totalSize = size
+	isChunked := isChunkedRequest(c)
+
+	// 按Content-Encoding解码，total_size为解码后的大小；摘要按解码前的数据校验
+	verifier := integrity.NewVerifier(c.Request)
+	body := compression.DecodeRequest(c.Request, maxLargeDecoded)
+	defer body.Close()
+	totalSize, err := io.Copy(io.Discard, body)
+	if err != nil {
+		status := compression.ErrorStatus(err)
+		response := routes.CreateErrorResponse(status, "读取数据失败: "+err.Error())
+		response.Data = map[string]interface{}{"request_encoding": body.Info()}
+		c.JSON(status, response)
+		return
 	}
-
 	transferTime := time.Since(startTime)
+
+	// 超出捕获上限的请求无法获取线上分块信息
+	wire, captured := wireChunks(c)
+
 	transferStats := map[string]interface{}{
 		"is_chunked":          isChunked,
-		"transfer_encoding":   transferEncoding,
-		"chunk_count":         chunkCount,
+		"transfer_encoding":   strings.Join(c.Request.TransferEncoding, ", "),
+		"chunk_count":         len(wire),
+		"chunk_source":        chunkSource(captured),
 		"total_size":          totalSize,
 		"transfer_time_ms":    transferTime.Milliseconds(),
 		"transfer_speed_bps":  float64(totalSize) / transferTime.Seconds(),
 		"transfer_speed_mbps": (float64(totalSize) / (1024 * 1024)) / transferTime.Seconds(),
+		"request_encoding":    body.Info(),
+		"integrity":           verifier.Result(),
 		"received_at":         time.Now().Unix(),
 	}
 
@@ -561,14 +465,25 @@ func handleSSETransfer(c *gin.Context) {
 
 // WebSocket传输测试
 func handleWebSocketTransfer(c *gin.Context) {
-	// 这里只是一个占位符，实际的WebSocket处理在websocket模块中
+	// 升级请求按 ?case= 选择协议边界测试场景，与 /ws/edge 相同
+	if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		websocket.HandleEdgeCase(c)
+		return
+	}
+
+	// 实际的WebSocket处理在websocket模块中
 	response := routes.CreateSuccessResponse("WebSocket传输测试", map[string]interface{}{
-		"message": "请使用 /ws/* 端点进行WebSocket测试",
+		"message":    "请使用 /ws/* 端点进行WebSocket测试",
+		"edge_cases": "/ws/edge",
 		"websocket_endpoints": []string{
 			"/ws/connect",
 			"/ws/echo",
 			"/ws/broadcast",
 			"/ws/realtime",
+			"/ws/heartbeat",
+			"/ws/binary",
+			"/ws/chat",
+			"/ws/performance",
 		},
 	})
 	c.JSON(http.StatusOK, response)
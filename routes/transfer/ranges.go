@@ -0,0 +1,331 @@
+package transfer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+	"http_proxy_tool_test_web_demo/routes/integrity"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// largeBlockSize 生成内容的块大小，与generateLargeChunk一致
+	largeBlockSize = 1024 * 1024
+	// maxRanges 单个请求允许的最大范围数，防止大量小范围放大响应
+	maxRanges = 100
+)
+
+// largeModified 生成内容不会变化，Last-Modified固定为进程启动时间
+var largeModified = time.Now().UTC().Truncate(time.Second)
+
+// largeDigest 某个大小的生成内容的摘要，首次使用时计算
+type largeDigest struct {
+	once    sync.Once
+	ready   atomic.Bool
+	digests integrity.Digests
+}
+
+var (
+	largeDigests     = make(map[int64]*largeDigest)
+	largeDigestsLock sync.Mutex
+)
+
+var (
+	// errRangeInvalid Range语法错误，按RFC 9110应忽略Range返回完整内容
+	errRangeInvalid = errors.New("Range格式错误")
+	// errRangeUnsatisfiable 所有范围都不可满足，应返回416
+	errRangeUnsatisfiable = errors.New("Range不可满足")
+)
+
+// byteRange 闭区间[start, end]
+type byteRange struct {
+	start int64
+	end   int64
+}
+
+func (r byteRange) length() int64 {
+	return r.end - r.start + 1
+}
+
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.end, size)
+}
+
+// largeContentByte 大文件第offset个字节，与完整下载逐字节一致
+func largeContentByte(offset int64) byte {
+	return byte((offset/largeBlockSize + offset%largeBlockSize) % 256)
+}
+
+// writeLargeRange 写出[start, end]范围的生成内容
+func writeLargeRange(w io.Writer, start, end int64) error {
+	buffer := make([]byte, 64*1024)
+	for offset := start; offset <= end; {
+		n := int64(len(buffer))
+		if remaining := end - offset + 1; remaining < n {
+			n = remaining
+		}
+		for i := int64(0); i < n; i++ {
+			buffer[i] = largeContentByte(offset + i)
+		}
+		if _, err := w.Write(buffer[:n]); err != nil {
+			return err
+		}
+		offset += n
+	}
+	return nil
+}
+
+// largeContentDigests 完整内容的摘要，内容只取决于大小，按大小缓存
+func largeContentDigests(size int64) integrity.Digests {
+	largeDigestsLock.Lock()
+	entry, ok := largeDigests[size]
+	if !ok {
+		entry = &largeDigest{}
+		largeDigests[size] = entry
+	}
+	largeDigestsLock.Unlock()
+
+	entry.once.Do(func() {
+		hasher := integrity.NewHasher()
+		if size > 0 {
+			_ = writeLargeRange(hasher, 0, size-1)
+		}
+		entry.digests = hasher.Sum()
+		entry.ready.Store(true)
+	})
+	return entry.digests
+}
+
+// cachedLargeDigests 已经计算过的摘要，不触发计算
+func cachedLargeDigests(size int64) (integrity.Digests, bool) {
+	largeDigestsLock.Lock()
+	entry, ok := largeDigests[size]
+	largeDigestsLock.Unlock()
+	if !ok || !entry.ready.Load() {
+		return integrity.Digests{}, false
+	}
+	return entry.digests, true
+}
+
+// upfrontLargeDigests 发送响应头前可用的摘要
+// 完整的GET响应和显式指定digest=header时计算整个文件的摘要；
+// 部分响应和HEAD只使用已缓存的结果，避免为几个字节等待对整个文件的哈希
+func upfrontLargeDigests(c *gin.Context, size int64, full bool) (integrity.Digests, bool) {
+	if full || (integrity.Requested(c) && integrity.Mode(c) == integrity.ModeHeader) {
+		return largeContentDigests(size), true
+	}
+	return cachedLargeDigests(size)
+}
+
+// parseRange 解析Range头，支持 first-last、first- 和 -suffix
+func parseRange(header string, size int64) ([]byteRange, error) {
+	unit, specs, found := strings.Cut(header, "=")
+	if !found || !strings.EqualFold(strings.TrimSpace(unit), "bytes") {
+		return nil, errRangeInvalid
+	}
+
+	ranges := make([]byteRange, 0)
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		first, last, found := strings.Cut(spec, "-")
+		if !found {
+			return nil, errRangeInvalid
+		}
+
+		if first == "" {
+			// 后缀范围：最后N个字节
+			suffix, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || suffix < 0 {
+				return nil, errRangeInvalid
+			}
+			if suffix == 0 || size == 0 {
+				continue
+			}
+			if suffix > size {
+				suffix = size
+			}
+			ranges = append(ranges, byteRange{start: size - suffix, end: size - 1})
+			continue
+		}
+
+		start, err := strconv.ParseInt(first, 10, 64)
+		if err != nil || start < 0 {
+			return nil, errRangeInvalid
+		}
+		end := size - 1
+		if last != "" {
+			end, err = strconv.ParseInt(last, 10, 64)
+			if err != nil || end < start {
+				return nil, errRangeInvalid
+			}
+		}
+		if start >= size {
+			continue
+		}
+		if end >= size {
+			end = size - 1
+		}
+		ranges = append(ranges, byteRange{start: start, end: end})
+	}
+
+	if len(ranges) > maxRanges {
+		return nil, errRangeInvalid
+	}
+	if len(ranges) == 0 {
+		return nil, errRangeUnsatisfiable
+	}
+	return ranges, nil
+}
+
+// ifRangeMatches If-Range为强ETag或与Last-Modified完全相同的日期时才应用Range
+func ifRangeMatches(ifRange, etag string) bool {
+	ifRange = strings.TrimSpace(ifRange)
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		return ifRange == etag
+	}
+	date, err := http.ParseTime(ifRange)
+	return err == nil && date.Equal(largeModified)
+}
+
+// serveLargeContent 输出生成的大文件内容，支持单范围、多范围（multipart/byteranges）、If-Range和416
+// 完整响应附带全部摘要头；部分响应只有Repr-Digest（完整表示的摘要），HEAD和部分响应仅在摘要已缓存或指定digest=header时发送；
+// digest=trailer 时完整响应边输出边计算摘要
+func serveLargeContent(c *gin.Context, size int64) {
+	etag := fmt.Sprintf(`"large-%d"`, size)
+	header := c.Writer.Header()
+	header.Set("Accept-Ranges", "bytes")
+	header.Set("ETag", etag)
+	header.Set("Last-Modified", largeModified.Format(http.TimeFormat))
+
+	ranges := []byteRange{{start: 0, end: size - 1}}
+	partial := false
+	if rangeHeader := c.GetHeader("Range"); rangeHeader != "" && ifRangeMatches(c.GetHeader("If-Range"), etag) {
+		parsed, err := parseRange(rangeHeader, size)
+		switch {
+		case errors.Is(err, errRangeUnsatisfiable):
+			header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			response := routes.CreateErrorResponse(416, "请求的范围不可满足: "+rangeHeader)
+			c.JSON(http.StatusRequestedRangeNotSatisfiable, response)
+			return
+		case err == nil:
+			ranges = parsed
+			partial = true
+		}
+	}
+
+	writeBody := c.Request.Method != http.MethodHead
+	mode := integrity.Mode(c)
+	w := c.Writer
+
+	// 完整内容或单个范围
+	if len(ranges) == 1 {
+		status := http.StatusOK
+		if partial {
+			status = http.StatusPartialContent
+			header.Set("Content-Range", ranges[0].contentRange(size))
+		}
+		// 声明Content-Length避免net/http自动改用分块编码
+		header.Set("Content-Type", "application/octet-stream")
+
+		// trailer只能随分块编码发送，此时不声明Content-Length
+		var digest *integrity.Hasher
+		var out io.Writer = w
+		switch {
+		case mode == integrity.ModeTrailer && !partial && writeBody:
+			digest = integrity.NewHasher()
+			out = io.MultiWriter(w, digest)
+			header.Set("Trailer", integrity.TrailerNames())
+		case mode != integrity.ModeNone:
+			if digests, ok := upfrontLargeDigests(c, size, !partial && writeBody && mode == integrity.ModeHeader); ok {
+				if partial {
+					digests.ApplyRepresentation(header)
+				} else {
+					digests.Apply(header)
+				}
+			}
+		}
+		if digest == nil {
+			// 声明Content-Length避免net/http自动改用分块编码
+			header.Set("Content-Length", strconv.FormatInt(ranges[0].length(), 10))
+		}
+
+		c.Status(status)
+		if !writeBody {
+			w.WriteHeaderNow()
+			return
+		}
+		for start := ranges[0].start; start <= ranges[0].end; start += largeBlockSize {
+			end := start + largeBlockSize - 1
+			if end > ranges[0].end {
+				end = ranges[0].end
+			}
+			if err := writeLargeRange(out, start, end); err != nil {
+				return
+			}
+			w.Flush()
+		}
+		if digest != nil && header.Get("Content-Encoding") == "" {
+			digest.Sum().Apply(header)
+		}
+		return
+	}
+
+	// 多个范围使用multipart/byteranges，先计算各部分头部以得到准确的Content-Length
+	boundary := newBoundary()
+	partHeaders := make([]string, len(ranges))
+	length := int64(0)
+	for i, r := range ranges {
+		partHeaders[i] = fmt.Sprintf("\r\n--%s\r\nContent-Type: application/octet-stream\r\nContent-Range: %s\r\n\r\n", boundary, r.contentRange(size))
+		length += int64(len(partHeaders[i])) + r.length()
+	}
+	closing := "\r\n--" + boundary + "--\r\n"
+	length += int64(len(closing))
+
+	if mode != integrity.ModeNone {
+		if digests, ok := upfrontLargeDigests(c, size, false); ok {
+			digests.ApplyRepresentation(header)
+		}
+	}
+	header.Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+	header.Set("Content-Length", strconv.FormatInt(length, 10))
+	c.Status(http.StatusPartialContent)
+	if !writeBody {
+		w.WriteHeaderNow()
+		return
+	}
+	for i, r := range ranges {
+		if _, err := io.WriteString(w, partHeaders[i]); err != nil {
+			return
+		}
+		if err := writeLargeRange(w, r.start, r.end); err != nil {
+			return
+		}
+	}
+	_, _ = io.WriteString(w, closing)
+}
+
+// newBoundary 生成multipart分隔符
+func newBoundary() string {
+	data := make([]byte, 12)
+	if _, err := rand.Read(data); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(data)
+}
@@ -0,0 +1,251 @@
+package transfer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+	"http_proxy_tool_test_web_demo/routes/integrity"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chunkedEmitter 接管连接后逐字节写出分块编码，确保线上的分块边界与调用方一致
+// 无法接管连接时（HTTP/2、测试环境）退回net/http自身的分块编码，此时扩展和零长度分块不可用
+type chunkedEmitter struct {
+	conn    net.Conn
+	writer  *bufio.Writer
+	raw     bool
+	gin     gin.ResponseWriter
+	options chunkOptions
+
+	chunkCount int
+	totalBytes int64
+	digest     *integrity.Hasher // 设置digest=trailer时计算已输出数据的摘要
+}
+
+// chunkOptions 分块输出选项
+type chunkOptions struct {
+	extensions []string    // 每个分块附带的扩展，如 name=value
+	sizes      []int       // 分块大小序列，循环使用；为空时按调用方的数据切分
+	zeroChunks int         // 在第一个分块后插入的零长度分块数
+	trailers   http.Header // 结束时发送的额外trailer
+	digest     bool        // 结束时以trailer发送数据摘要
+}
+
+// errChunkedHTTP10 HTTP/1.0客户端不支持分块传输编码
+var errChunkedHTTP10 = errors.New("HTTP/1.0不支持分块传输编码")
+
+// 去掉换行，避免查询参数中的内容破坏分块结构
+var crlfStripper = strings.NewReplacer("\r", "", "\n", "")
+
+// parseChunkOptions 从查询参数解析分块选项
+// ext=name=value 可重复；sizes=1,10,100；zero_chunks=N；trailer=Name:Value 可重复；digest=trailer
+func parseChunkOptions(c *gin.Context) chunkOptions {
+	options := chunkOptions{trailers: http.Header{}, digest: integrity.Mode(c) == integrity.ModeTrailer}
+
+	for _, ext := range c.QueryArray("ext") {
+		if ext = crlfStripper.Replace(ext); ext != "" {
+			options.extensions = append(options.extensions, ext)
+		}
+	}
+
+	for _, value := range strings.Split(c.Query("sizes"), ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(value))
+		if err == nil && size > 0 && size <= 16*1024*1024 {
+			options.sizes = append(options.sizes, size)
+		}
+	}
+
+	zeroChunks, err := strconv.Atoi(c.Query("zero_chunks"))
+	if err == nil && zeroChunks > 0 && zeroChunks <= 100 {
+		options.zeroChunks = zeroChunks
+	}
+
+	for _, trailer := range c.QueryArray("trailer") {
+		name, value, ok := strings.Cut(crlfStripper.Replace(trailer), ":")
+		if ok && strings.TrimSpace(name) != "" {
+			options.trailers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+
+	return options
+}
+
+// startChunked 写出响应头并返回分块输出器
+func startChunked(c *gin.Context, contentType string, options chunkOptions) (*chunkedEmitter, error) {
+	trailerNames := []string{"X-Chunk-Count", "X-Total-Bytes"}
+	for name := range options.trailers {
+		trailerNames = append(trailerNames, name)
+	}
+	var digest *integrity.Hasher
+	if options.digest {
+		trailerNames = append(trailerNames, integrity.HeaderNames...)
+		digest = integrity.NewHasher()
+	}
+	sort.Strings(trailerNames)
+
+	if c.Request.ProtoMajor == 1 && c.Request.ProtoMinor == 0 {
+		return nil, errChunkedHTTP10
+	}
+	conn, rw, err := routes.HijackConnection(c)
+	if errors.Is(err, routes.ErrHijackNotSupported) {
+		if c.Request.ProtoMajor == 1 {
+			c.Header("Transfer-Encoding", "chunked")
+		}
+		c.Header("Content-Type", contentType)
+		c.Header("Trailer", strings.Join(trailerNames, ", "))
+		c.Status(http.StatusOK)
+		return &chunkedEmitter{gin: c.Writer, options: options, digest: digest}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 保留中间件已设置的响应头（如CORS）
+	header := c.Writer.Header().Clone()
+	header.Del("Content-Length")
+	header.Set("Content-Type", contentType)
+	header.Set("Transfer-Encoding", "chunked")
+	header.Set("Trailer", strings.Join(trailerNames, ", "))
+	header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	header.Set("Connection", "close")
+
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	_ = conn.SetDeadline(time.Time{})
+	fmt.Fprintf(rw.Writer, "%s 200 OK\r\n", c.Request.Proto)
+	for _, name := range names {
+		for _, value := range header[name] {
+			fmt.Fprintf(rw.Writer, "%s: %s\r\n", name, value)
+		}
+	}
+	fmt.Fprintf(rw.Writer, "\r\n")
+	if err := rw.Writer.Flush(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &chunkedEmitter{conn: conn, writer: rw.Writer, raw: true, options: options, digest: digest}, nil
+}
+
+// chunkedFailed 无法开始分块输出时的错误响应，HTTP/1.0请求返回505
+func chunkedFailed(c *gin.Context, err error) {
+	if errors.Is(err, errChunkedHTTP10) {
+		response := routes.CreateErrorResponse(505, err.Error()+"，请使用HTTP/1.1或HTTP/2")
+		c.JSON(http.StatusHTTPVersionNotSupported, response)
+		return
+	}
+	response := routes.CreateErrorResponse(500, "不支持分块传输: "+err.Error())
+	c.JSON(http.StatusInternalServerError, response)
+}
+
+// write 输出数据，设置了sizes时按大小序列重新切分
+func (e *chunkedEmitter) write(data []byte) error {
+	if len(e.options.sizes) == 0 {
+		return e.writeChunk(data)
+	}
+	for len(data) > 0 {
+		size := e.options.sizes[e.chunkCount%len(e.options.sizes)]
+		if size > len(data) {
+			size = len(data)
+		}
+		if err := e.writeChunk(data[:size]); err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+// writeChunk 输出一个分块：大小[;扩展]\r\n数据\r\n
+func (e *chunkedEmitter) writeChunk(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	e.chunkCount++
+	e.totalBytes += int64(len(data))
+	if e.digest != nil {
+		_, _ = e.digest.Write(data)
+	}
+
+	if !e.raw {
+		if _, err := e.gin.Write(data); err != nil {
+			return err
+		}
+		e.gin.Flush()
+		return nil
+	}
+
+	fmt.Fprintf(e.writer, "%x%s\r\n", len(data), e.extensionSuffix())
+	if _, err := e.writer.Write(data); err != nil {
+		return err
+	}
+	if _, err := e.writer.WriteString("\r\n"); err != nil {
+		return err
+	}
+
+	// 按RFC 9112零长度分块即last-chunk，这里故意在数据中途插入，用于测试代理是否提前结束消息体
+	if e.chunkCount == 1 {
+		for i := 0; i < e.options.zeroChunks; i++ {
+			fmt.Fprintf(e.writer, "0%s\r\n\r\n", e.extensionSuffix())
+		}
+	}
+	return e.writer.Flush()
+}
+
+// finish 输出last-chunk和trailer并关闭连接
+func (e *chunkedEmitter) finish() error {
+	trailers := e.options.trailers.Clone()
+	trailers.Set("X-Chunk-Count", strconv.Itoa(e.chunkCount))
+	trailers.Set("X-Total-Bytes", strconv.FormatInt(e.totalBytes, 10))
+	// 经压缩中间件输出时消息内容已被改写，不再发送按原始数据计算的摘要
+	if e.digest != nil && (e.raw || e.gin.Header().Get("Content-Encoding") == "") {
+		e.digest.Sum().Apply(trailers)
+	}
+
+	if !e.raw {
+		for name, values := range trailers {
+			for _, value := range values {
+				e.gin.Header().Add(name, value)
+			}
+		}
+		e.gin.Flush()
+		return nil
+	}
+	defer func() { _ = e.conn.Close() }()
+
+	names := make([]string, 0, len(trailers))
+	for name := range trailers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(e.writer, "0%s\r\n", e.extensionSuffix())
+	for _, name := range names {
+		for _, value := range trailers[name] {
+			fmt.Fprintf(e.writer, "%s: %s\r\n", name, value)
+		}
+	}
+	fmt.Fprintf(e.writer, "\r\n")
+	return e.writer.Flush()
+}
+
+// extensionSuffix 生成分块扩展后缀
+func (e *chunkedEmitter) extensionSuffix() string {
+	if len(e.options.extensions) == 0 {
+		return ""
+	}
+	return ";" + strings.Join(e.options.extensions, ";")
+}
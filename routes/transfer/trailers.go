@@ -0,0 +1,202 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+	"http_proxy_tool_test_web_demo/routes/integrity"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxTrailerBodySize = 64 * 1024 * 1024 // 带trailer流式响应的最大消息体
+	maxTrailerChunks   = 1000             // 最大写入次数
+	maxTrailerInterval = 10000            // 两次写入的最大间隔（毫秒）
+)
+
+// 流式响应固定发送的trailer
+const (
+	trailerBodyBytes    = "X-Body-Bytes"    // 实际发送的字节数
+	trailerChunkCount   = "X-Chunk-Count"   // 写入并刷新的次数
+	trailerStreamStatus = "X-Stream-Status" // 处理结果，status参数指定
+	trailerServerTiming = "Server-Timing"   // 生成耗时
+)
+
+// 带trailer的流式响应，使用net/http的trailer机制，HTTP/1.1下为分块编码的trailer，HTTP/2下为结尾的HEADERS帧
+// size=字节数；chunks=写入次数；interval=每次写入间隔（毫秒）；status=X-Stream-Status的取值；
+// trailer=Name:Value 可重复；grpc=1 附加Grpc-Status/Grpc-Message；undeclared=1 额外发送一个未在Trailer头中声明的字段
+func handleTrailerStream(c *gin.Context) {
+	size, err := strconv.ParseInt(c.DefaultQuery("size", "65536"), 10, 64)
+	if err != nil || size < 0 || size > maxTrailerBodySize {
+		response := routes.CreateErrorResponse(400, fmt.Sprintf("size必须在0到%d之间", maxTrailerBodySize))
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	chunks, err := strconv.Atoi(c.DefaultQuery("chunks", "8"))
+	if err != nil || chunks < 1 || chunks > maxTrailerChunks {
+		response := routes.CreateErrorResponse(400, fmt.Sprintf("chunks必须在1到%d之间", maxTrailerChunks))
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	interval, err := strconv.Atoi(c.DefaultQuery("interval", "0"))
+	if err != nil || interval < 0 || interval > maxTrailerInterval {
+		response := routes.CreateErrorResponse(400, fmt.Sprintf("interval必须在0到%d毫秒之间", maxTrailerInterval))
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	trailers := http.Header{}
+	for _, trailer := range c.QueryArray("trailer") {
+		name, value, ok := strings.Cut(crlfStripper.Replace(trailer), ":")
+		if ok && strings.TrimSpace(name) != "" {
+			trailers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+	trailers.Set(trailerStreamStatus, c.DefaultQuery("status", "ok"))
+	if c.Query("grpc") == "1" {
+		trailers.Set("Grpc-Status", "0")
+		trailers.Set("Grpc-Message", "OK")
+	}
+
+	names := []string{trailerBodyBytes, trailerChunkCount, trailerServerTiming}
+	names = append(names, integrity.HeaderNames...)
+	for name := range trailers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "application/octet-stream")
+	header.Set("Trailer", strings.Join(names, ", "))
+	c.Status(http.StatusOK)
+
+	startTime := time.Now()
+	hasher := integrity.NewHasher()
+	writer := io.MultiWriter(c.Writer, hasher)
+	written := 0
+	var offset int64
+	for i := 0; i < chunks; i++ {
+		// 客户端断开后不再继续输出
+		if i > 0 && interval > 0 && !sleepContext(c, time.Duration(interval)*time.Millisecond) {
+			return
+		}
+		// 余数分摊到前面的写入中
+		n := size / int64(chunks)
+		if int64(i) < size%int64(chunks) {
+			n++
+		}
+		if n > 0 {
+			if err := writeLargeRange(writer, offset, offset+n-1); err != nil {
+				return
+			}
+			offset += n
+		}
+		c.Writer.Flush()
+		written++
+	}
+
+	for name, values := range trailers {
+		header[name] = values
+	}
+	header.Set(trailerBodyBytes, strconv.FormatInt(hasher.Size(), 10))
+	header.Set(trailerChunkCount, strconv.Itoa(written))
+	header.Set(trailerServerTiming, fmt.Sprintf("total;dur=%.3f", float64(time.Since(startTime).Microseconds())/1000))
+	// 经压缩中间件输出时消息内容已被改写，不再发送按原始数据计算的摘要
+	if header.Get("Content-Encoding") == "" {
+		hasher.Sum().Apply(header)
+	}
+	if c.Query("undeclared") == "1" {
+		header.Set(http.TrailerPrefix+"X-Undeclared-Trailer", "sent")
+	}
+}
+
+// 读取请求trailer并回显，回显内容同时出现在JSON和同名的响应trailer中
+// 适用于HTTP/1.1分块请求和HTTP/2请求
+func handleTrailerEcho(c *gin.Context) {
+	// net/http把Trailer头声明的字段名放入Request.Trailer，读完消息体后才有取值
+	declared := make([]string, 0, len(c.Request.Trailer))
+	for name := range c.Request.Trailer {
+		declared = append(declared, name)
+	}
+	sort.Strings(declared)
+
+	startTime := time.Now()
+	verifier := integrity.NewVerifier(c.Request)
+	size, err := io.Copy(io.Discard, c.Request.Body)
+	if err != nil {
+		response := routes.CreateErrorResponse(400, "读取请求体失败: "+err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	received := make(map[string][]string)
+	names := make([]string, 0, len(c.Request.Trailer))
+	undeclared := make([]string, 0)
+	for name, values := range c.Request.Trailer {
+		if len(values) == 0 {
+			continue
+		}
+		received[name] = values
+		names = append(names, name)
+		if !containsName(declared, name) {
+			undeclared = append(undeclared, name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(undeclared)
+	missing := make([]string, 0)
+	for _, name := range declared {
+		if _, ok := received[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+
+	header := c.Writer.Header()
+	if len(names) > 0 {
+		header.Set("Trailer", strings.Join(names, ", "))
+	}
+	response := routes.CreateSuccessResponse("请求trailer", map[string]interface{}{
+		"protocol":          c.Request.Proto,
+		"chunked":           isChunkedRequest(c),
+		"body_size":         size,
+		"declared_trailers": declared,
+		"trailers":          received,
+		"missing":           missing,
+		"undeclared":        undeclared,
+		"integrity":         verifier.Result(),
+		"read_time_ms":      time.Since(startTime).Milliseconds(),
+	})
+	c.JSON(http.StatusOK, response)
+	for _, name := range names {
+		header[name] = received[name]
+	}
+}
+
+// containsName 判断字段名列表是否包含name
+func containsName(names []string, name string) bool {
+	for _, item := range names {
+		if item == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sleepContext 等待指定时间，客户端断开时返回false
+func sleepContext(c *gin.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
@@ -0,0 +1,525 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxEdgeReceived = 1000 // 每个会话记录的客户端帧上限
+
+// EdgeCase 协议边界测试场景
+type EdgeCase struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	run         func(rc *rawConn, p edgeParams) (closed bool, err error)
+}
+
+// CloseCode RFC 6455关闭状态码
+type CloseCode struct {
+	Code     int    `json:"code"`
+	Name     string `json:"name"`
+	Sendable bool   `json:"sendable"` // RFC规定不得出现在线上的状态码为false
+}
+
+// EdgeSession 一次边界测试会话的记录
+type EdgeSession struct {
+	ID         string        `json:"id"`
+	Case       string        `json:"case"`
+	RemoteAddr string        `json:"remote_addr"`
+	StartedAt  int64         `json:"started_at"`
+	EndedAt    int64         `json:"ended_at,omitempty"`
+	FramesSent int           `json:"frames_sent"`
+	Received   []FrameRecord `json:"received"`
+	Dropped    int           `json:"received_dropped,omitempty"` // 超出maxEdgeReceived未记录的帧数
+	Error      string        `json:"error,omitempty"`
+}
+
+// edgeParams 场景参数
+type edgeParams struct {
+	fragments int
+	size      int
+	delay     time.Duration
+	code      int
+	reason    string
+	repeat    int
+	ping      bool
+	binary    bool
+}
+
+// CloseCodes RFC 6455及IANA注册的关闭状态码，附带若干非法值
+var CloseCodes = []CloseCode{
+	{999, "Invalid (below range)", false},
+	{1000, "Normal Closure", true},
+	{1001, "Going Away", true},
+	{1002, "Protocol Error", true},
+	{1003, "Unsupported Data", true},
+	{1004, "Reserved", false},
+	{1005, "No Status Rcvd", false},
+	{1006, "Abnormal Closure", false},
+	{1007, "Invalid Frame Payload Data", true},
+	{1008, "Policy Violation", true},
+	{1009, "Message Too Big", true},
+	{1010, "Mandatory Extension", true},
+	{1011, "Internal Server Error", true},
+	{1012, "Service Restart", true},
+	{1013, "Try Again Later", true},
+	{1014, "Bad Gateway", true},
+	{1015, "TLS Handshake", false},
+	{2999, "Reserved (unassigned)", false},
+	{3000, "Unauthorized (IANA registered)", true},
+	{4000, "Private Use", true},
+	{5000, "Invalid (above range)", false},
+}
+
+var edgeCases = []EdgeCase{
+	{Name: "fragmented", Description: "文本消息拆分为多个分片，分片之间穿插Ping帧", run: runFragmented},
+	{Name: "fragmented-binary", Description: "二进制消息拆分为多个分片，分片之间穿插Ping帧", run: runFragmented},
+	{Name: "empty-fragments", Description: "分片之间插入零长度的continuation帧", run: runEmptyFragments},
+	{Name: "interleaved", Description: "分片消息未结束时发送新的数据帧（协议违规）", run: runInterleaved},
+	{Name: "orphan-continuation", Description: "没有起始帧的continuation帧（协议违规）", run: runOrphanContinuation},
+	{Name: "oversized", Description: "单个超大数据帧（64位长度字段），大小由size指定", run: runOversized},
+	{Name: "oversized-control", Description: "负载超过125字节的Ping控制帧（协议违规）", run: runOversizedControl},
+	{Name: "fragmented-control", Description: "FIN=0的Ping控制帧（协议违规）", run: runFragmentedControl},
+	{Name: "unsolicited-pong", Description: "未收到Ping时主动发送Pong帧", run: runUnsolicitedPong},
+	{Name: "ping-flood", Description: "连续发送大量Ping帧，统计客户端返回的Pong", run: runPingFlood},
+	{Name: "reserved-bits", Description: "未协商扩展时设置RSV1/RSV2/RSV3位", run: runReservedBits},
+	{Name: "reserved-opcode", Description: "使用保留操作码0x3和0xB", run: runReservedOpcode},
+	{Name: "masked", Description: "服务端发送带掩码的帧（协议违规）", run: runMasked},
+	{Name: "invalid-utf8", Description: "包含非法UTF-8的文本帧", run: runInvalidUTF8},
+	{Name: "close", Description: "发送指定code和reason的关闭帧", run: runClose},
+	{Name: "close-empty", Description: "发送无负载的关闭帧", run: runCloseEmpty},
+	{Name: "close-long-reason", Description: "关闭原因超过123字节的关闭帧（协议违规）", run: runCloseLongReason},
+	{Name: "close-then-data", Description: "发送关闭帧后继续发送数据帧（协议违规）", run: runCloseThenData},
+}
+
+// 最近的边界测试会话
+var (
+	edgeSessions     = make([]*EdgeSession, 0, maxClosedClients)
+	edgeSessionsLock sync.RWMutex
+)
+
+// HandleEdgeCase 协议边界测试入口，非升级请求返回场景索引
+func HandleEdgeCase(c *gin.Context) {
+	if !isUpgradeRequest(c.Request) {
+		handleEdgeIndex(c)
+		return
+	}
+
+	caseName := c.DefaultQuery("case", "fragmented")
+	edgeCase, ok := findEdgeCase(caseName)
+	if !ok {
+		response := routes.CreateErrorResponse(400, "未知的测试场景: "+caseName)
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	params := parseEdgeParams(c, caseName)
+
+	rc, err := acceptRaw(c, nil)
+	if err != nil {
+		if !c.Writer.Written() {
+			response := routes.CreateErrorResponse(400, "WebSocket握手失败: "+err.Error())
+			c.JSON(http.StatusBadRequest, response)
+		}
+		return
+	}
+	defer func() { _ = rc.close() }()
+
+	session := &EdgeSession{
+		ID:         fmt.Sprintf("edge_%d", time.Now().UnixNano()),
+		Case:       caseName,
+		RemoteAddr: rc.conn.RemoteAddr().String(),
+		StartedAt:  time.Now().Unix(),
+		Received:   make([]FrameRecord, 0),
+	}
+	saveEdgeSession(session)
+
+	// 处理函数返回后读取协程不再阻塞在发送上
+	done := make(chan struct{})
+	defer close(done)
+	received := make(chan frame, 64)
+	go readEdgeFrames(rc, received, done)
+
+	closed, err := edgeCase.run(rc, params)
+	if err != nil {
+		finishEdgeSession(session, rc, err)
+		log.Printf("WebSocket边界测试(%s)发送失败: %v", caseName, err)
+		return
+	}
+
+	if !closed {
+		summary := map[string]interface{}{
+			"type":       "summary",
+			"session_id": session.ID,
+			"case":       caseName,
+			"message":    "场景帧已发送完毕，发送 {\"type\":\"report\"} 获取服务端收到的帧",
+		}
+		payload, _ := json.Marshal(summary)
+		_ = rc.writeFrame(frame{fin: true, opcode: opText, payload: payload})
+	}
+
+	serveEdgeAfterScenario(rc, session, received, closed)
+	finishEdgeSession(session, rc, nil)
+}
+
+// handleEdgeIndex 返回所有场景、关闭码和参数说明
+func handleEdgeIndex(c *gin.Context) {
+	result := map[string]interface{}{
+		"cases":       edgeCases,
+		"close_codes": CloseCodes,
+		"params": map[string]string{
+			"case":      "场景名称，默认fragmented",
+			"fragments": "分片数量(2-1000)，默认4",
+			"size":      "oversized场景的帧大小(字节)，默认16MB，最大32MB",
+			"delay":     "帧之间的延迟(毫秒，0-5000)，默认0",
+			"code":      "close场景的关闭码(0-65535)，默认1000",
+			"reason":    "close场景的关闭原因，默认为状态码名称",
+			"repeat":    "重复次数(1-1000)，默认5",
+			"ping":      "分片之间是否穿插Ping帧，默认true",
+		},
+		"sessions": "/ws/edge/sessions",
+	}
+
+	response := routes.CreateSuccessResponse("WebSocket协议边界测试场景", result)
+	c.JSON(http.StatusOK, response)
+}
+
+// handleEdgeSessions 返回最近的边界测试会话记录
+func handleEdgeSessions(c *gin.Context) {
+	edgeSessionsLock.RLock()
+	defer edgeSessionsLock.RUnlock()
+
+	id := c.Param("id")
+	if id != "" {
+		for _, session := range edgeSessions {
+			if session.ID == id {
+				response := routes.CreateSuccessResponse("边界测试会话", session)
+				c.JSON(http.StatusOK, response)
+				return
+			}
+		}
+		response := routes.CreateErrorResponse(404, "会话不存在: "+id)
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	response := routes.CreateSuccessResponse("边界测试会话列表", edgeSessions)
+	c.JSON(http.StatusOK, response)
+}
+
+// parseEdgeParams 解析场景参数
+func parseEdgeParams(c *gin.Context, caseName string) edgeParams {
+	p := edgeParams{
+		fragments: queryInt(c, "fragments", 4, 2, 1000),
+		size:      queryInt(c, "size", 16*1024*1024, 1, maxMessageSize),
+		delay:     time.Duration(queryInt(c, "delay", 0, 0, 5000)) * time.Millisecond,
+		code:      queryInt(c, "code", 1000, 0, 65535),
+		repeat:    queryInt(c, "repeat", 5, 1, 1000),
+		ping:      c.DefaultQuery("ping", "true") != "false",
+		binary:    caseName == "fragmented-binary" || c.Query("type") == "binary",
+	}
+
+	p.reason = c.Query("reason")
+	if p.reason == "" {
+		p.reason = closeCodeName(p.code)
+	}
+	return p
+}
+
+// queryInt 解析整数查询参数，超出范围时返回默认值
+func queryInt(c *gin.Context, name string, defaultValue, minValue, maxValue int) int {
+	value, err := strconv.Atoi(c.Query(name))
+	if err != nil || value < minValue || value > maxValue {
+		return defaultValue
+	}
+	return value
+}
+
+// readEdgeFrames 持续读取客户端帧，done关闭后退出
+func readEdgeFrames(rc *rawConn, received chan<- frame, done <-chan struct{}) {
+	defer close(received)
+	for {
+		_ = rc.conn.SetReadDeadline(time.Now().Add(readTimeout))
+		f, err := rc.readFrame()
+		if err != nil {
+			return
+		}
+		select {
+		case received <- f:
+		case <-done:
+			return
+		}
+	}
+}
+
+// serveEdgeAfterScenario 场景结束后记录客户端帧：应答Ping、回显数据帧、响应report命令
+func serveEdgeAfterScenario(rc *rawConn, session *EdgeSession, received <-chan frame, closed bool) {
+	// 已发送关闭帧时只等待客户端的关闭应答
+	var closeTimeout <-chan time.Time
+	if closed {
+		closeTimeout = time.After(5 * time.Second)
+	}
+
+	for {
+		select {
+		case <-closeTimeout:
+			return
+		case f, ok := <-received:
+			if !ok {
+				return
+			}
+			recordEdgeFrame(session, f)
+
+			switch f.opcode {
+			case opClose:
+				if !closed {
+					_ = rc.writeFrame(frame{fin: true, opcode: opClose, payload: f.payload})
+				}
+				return
+			case opPing:
+				_ = rc.writeFrame(frame{fin: true, opcode: opPong, payload: f.payload})
+			case opText:
+				if closed {
+					continue
+				}
+				if bytes.Contains(f.payload, []byte(`"report"`)) {
+					edgeSessionsLock.RLock()
+					payload, _ := json.Marshal(map[string]interface{}{"type": "report", "session": session})
+					edgeSessionsLock.RUnlock()
+					_ = rc.writeFrame(frame{fin: true, opcode: opText, payload: payload})
+					continue
+				}
+				_ = rc.writeFrame(frame{fin: f.fin, opcode: f.opcode, payload: f.payload})
+			case opBinary, opContinuation:
+				if !closed {
+					_ = rc.writeFrame(frame{fin: f.fin, opcode: f.opcode, payload: f.payload})
+				}
+			}
+		}
+	}
+}
+
+// saveEdgeSession 保存会话，超出上限时淘汰最旧的记录
+func saveEdgeSession(session *EdgeSession) {
+	edgeSessionsLock.Lock()
+	defer edgeSessionsLock.Unlock()
+
+	if len(edgeSessions) >= maxClosedClients {
+		edgeSessions = edgeSessions[1:]
+	}
+	edgeSessions = append(edgeSessions, session)
+}
+
+// recordEdgeFrame 记录收到的客户端帧
+func recordEdgeFrame(session *EdgeSession, f frame) {
+	edgeSessionsLock.Lock()
+	if len(session.Received) < maxEdgeReceived {
+		session.Received = append(session.Received, f.record())
+	} else {
+		session.Dropped++
+	}
+	edgeSessionsLock.Unlock()
+}
+
+// finishEdgeSession 结束会话
+func finishEdgeSession(session *EdgeSession, rc *rawConn, err error) {
+	framesSent := rc.framesSent()
+
+	edgeSessionsLock.Lock()
+	defer edgeSessionsLock.Unlock()
+
+	session.EndedAt = time.Now().Unix()
+	session.FramesSent = framesSent
+	if err != nil {
+		session.Error = err.Error()
+	}
+}
+
+// findEdgeCase 按名称查找场景
+func findEdgeCase(name string) (EdgeCase, bool) {
+	for _, edgeCase := range edgeCases {
+		if edgeCase.Name == name {
+			return edgeCase, true
+		}
+	}
+	return EdgeCase{}, false
+}
+
+// closeCodeName 获取关闭码名称
+func closeCodeName(code int) string {
+	index := sort.Search(len(CloseCodes), func(i int) bool { return CloseCodes[i].Code >= code })
+	if index < len(CloseCodes) && CloseCodes[index].Code == code {
+		return CloseCodes[index].Name
+	}
+	return fmt.Sprintf("Close %d", code)
+}
+
+// send 发送帧并按需延迟
+func send(rc *rawConn, p edgeParams, frames ...frame) error {
+	for _, f := range frames {
+		if err := rc.writeFrame(f); err != nil {
+			return err
+		}
+		if p.delay > 0 {
+			time.Sleep(p.delay)
+		}
+	}
+	return nil
+}
+
+// splitPayload 将负载平均拆分为n段
+func splitPayload(payload []byte, n int) [][]byte {
+	if n > len(payload) {
+		n = len(payload)
+	}
+	parts := make([][]byte, 0, n)
+	size := len(payload) / n
+	for i := 0; i < n; i++ {
+		end := (i + 1) * size
+		if i == n-1 {
+			end = len(payload)
+		}
+		parts = append(parts, payload[i*size:end])
+	}
+	return parts
+}
+
+func runFragmented(rc *rawConn, p edgeParams) (bool, error) {
+	opcode := opText
+	payload := bytes.Repeat([]byte("fragment-data-"), p.fragments*4)
+	if p.binary {
+		opcode = opBinary
+		payload = generateBinary(p.fragments * 64)
+	}
+
+	parts := splitPayload(payload, p.fragments)
+	for i, part := range parts {
+		f := frame{fin: i == len(parts)-1, opcode: opContinuation, payload: part}
+		if i == 0 {
+			f.opcode = opcode
+		}
+		if err := send(rc, p, f); err != nil {
+			return false, err
+		}
+		if p.ping && i < len(parts)-1 {
+			ping := frame{fin: true, opcode: opPing, payload: []byte(fmt.Sprintf("ping-%d", i+1))}
+			if err := send(rc, p, ping); err != nil {
+				return false, err
+			}
+		}
+	}
+	return false, nil
+}
+
+func runEmptyFragments(rc *rawConn, p edgeParams) (bool, error) {
+	frames := []frame{{fin: false, opcode: opText, payload: []byte("start-")}}
+	for i := 0; i < p.repeat; i++ {
+		frames = append(frames, frame{fin: false, opcode: opContinuation, payload: nil})
+	}
+	frames = append(frames, frame{fin: true, opcode: opContinuation, payload: []byte("end")})
+	return false, send(rc, p, frames...)
+}
+
+func runInterleaved(rc *rawConn, p edgeParams) (bool, error) {
+	return false, send(rc, p,
+		frame{fin: false, opcode: opText, payload: []byte("first-message-part1-")},
+		frame{fin: true, opcode: opText, payload: []byte("second-message")},
+		frame{fin: true, opcode: opContinuation, payload: []byte("first-message-part2")},
+	)
+}
+
+func runOrphanContinuation(rc *rawConn, p edgeParams) (bool, error) {
+	return false, send(rc, p, frame{fin: true, opcode: opContinuation, payload: []byte("orphan")})
+}
+
+func runOversized(rc *rawConn, p edgeParams) (bool, error) {
+	if p.binary {
+		return false, send(rc, p, frame{fin: true, opcode: opBinary, payload: generateBinary(p.size)})
+	}
+	return false, send(rc, p, frame{fin: true, opcode: opText, payload: bytes.Repeat([]byte("x"), p.size)})
+}
+
+func runOversizedControl(rc *rawConn, p edgeParams) (bool, error) {
+	return false, send(rc, p, frame{fin: true, opcode: opPing, payload: bytes.Repeat([]byte("p"), 200)})
+}
+
+func runFragmentedControl(rc *rawConn, p edgeParams) (bool, error) {
+	return false, send(rc, p,
+		frame{fin: false, opcode: opPing, payload: []byte("ping-part1")},
+		frame{fin: true, opcode: opContinuation, payload: []byte("ping-part2")},
+	)
+}
+
+func runUnsolicitedPong(rc *rawConn, p edgeParams) (bool, error) {
+	for i := 0; i < p.repeat; i++ {
+		pong := frame{fin: true, opcode: opPong, payload: []byte(fmt.Sprintf("unsolicited-%d", i+1))}
+		if err := send(rc, p, pong); err != nil {
+			return false, err
+		}
+	}
+	return false, send(rc, p, frame{fin: true, opcode: opText, payload: []byte("after-pongs")})
+}
+
+func runPingFlood(rc *rawConn, p edgeParams) (bool, error) {
+	for i := 0; i < p.repeat; i++ {
+		ping := frame{fin: true, opcode: opPing, payload: []byte(strconv.Itoa(i + 1))}
+		if err := send(rc, p, ping); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+func runReservedBits(rc *rawConn, p edgeParams) (bool, error) {
+	return false, send(rc, p,
+		frame{fin: true, rsv: rsv1Bit, opcode: opText, payload: []byte("rsv1")},
+		frame{fin: true, rsv: rsv2Bit, opcode: opText, payload: []byte("rsv2")},
+		frame{fin: true, rsv: rsv3Bit, opcode: opText, payload: []byte("rsv3")},
+	)
+}
+
+func runReservedOpcode(rc *rawConn, p edgeParams) (bool, error) {
+	return false, send(rc, p,
+		frame{fin: true, opcode: 0x3, payload: []byte("reserved-data-opcode")},
+		frame{fin: true, opcode: 0xB, payload: []byte("reserved-control-opcode")},
+	)
+}
+
+func runMasked(rc *rawConn, p edgeParams) (bool, error) {
+	return false, send(rc, p, frame{fin: true, opcode: opText, masked: true, payload: []byte("masked-from-server")})
+}
+
+func runInvalidUTF8(rc *rawConn, p edgeParams) (bool, error) {
+	return false, send(rc, p, frame{fin: true, opcode: opText, payload: []byte{'b', 'a', 'd', 0xC3, 0x28, 0xFF}})
+}
+
+func runClose(rc *rawConn, p edgeParams) (bool, error) {
+	return true, send(rc, p, frame{fin: true, opcode: opClose, payload: closePayload(p.code, p.reason)})
+}
+
+func runCloseEmpty(rc *rawConn, p edgeParams) (bool, error) {
+	return true, send(rc, p, frame{fin: true, opcode: opClose})
+}
+
+func runCloseLongReason(rc *rawConn, p edgeParams) (bool, error) {
+	reason := string(bytes.Repeat([]byte("r"), 200))
+	return true, send(rc, p, frame{fin: true, opcode: opClose, payload: closePayload(p.code, reason)})
+}
+
+func runCloseThenData(rc *rawConn, p edgeParams) (bool, error) {
+	return true, send(rc, p,
+		frame{fin: true, opcode: opClose, payload: closePayload(p.code, p.reason)},
+		frame{fin: true, opcode: opText, payload: []byte("data-after-close")},
+	)
+}
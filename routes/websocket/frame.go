@@ -0,0 +1,304 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1" // #nosec G505 - RFC 6455握手规定使用SHA-1，非安全用途
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 原始帧层：绕过gorilla直接读写RFC 6455帧，用于构造协议边界情况
+
+// WebSocket操作码
+const (
+	opContinuation byte = 0x0
+	opText         byte = 0x1
+	opBinary       byte = 0x2
+	opClose        byte = 0x8
+	opPing         byte = 0x9
+	opPong         byte = 0xA
+)
+
+// RSV位
+const (
+	rsv1Bit byte = 0x40
+	rsv2Bit byte = 0x20
+	rsv3Bit byte = 0x10
+)
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// frame 单个WebSocket帧
+type frame struct {
+	fin     bool
+	rsv     byte
+	opcode  byte
+	masked  bool
+	payload []byte
+}
+
+// FrameRecord 收到的帧记录
+type FrameRecord struct {
+	Opcode     string `json:"opcode"`
+	Fin        bool   `json:"fin"`
+	RSV1       bool   `json:"rsv1"`
+	RSV2       bool   `json:"rsv2"`
+	RSV3       bool   `json:"rsv3"`
+	Masked     bool   `json:"masked"`
+	Length     int    `json:"length"`
+	Preview    string `json:"preview"`
+	ReceivedAt int64  `json:"received_at"`
+}
+
+// rawConn 已完成握手的原始WebSocket连接
+type rawConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writer  *bufio.Writer
+	writeMu sync.Mutex
+	sent    int
+}
+
+// acceptRaw 校验握手请求并手动写出101响应，extraHeaders会附加到响应中
+func acceptRaw(c *gin.Context, extraHeaders http.Header) (*rawConn, error) {
+	if !isUpgradeRequest(c.Request) {
+		return nil, errors.New("不是WebSocket升级请求")
+	}
+	if c.GetHeader("Sec-WebSocket-Version") != "13" {
+		return nil, errors.New("不支持的Sec-WebSocket-Version")
+	}
+	key := c.GetHeader("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, errors.New("缺少Sec-WebSocket-Key")
+	}
+
+	conn, rw, err := routes.HijackConnection(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var handshake strings.Builder
+	handshake.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	handshake.WriteString("Upgrade: websocket\r\n")
+	handshake.WriteString("Connection: Upgrade\r\n")
+	handshake.WriteString("Sec-WebSocket-Accept: " + computeAcceptKey(key) + "\r\n")
+	for name, values := range extraHeaders {
+		for _, value := range values {
+			handshake.WriteString(name + ": " + value + "\r\n")
+		}
+	}
+	handshake.WriteString("\r\n")
+
+	_ = conn.SetDeadline(time.Time{})
+	if _, err := rw.WriteString(handshake.String()); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &rawConn{conn: conn, reader: rw.Reader, writer: rw.Writer}, nil
+}
+
+// isUpgradeRequest 判断是否为WebSocket升级请求
+func isUpgradeRequest(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+// headerContainsToken 判断逗号分隔的请求头中是否包含指定token
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// computeAcceptKey 计算Sec-WebSocket-Accept
+func computeAcceptKey(key string) string {
+	h := sha1.New() // #nosec G401 - RFC 6455握手规定使用SHA-1
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// writeFrame 写出一个帧并立即刷新，不做任何合法性校验
+func (rc *rawConn) writeFrame(f frame) error {
+	rc.writeMu.Lock()
+	defer rc.writeMu.Unlock()
+
+	header := make([]byte, 0, 14)
+	first := f.rsv | (f.opcode & 0x0F)
+	if f.fin {
+		first |= 0x80
+	}
+	header = append(header, first)
+
+	var maskBit byte
+	if f.masked {
+		maskBit = 0x80
+	}
+
+	length := len(f.payload)
+	switch {
+	case length <= 125:
+		header = append(header, maskBit|byte(length))
+	case length <= 0xFFFF:
+		header = append(header, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(header[len(header)-2:], uint16(length))
+	default:
+		header = append(header, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[len(header)-8:], uint64(length))
+	}
+
+	payload := f.payload
+	if f.masked {
+		maskKey := make([]byte, 4)
+		if _, err := rand.Read(maskKey); err != nil {
+			return err
+		}
+		header = append(header, maskKey...)
+		payload = make([]byte, len(f.payload))
+		for i := range f.payload {
+			payload[i] = f.payload[i] ^ maskKey[i%4]
+		}
+	}
+
+	_ = rc.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := rc.writer.Write(header); err != nil {
+		return err
+	}
+	if _, err := rc.writer.Write(payload); err != nil {
+		return err
+	}
+	if err := rc.writer.Flush(); err != nil {
+		return err
+	}
+	rc.sent++
+	return nil
+}
+
+// framesSent 获取已发送的帧数
+func (rc *rawConn) framesSent() int {
+	rc.writeMu.Lock()
+	defer rc.writeMu.Unlock()
+	return rc.sent
+}
+
+// readFrame 读取一个帧并解除掩码
+func (rc *rawConn) readFrame() (frame, error) {
+	var f frame
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(rc.reader, header); err != nil {
+		return f, err
+	}
+
+	f.fin = header[0]&0x80 != 0
+	f.rsv = header[0] & (rsv1Bit | rsv2Bit | rsv3Bit)
+	f.opcode = header[0] & 0x0F
+	f.masked = header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(rc.reader, ext); err != nil {
+			return f, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(rc.reader, ext); err != nil {
+			return f, err
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+	if length > maxMessageSize {
+		return f, fmt.Errorf("帧过大: %d 字节", length)
+	}
+
+	var maskKey []byte
+	if f.masked {
+		maskKey = make([]byte, 4)
+		if _, err := io.ReadFull(rc.reader, maskKey); err != nil {
+			return f, err
+		}
+	}
+
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(rc.reader, f.payload); err != nil {
+		return f, err
+	}
+	if maskKey != nil {
+		for i := range f.payload {
+			f.payload[i] ^= maskKey[i%4]
+		}
+	}
+	return f, nil
+}
+
+// close 关闭底层连接
+func (rc *rawConn) close() error {
+	return rc.conn.Close()
+}
+
+// record 将帧转换为记录
+func (f frame) record() FrameRecord {
+	preview := f.payload[:minInt(64, len(f.payload))]
+	return FrameRecord{
+		Opcode:     opcodeName(f.opcode),
+		Fin:        f.fin,
+		RSV1:       f.rsv&rsv1Bit != 0,
+		RSV2:       f.rsv&rsv2Bit != 0,
+		RSV3:       f.rsv&rsv3Bit != 0,
+		Masked:     f.masked,
+		Length:     len(f.payload),
+		Preview:    fmt.Sprintf("%q", preview),
+		ReceivedAt: time.Now().UnixMilli(),
+	}
+}
+
+// opcodeName 获取操作码名称
+func opcodeName(opcode byte) string {
+	switch opcode {
+	case opContinuation:
+		return "continuation"
+	case opText:
+		return "text"
+	case opBinary:
+		return "binary"
+	case opClose:
+		return "close"
+	case opPing:
+		return "ping"
+	case opPong:
+		return "pong"
+	default:
+		return fmt.Sprintf("reserved(0x%X)", opcode)
+	}
+}
+
+// closePayload 构造关闭帧负载
+func closePayload(code int, reason string) []byte {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return append(payload, reason...)
+}
@@ -0,0 +1,264 @@
+package websocket
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ConnStats 单个WebSocket连接的统计信息
+type ConnStats struct {
+	ID               string `json:"id"`
+	Endpoint         string `json:"endpoint"`
+	RemoteAddr       string `json:"remote_addr"`
+	Subprotocol      string `json:"subprotocol"`
+	ConnectedAt      int64  `json:"connected_at"`
+	ClosedAt         int64  `json:"closed_at,omitempty"`
+	DurationMs       int64  `json:"duration_ms"`
+	MessagesSent     int64  `json:"messages_sent"`
+	MessagesReceived int64  `json:"messages_received"`
+	BytesSent        int64  `json:"bytes_sent"`
+	BytesReceived    int64  `json:"bytes_received"`
+	TextFrames       int64  `json:"text_frames"`
+	BinaryFrames     int64  `json:"binary_frames"`
+	PingsSent        int64  `json:"pings_sent"`
+	PongsReceived    int64  `json:"pongs_received"`
+	LastRTTMs        int64  `json:"last_rtt_ms"`
+	CloseCode        int    `json:"close_code,omitempty"`
+	CloseReason      string `json:"close_reason,omitempty"`
+}
+
+// client 封装一个WebSocket连接，保证同一时刻只有一个写入者
+type client struct {
+	id          string
+	endpoint    string
+	room        string
+	name        string
+	conn        *websocket.Conn
+	writeMu     sync.Mutex
+	connectedAt time.Time
+
+	messagesSent     int64
+	messagesReceived int64
+	bytesSent        int64
+	bytesReceived    int64
+	textFrames       int64
+	binaryFrames     int64
+	pingsSent        int64
+	pongsReceived    int64
+	lastRTTMs        int64
+	lastPingAt       int64
+
+	closeCode   int
+	closeReason string
+	closedAt    time.Time
+}
+
+// send 发送一条数据消息并记录统计
+func (cl *client) send(messageType int, data []byte) error {
+	cl.writeMu.Lock()
+	defer cl.writeMu.Unlock()
+
+	if err := cl.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	if err := cl.conn.WriteMessage(messageType, data); err != nil {
+		return err
+	}
+
+	atomic.AddInt64(&cl.messagesSent, 1)
+	atomic.AddInt64(&cl.bytesSent, int64(len(data)))
+	return nil
+}
+
+// sendJSON 以文本帧发送JSON消息
+func (cl *client) sendJSON(v interface{}) error {
+	cl.writeMu.Lock()
+	defer cl.writeMu.Unlock()
+
+	if err := cl.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	w, err := cl.conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+	counter := &countingWriter{w: w}
+	if err := jsonEncode(counter, v); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	atomic.AddInt64(&cl.messagesSent, 1)
+	atomic.AddInt64(&cl.bytesSent, counter.n)
+	return nil
+}
+
+// ping 发送Ping控制帧，RTT在收到Pong时计算
+func (cl *client) ping() error {
+	now := time.Now()
+	payload := []byte(fmt.Sprintf("%d", now.UnixNano()))
+	if err := cl.conn.WriteControl(websocket.PingMessage, payload, now.Add(writeTimeout)); err != nil {
+		return err
+	}
+	atomic.AddInt64(&cl.pingsSent, 1)
+	atomic.StoreInt64(&cl.lastPingAt, now.UnixNano())
+	return nil
+}
+
+// recordReceived 记录收到的数据帧
+func (cl *client) recordReceived(messageType int, size int) {
+	atomic.AddInt64(&cl.messagesReceived, 1)
+	atomic.AddInt64(&cl.bytesReceived, int64(size))
+	if messageType == websocket.BinaryMessage {
+		atomic.AddInt64(&cl.binaryFrames, 1)
+	} else {
+		atomic.AddInt64(&cl.textFrames, 1)
+	}
+}
+
+// recordPong 记录收到的Pong帧
+func (cl *client) recordPong() {
+	atomic.AddInt64(&cl.pongsReceived, 1)
+	if sentAt := atomic.LoadInt64(&cl.lastPingAt); sentAt > 0 {
+		atomic.StoreInt64(&cl.lastRTTMs, (time.Now().UnixNano()-sentAt)/int64(time.Millisecond))
+	}
+}
+
+// stats 获取连接统计快照
+func (cl *client) stats() ConnStats {
+	end := time.Now()
+	stats := ConnStats{
+		ID:               cl.id,
+		Endpoint:         cl.endpoint,
+		RemoteAddr:       cl.conn.RemoteAddr().String(),
+		Subprotocol:      cl.conn.Subprotocol(),
+		ConnectedAt:      cl.connectedAt.Unix(),
+		MessagesSent:     atomic.LoadInt64(&cl.messagesSent),
+		MessagesReceived: atomic.LoadInt64(&cl.messagesReceived),
+		BytesSent:        atomic.LoadInt64(&cl.bytesSent),
+		BytesReceived:    atomic.LoadInt64(&cl.bytesReceived),
+		TextFrames:       atomic.LoadInt64(&cl.textFrames),
+		BinaryFrames:     atomic.LoadInt64(&cl.binaryFrames),
+		PingsSent:        atomic.LoadInt64(&cl.pingsSent),
+		PongsReceived:    atomic.LoadInt64(&cl.pongsReceived),
+		LastRTTMs:        atomic.LoadInt64(&cl.lastRTTMs),
+	}
+
+	hubLock.RLock()
+	if !cl.closedAt.IsZero() {
+		end = cl.closedAt
+		stats.ClosedAt = cl.closedAt.Unix()
+		stats.CloseCode = cl.closeCode
+		stats.CloseReason = cl.closeReason
+	}
+	hubLock.RUnlock()
+
+	stats.DurationMs = end.Sub(cl.connectedAt).Milliseconds()
+	return stats
+}
+
+// 全局连接注册表
+var (
+	activeClients = make(map[string]*client)
+	closedClients = make([]*client, 0, maxClosedClients)
+	hubLock       sync.RWMutex
+	connCounter   int64
+)
+
+// register 注册新连接
+func register(conn *websocket.Conn, endpoint string) *client {
+	id := fmt.Sprintf("ws_%d_%d", time.Now().UnixNano(), atomic.AddInt64(&connCounter, 1))
+	cl := &client{
+		id:          id,
+		endpoint:    endpoint,
+		conn:        conn,
+		connectedAt: time.Now(),
+	}
+
+	hubLock.Lock()
+	activeClients[id] = cl
+	hubLock.Unlock()
+
+	conn.SetPongHandler(func(string) error {
+		cl.recordPong()
+		return conn.SetReadDeadline(time.Now().Add(readTimeout))
+	})
+	conn.SetCloseHandler(func(code int, text string) error {
+		hubLock.Lock()
+		cl.closeCode = code
+		cl.closeReason = text
+		hubLock.Unlock()
+		message := websocket.FormatCloseMessage(code, "")
+		_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeTimeout))
+		return nil
+	})
+
+	return cl
+}
+
+// unregister 注销连接并保留最近关闭连接的统计
+func unregister(cl *client) {
+	hubLock.Lock()
+	delete(activeClients, cl.id)
+	cl.closedAt = time.Now()
+	if len(closedClients) >= maxClosedClients {
+		closedClients = closedClients[1:]
+	}
+	closedClients = append(closedClients, cl)
+	hubLock.Unlock()
+
+	_ = cl.conn.Close()
+}
+
+// peers 获取指定端点（和房间）下的所有活动连接
+func peers(endpoint, room string) []*client {
+	hubLock.RLock()
+	defer hubLock.RUnlock()
+
+	result := make([]*client, 0)
+	for _, cl := range activeClients {
+		if cl.endpoint == endpoint && cl.room == room {
+			result = append(result, cl)
+		}
+	}
+	return result
+}
+
+// broadcastJSON 向指定端点（和房间）下的所有连接广播JSON消息，返回成功投递数
+func broadcastJSON(endpoint, room string, v interface{}) int {
+	delivered := 0
+	for _, peer := range peers(endpoint, room) {
+		if err := peer.sendJSON(v); err == nil {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+// snapshot 获取所有活动连接和最近关闭连接的统计
+func snapshot() (active []ConnStats, closed []ConnStats) {
+	hubLock.RLock()
+	activeList := make([]*client, 0, len(activeClients))
+	for _, cl := range activeClients {
+		activeList = append(activeList, cl)
+	}
+	closedList := make([]*client, len(closedClients))
+	copy(closedList, closedClients)
+	hubLock.RUnlock()
+
+	active = make([]ConnStats, 0, len(activeList))
+	for _, cl := range activeList {
+		active = append(active, cl.stats())
+	}
+	closed = make([]ConnStats, 0, len(closedList))
+	for _, cl := range closedList {
+		closed = append(closed, cl.stats())
+	}
+	return active, closed
+}
@@ -0,0 +1,469 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 同步刷新产生的块尾，发送时去掉、接收时补回（RFC 7692 7.2.1）
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// 补回块尾后追加一个空的最终块，使解压器能正常结束
+var inflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+// ExtensionOffer 客户端在Sec-WebSocket-Extensions中提出的一个扩展
+type ExtensionOffer struct {
+	Name   string            `json:"name"`
+	Params map[string]string `json:"params"`
+}
+
+// DeflateParams 协商后的permessage-deflate参数
+type DeflateParams struct {
+	ServerMaxWindowBits     int  `json:"server_max_window_bits"`
+	ClientMaxWindowBits     int  `json:"client_max_window_bits"`
+	ServerNoContextTakeover bool `json:"server_no_context_takeover"`
+	ClientNoContextTakeover bool `json:"client_no_context_takeover"`
+	HuffmanOnly             bool `json:"huffman_only"` // 窗口小于32KB时只用Huffman编码，避免越界的回溯引用
+}
+
+// NegotiationResult 握手协商结果
+type NegotiationResult struct {
+	RequestedExtensions string           `json:"requested_extensions"`
+	RequestedProtocols  []string         `json:"requested_protocols"`
+	Offers              []ExtensionOffer `json:"offers"`
+	ResponseExtensions  string           `json:"response_extensions"`
+	Deflate             *DeflateParams   `json:"deflate"`
+	Subprotocol         string           `json:"subprotocol"`
+	ProtocolMode        string           `json:"protocol_mode"`
+	DeflateMode         string           `json:"deflate_mode"`
+}
+
+// deflateConn 带压缩能力的原始连接
+type deflateConn struct {
+	*rawConn
+	params     *DeflateParams
+	compressor *flate.Writer
+	compressed bytes.Buffer
+	inflateDic []byte
+
+	messagesIn      int
+	compressedIn    int
+	wireBytesIn     int
+	plainBytesIn    int
+	messagesOut     int
+	wireBytesOut    int
+	plainBytesOut   int
+	inflateFailures int
+}
+
+// handleNegotiate 压缩扩展和子协议协商测试
+func handleNegotiate(c *gin.Context) {
+	if !isUpgradeRequest(c.Request) {
+		handleNegotiateIndex(c)
+		return
+	}
+
+	result, status, err := negotiate(c)
+	if err != nil {
+		response := routes.CreateErrorResponse(status, err.Error())
+		response.Data = result
+		c.JSON(status, response)
+		return
+	}
+
+	extraHeaders := http.Header{}
+	if result.ResponseExtensions != "" {
+		extraHeaders.Set("Sec-WebSocket-Extensions", result.ResponseExtensions)
+	}
+	if result.Subprotocol != "" {
+		extraHeaders.Set("Sec-WebSocket-Protocol", result.Subprotocol)
+	}
+
+	rc, err := acceptRaw(c, extraHeaders)
+	if err != nil {
+		if !c.Writer.Written() {
+			response := routes.CreateErrorResponse(400, "WebSocket握手失败: "+err.Error())
+			c.JSON(http.StatusBadRequest, response)
+		}
+		return
+	}
+	defer func() { _ = rc.close() }()
+
+	dc := &deflateConn{rawConn: rc, params: result.Deflate}
+	if err := dc.sendJSON(map[string]interface{}{"type": "negotiation", "result": result}); err != nil {
+		return
+	}
+	dc.echoLoop(result)
+}
+
+// handleNegotiateIndex 返回协商参数说明
+func handleNegotiateIndex(c *gin.Context) {
+	result := map[string]interface{}{
+		"description": "握手后首条消息返回客户端请求与服务端协商结果的对比，之后回显所有消息（按协商结果压缩）",
+		"params": map[string]string{
+			"deflate":                    "accept(默认，接受客户端的permessage-deflate提议) / reject(忽略提议，不压缩)",
+			"server_max_window_bits":     "服务端压缩窗口(8-15)，小于15时只使用Huffman编码",
+			"client_max_window_bits":     "客户端压缩窗口(8-15)，仅在客户端提议该参数时返回",
+			"server_no_context_takeover": "true时每条消息重置服务端压缩上下文",
+			"client_no_context_takeover": "true时要求客户端每条消息重置压缩上下文",
+			"protocols":                  "服务端支持的子协议列表(逗号分隔)，为空时接受客户端的第一个子协议",
+			"protocol":                   "accept(默认，选择双方都支持的第一个) / reject(拒绝握手) / ignore(不返回子协议) / mismatch(返回客户端未请求的子协议)",
+		},
+		"commands": map[string]string{
+			"{\"type\":\"report\"}": "获取压缩统计（线上字节数、解压后字节数、压缩比）",
+		},
+	}
+
+	response := routes.CreateSuccessResponse("WebSocket压缩与子协议协商测试", result)
+	c.JSON(http.StatusOK, response)
+}
+
+// negotiate 根据请求头和查询参数计算协商结果
+func negotiate(c *gin.Context) (*NegotiationResult, int, error) {
+	result := &NegotiationResult{
+		RequestedExtensions: strings.Join(c.Request.Header.Values("Sec-WebSocket-Extensions"), ", "),
+		RequestedProtocols:  parseProtocols(c.Request.Header.Values("Sec-WebSocket-Protocol")),
+		ProtocolMode:        c.DefaultQuery("protocol", "accept"),
+		DeflateMode:         c.DefaultQuery("deflate", "accept"),
+	}
+	result.Offers = parseExtensions(result.RequestedExtensions)
+
+	supported := parseProtocols([]string{c.Query("protocols")})
+	switch result.ProtocolMode {
+	case "accept":
+		if len(result.RequestedProtocols) > 0 {
+			result.Subprotocol = selectProtocol(result.RequestedProtocols, supported)
+			if result.Subprotocol == "" {
+				return result, http.StatusBadRequest, errors.New("没有双方都支持的子协议")
+			}
+		}
+	case "reject":
+		if len(result.RequestedProtocols) > 0 {
+			return result, http.StatusBadRequest, errors.New("服务端拒绝了请求的子协议")
+		}
+	case "ignore":
+	case "mismatch":
+		result.Subprotocol = "unrequested-protocol"
+		if len(supported) > 0 {
+			result.Subprotocol = supported[0]
+		}
+	default:
+		return result, http.StatusBadRequest, fmt.Errorf("未知的protocol模式: %s", result.ProtocolMode)
+	}
+
+	if result.DeflateMode == "accept" {
+		for _, offer := range result.Offers {
+			if offer.Name != "permessage-deflate" {
+				continue
+			}
+			params, ok := acceptDeflateOffer(c, offer)
+			if !ok {
+				continue
+			}
+			result.Deflate = params
+			result.ResponseExtensions = formatDeflateResponse(params, offer)
+			break
+		}
+	}
+
+	return result, http.StatusOK, nil
+}
+
+// acceptDeflateOffer 按查询参数接受一个permessage-deflate提议，提议参数非法时返回false
+func acceptDeflateOffer(c *gin.Context, offer ExtensionOffer) (*DeflateParams, bool) {
+	params := &DeflateParams{
+		ServerMaxWindowBits:     queryInt(c, "server_max_window_bits", 15, 8, 15),
+		ClientMaxWindowBits:     15,
+		ServerNoContextTakeover: c.Query("server_no_context_takeover") == "true",
+		ClientNoContextTakeover: c.Query("client_no_context_takeover") == "true",
+	}
+
+	for name, value := range offer.Params {
+		switch name {
+		case "server_no_context_takeover":
+			params.ServerNoContextTakeover = true
+		case "client_no_context_takeover":
+			params.ClientNoContextTakeover = true
+		case "server_max_window_bits":
+			bits, err := strconv.Atoi(value)
+			if err != nil || bits < 8 || bits > 15 {
+				return nil, false
+			}
+			if bits < params.ServerMaxWindowBits {
+				params.ServerMaxWindowBits = bits
+			}
+		case "client_max_window_bits":
+			if value != "" {
+				bits, err := strconv.Atoi(value)
+				if err != nil || bits < 8 || bits > 15 {
+					return nil, false
+				}
+				params.ClientMaxWindowBits = bits
+			}
+			params.ClientMaxWindowBits = minInt(params.ClientMaxWindowBits, queryInt(c, "client_max_window_bits", 15, 8, 15))
+		default:
+			// 未知参数，按RFC 7692必须拒绝该提议
+			return nil, false
+		}
+	}
+
+	params.HuffmanOnly = params.ServerMaxWindowBits < 15
+	return params, true
+}
+
+// formatDeflateResponse 生成Sec-WebSocket-Extensions响应头
+func formatDeflateResponse(params *DeflateParams, offer ExtensionOffer) string {
+	parts := []string{"permessage-deflate"}
+	if params.ServerNoContextTakeover {
+		parts = append(parts, "server_no_context_takeover")
+	}
+	if params.ClientNoContextTakeover {
+		parts = append(parts, "client_no_context_takeover")
+	}
+	if params.ServerMaxWindowBits < 15 {
+		parts = append(parts, "server_max_window_bits="+strconv.Itoa(params.ServerMaxWindowBits))
+	}
+	if _, offered := offer.Params["client_max_window_bits"]; offered && params.ClientMaxWindowBits < 15 {
+		parts = append(parts, "client_max_window_bits="+strconv.Itoa(params.ClientMaxWindowBits))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// parseExtensions 解析Sec-WebSocket-Extensions
+func parseExtensions(header string) []ExtensionOffer {
+	offers := make([]ExtensionOffer, 0)
+	for _, item := range strings.Split(header, ",") {
+		parts := strings.Split(item, ";")
+		name := strings.TrimSpace(parts[0])
+		if name == "" {
+			continue
+		}
+		offer := ExtensionOffer{Name: name, Params: make(map[string]string)}
+		for _, param := range parts[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			offer.Params[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+		offers = append(offers, offer)
+	}
+	return offers
+}
+
+// parseProtocols 解析逗号分隔的子协议列表
+func parseProtocols(values []string) []string {
+	protocols := make([]string, 0)
+	for _, value := range values {
+		for _, protocol := range strings.Split(value, ",") {
+			if protocol = strings.TrimSpace(protocol); protocol != "" {
+				protocols = append(protocols, protocol)
+			}
+		}
+	}
+	return protocols
+}
+
+// selectProtocol 选择客户端请求中第一个服务端支持的子协议
+func selectProtocol(requested, supported []string) string {
+	if len(supported) == 0 {
+		return requested[0]
+	}
+	for _, protocol := range requested {
+		for _, candidate := range supported {
+			if protocol == candidate {
+				return protocol
+			}
+		}
+	}
+	return ""
+}
+
+// echoLoop 读取完整消息（处理分片和解压）并按协商结果回显
+func (dc *deflateConn) echoLoop(result *NegotiationResult) {
+	var message []byte
+	var messageOpcode byte
+	var messageCompressed bool
+	wireSize := 0
+
+	for {
+		_ = dc.conn.SetReadDeadline(time.Now().Add(readTimeout))
+		f, err := dc.readFrame()
+		if err != nil {
+			return
+		}
+
+		switch f.opcode {
+		case opPing:
+			_ = dc.writeFrame(frame{fin: true, opcode: opPong, payload: f.payload})
+			continue
+		case opPong:
+			continue
+		case opClose:
+			_ = dc.writeFrame(frame{fin: true, opcode: opClose, payload: f.payload})
+			return
+		case opText, opBinary:
+			message = append(message[:0], f.payload...)
+			messageOpcode = f.opcode
+			messageCompressed = f.rsv&rsv1Bit != 0
+			wireSize = len(f.payload)
+		case opContinuation:
+			message = append(message, f.payload...)
+			wireSize += len(f.payload)
+		default:
+			continue
+		}
+		if !f.fin {
+			continue
+		}
+
+		payload := message
+		if messageCompressed {
+			payload, err = dc.inflate(message)
+			if err != nil {
+				dc.inflateFailures++
+				_ = dc.sendJSON(map[string]interface{}{"type": "error", "message": "解压失败: " + err.Error()})
+				continue
+			}
+			dc.compressedIn++
+		}
+		dc.messagesIn++
+		dc.wireBytesIn += wireSize
+		dc.plainBytesIn += len(payload)
+
+		if messageOpcode == opText && bytes.Contains(payload, []byte(`"report"`)) {
+			_ = dc.sendJSON(dc.report(result))
+			continue
+		}
+		if err := dc.sendMessage(messageOpcode, payload); err != nil {
+			return
+		}
+	}
+}
+
+// sendJSON 以文本消息发送JSON
+func (dc *deflateConn) sendJSON(v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return dc.sendMessage(opText, payload)
+}
+
+// sendMessage 发送一条消息，已协商压缩时设置RSV1并压缩负载
+func (dc *deflateConn) sendMessage(opcode byte, payload []byte) error {
+	f := frame{fin: true, opcode: opcode, payload: payload}
+	if dc.params != nil {
+		compressed, err := dc.deflate(payload)
+		if err != nil {
+			return err
+		}
+		f.rsv = rsv1Bit
+		f.payload = compressed
+	}
+
+	dc.messagesOut++
+	dc.wireBytesOut += len(f.payload)
+	dc.plainBytesOut += len(payload)
+	return dc.writeFrame(f)
+}
+
+// deflate 压缩单条消息，启用上下文接管时复用压缩器以保留滑动窗口
+func (dc *deflateConn) deflate(payload []byte) ([]byte, error) {
+	level := flate.BestSpeed
+	if dc.params.HuffmanOnly {
+		level = flate.HuffmanOnly
+	}
+
+	dc.compressed.Reset()
+	if dc.compressor == nil {
+		compressor, err := flate.NewWriter(&dc.compressed, level)
+		if err != nil {
+			return nil, err
+		}
+		dc.compressor = compressor
+	} else if dc.params.ServerNoContextTakeover {
+		dc.compressor.Reset(&dc.compressed)
+	}
+
+	if _, err := dc.compressor.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := dc.compressor.Flush(); err != nil {
+		return nil, err
+	}
+
+	data := bytes.TrimSuffix(dc.compressed.Bytes(), deflateTail)
+	result := make([]byte, len(data))
+	copy(result, data)
+	return result, nil
+}
+
+// inflate 解压单条消息，客户端上下文接管时以之前的明文作为字典
+func (dc *deflateConn) inflate(payload []byte) ([]byte, error) {
+	if dc.params == nil {
+		return nil, errors.New("未协商permessage-deflate却收到RSV1帧")
+	}
+
+	var dict []byte
+	if !dc.params.ClientNoContextTakeover {
+		dict = dc.inflateDic
+	}
+
+	reader := flate.NewReaderDict(io.MultiReader(bytes.NewReader(payload), bytes.NewReader(inflateTail)), dict)
+	defer reader.Close()
+
+	plain, err := io.ReadAll(io.LimitReader(reader, maxMessageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if !dc.params.ClientNoContextTakeover {
+		window := 1 << dc.params.ClientMaxWindowBits
+		dc.inflateDic = append(dc.inflateDic, plain...)
+		if len(dc.inflateDic) > window {
+			dc.inflateDic = dc.inflateDic[len(dc.inflateDic)-window:]
+		}
+	}
+	return plain, nil
+}
+
+// report 压缩统计
+func (dc *deflateConn) report(result *NegotiationResult) map[string]interface{} {
+	ratio := func(wire, plain int) float64 {
+		if plain == 0 {
+			return 0
+		}
+		return float64(wire) / float64(plain)
+	}
+
+	return map[string]interface{}{
+		"type":        "report",
+		"negotiation": result,
+		"received": map[string]interface{}{
+			"messages":           dc.messagesIn,
+			"compressed":         dc.compressedIn,
+			"wire_bytes":         dc.wireBytesIn,
+			"plain_bytes":        dc.plainBytesIn,
+			"ratio":              ratio(dc.wireBytesIn, dc.plainBytesIn),
+			"inflate_failures":   dc.inflateFailures,
+			"compression_used":   dc.compressedIn > 0,
+			"deflate_negotiated": result.Deflate != nil,
+		},
+		"sent": map[string]interface{}{
+			"messages":    dc.messagesOut,
+			"wire_bytes":  dc.wireBytesOut,
+			"plain_bytes": dc.plainBytesOut,
+			"ratio":       ratio(dc.wireBytesOut, dc.plainBytesOut),
+		},
+	}
+}
@@ -0,0 +1,563 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"http_proxy_tool_test_web_demo/routes"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeTimeout     = 10 * time.Second
+	readTimeout      = 60 * time.Second
+	maxMessageSize   = 32 * 1024 * 1024
+	maxClosedClients = 100
+)
+
+// WebSocketModule WebSocket测试模块
+type WebSocketModule struct{}
+
+// WSMessage WebSocket JSON消息结构
+type WSMessage struct {
+	Type      string      `json:"type"`
+	Data      interface{} `json:"data,omitempty"`
+	ConnID    string      `json:"conn_id,omitempty"`
+	Timestamp int64       `json:"timestamp"`
+}
+
+// 测试工具需要接受任意来源的连接
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// 聊天室用户编号
+var chatUserCounter int64
+
+// RegisterRoutes 注册路由
+func (m *WebSocketModule) RegisterRoutes(r *gin.Engine) {
+	ws := r.Group("/ws")
+	{
+		// 基础连接和回声测试
+		ws.GET("/connect", serveWS("connect", handleConnect))
+		ws.GET("/echo", serveWS("echo", handleEcho))
+
+		// 广播和聊天室测试
+		ws.GET("/broadcast", serveWS("broadcast", handleBroadcast))
+		ws.GET("/chat", serveWS("chat", handleChat))
+
+		// 实时推送和心跳测试
+		ws.GET("/realtime", serveWS("realtime", handleRealtime))
+		ws.GET("/heartbeat", serveWS("heartbeat", handleHeartbeat))
+
+		// 二进制和性能测试
+		ws.GET("/binary", serveWS("binary", handleBinary))
+		ws.GET("/performance", serveWS("performance", handlePerformance))
+
+		// 协议边界测试（分片、控制帧、关闭码）
+		ws.GET("/edge", HandleEdgeCase)
+		ws.GET("/edge/sessions", handleEdgeSessions)
+		ws.GET("/edge/sessions/:id", handleEdgeSessions)
+
+		// 压缩扩展和子协议协商测试
+		ws.GET("/negotiate", handleNegotiate)
+
+		// 连接统计
+		ws.GET("/stats", handleStats)
+	}
+}
+
+// GetPrefix 获取前缀
+func (m *WebSocketModule) GetPrefix() string {
+	return "/ws"
+}
+
+// GetDescription 获取描述
+func (m *WebSocketModule) GetDescription() string {
+	return "WebSocket连接、消息和性能测试接口"
+}
+
+// serveWS 完成协议升级并注册连接，处理函数返回后自动注销
+func serveWS(endpoint string, handler func(c *gin.Context, cl *client)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 回应客户端请求的第一个子协议，便于测试代理对子协议头的转发
+		var responseHeader http.Header
+		if protocols := websocket.Subprotocols(c.Request); len(protocols) > 0 {
+			responseHeader = http.Header{"Sec-WebSocket-Protocol": {protocols[0]}}
+		}
+
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, responseHeader)
+		if err != nil {
+			// Upgrade失败时已向客户端写入错误响应
+			log.Printf("WebSocket升级失败(%s): %v", endpoint, err)
+			return
+		}
+
+		conn.SetReadLimit(maxMessageSize)
+		_ = conn.SetReadDeadline(time.Now().Add(readTimeout))
+
+		cl := register(conn, endpoint)
+		defer unregister(cl)
+
+		handler(c, cl)
+	}
+}
+
+// readLoop 循环读取消息直到连接关闭，公共命令在此处理
+func readLoop(cl *client, onMessage func(messageType int, data []byte) error) {
+	for {
+		messageType, data, err := cl.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+				log.Printf("WebSocket连接异常关闭(%s): %v", cl.id, err)
+			}
+			return
+		}
+		_ = cl.conn.SetReadDeadline(time.Now().Add(readTimeout))
+		cl.recordReceived(messageType, len(data))
+
+		if messageType == websocket.TextMessage && handleCommand(cl, data) {
+			continue
+		}
+		if err := onMessage(messageType, data); err != nil {
+			return
+		}
+	}
+}
+
+// handleCommand 处理所有端点通用的JSON命令，返回是否已处理
+func handleCommand(cl *client, data []byte) bool {
+	msg, ok := parseMessage(data)
+	if !ok {
+		return false
+	}
+
+	switch msg.Type {
+	case "stats":
+		_ = cl.sendJSON(newMessage("stats", cl.id, cl.stats()))
+		return true
+	case "ping":
+		_ = cl.sendJSON(newMessage("pong", cl.id, msg.Data))
+		return true
+	}
+	return false
+}
+
+// 基础连接测试
+func handleConnect(c *gin.Context, cl *client) {
+	headers := make(map[string]string)
+	for name := range c.Request.Header {
+		headers[name] = c.Request.Header.Get(name)
+	}
+
+	welcome := map[string]interface{}{
+		"message":     "WebSocket连接已建立",
+		"endpoint":    cl.endpoint,
+		"remote_addr": cl.conn.RemoteAddr().String(),
+		"client_ip":   c.ClientIP(),
+		"subprotocol": cl.conn.Subprotocol(),
+		"headers":     headers,
+	}
+	if err := cl.sendJSON(newMessage("welcome", cl.id, welcome)); err != nil {
+		return
+	}
+
+	readLoop(cl, func(messageType int, data []byte) error {
+		ack := map[string]interface{}{
+			"received":     decodePayload(messageType, data),
+			"size":         len(data),
+			"message_type": messageTypeName(messageType),
+		}
+		return cl.sendJSON(newMessage("ack", cl.id, ack))
+	})
+}
+
+// 回声测试，按原消息类型原样返回
+func handleEcho(c *gin.Context, cl *client) {
+	readLoop(cl, func(messageType int, data []byte) error {
+		return cl.send(messageType, data)
+	})
+}
+
+// 广播测试
+func handleBroadcast(c *gin.Context, cl *client) {
+	broadcastJSON("broadcast", "", newMessage("join", cl.id, map[string]interface{}{
+		"online": len(peers("broadcast", "")),
+	}))
+
+	readLoop(cl, func(messageType int, data []byte) error {
+		recipients := len(peers("broadcast", ""))
+		broadcastJSON("broadcast", "", newMessage("broadcast", cl.id, map[string]interface{}{
+			"from":       cl.id,
+			"message":    decodePayload(messageType, data),
+			"recipients": recipients,
+		}))
+		return nil
+	})
+
+	broadcastJSON("broadcast", "", newMessage("leave", cl.id, map[string]interface{}{
+		"online": len(peers("broadcast", "")) - 1,
+	}))
+}
+
+// 实时数据推送测试
+func handleRealtime(c *gin.Context, cl *client) {
+	interval, err := strconv.Atoi(c.Query("interval"))
+	if err != nil || interval < 100 || interval > 10000 {
+		interval = 1000
+	}
+
+	count, err := strconv.Atoi(c.Query("count"))
+	if err != nil || count < 0 || count > 10000 {
+		count = 0 // 0表示持续推送直到连接关闭
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		readLoop(cl, func(int, []byte) error { return nil })
+	}()
+
+	ticker := time.NewTicker(time.Duration(interval) * time.Millisecond)
+	defer ticker.Stop()
+
+	var memStats runtime.MemStats
+	for seq := 1; count == 0 || seq <= count; seq++ {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		runtime.ReadMemStats(&memStats)
+		data := map[string]interface{}{
+			"seq":         seq,
+			"value":       rand.Float64() * 100, // #nosec G404 - 模拟实时数据，非安全敏感
+			"goroutines":  runtime.NumGoroutine(),
+			"alloc_mb":    float64(memStats.Alloc) / 1024 / 1024,
+			"connections": len(peers("realtime", "")),
+			"server_time": time.Now().Format("2006-01-02 15:04:05.000"),
+		}
+		if err := cl.sendJSON(newMessage("realtime", cl.id, data)); err != nil {
+			return
+		}
+	}
+
+	_ = cl.sendJSON(newMessage("complete", cl.id, map[string]interface{}{"count": count}))
+	closeNormally(cl, "推送完成")
+	<-done
+}
+
+// 心跳检测测试，服务端定时发送Ping帧并统计Pong往返时间
+func handleHeartbeat(c *gin.Context, cl *client) {
+	interval, err := strconv.Atoi(c.Query("interval"))
+	if err != nil || interval < 1 || interval > 60 {
+		interval = 5
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		readLoop(cl, func(messageType int, data []byte) error {
+			return cl.sendJSON(newMessage("ack", cl.id, decodePayload(messageType, data)))
+		})
+	}()
+
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for seq := 1; ; seq++ {
+		if err := cl.ping(); err != nil {
+			return
+		}
+
+		stats := cl.stats()
+		heartbeat := map[string]interface{}{
+			"seq":            seq,
+			"interval_sec":   interval,
+			"pings_sent":     stats.PingsSent,
+			"pongs_received": stats.PongsReceived,
+			"last_rtt_ms":    stats.LastRTTMs,
+		}
+		if err := cl.sendJSON(newMessage("heartbeat", cl.id, heartbeat)); err != nil {
+			return
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 二进制数据传输测试
+func handleBinary(c *gin.Context, cl *client) {
+	size, err := strconv.Atoi(c.Query("size"))
+	if err == nil && size > 0 && size <= maxMessageSize {
+		if err := cl.send(websocket.BinaryMessage, generateBinary(size)); err != nil {
+			return
+		}
+	}
+
+	readLoop(cl, func(messageType int, data []byte) error {
+		if messageType == websocket.BinaryMessage {
+			return cl.send(websocket.BinaryMessage, data)
+		}
+
+		// 文本消息 {"type":"request","size":N} 请求指定大小的二进制帧
+		msg, ok := parseMessage(data)
+		if ok && msg.Type == "request" {
+			requested := 0
+			if value, isNumber := msg.Data.(float64); isNumber {
+				requested = int(value)
+			}
+			var sizeReq struct {
+				Size int `json:"size"`
+			}
+			if json.Unmarshal(data, &sizeReq) == nil && sizeReq.Size > 0 {
+				requested = sizeReq.Size
+			}
+			if requested < 1 || requested > maxMessageSize {
+				return cl.sendJSON(newMessage("error", cl.id, fmt.Sprintf("无效的大小: %d", requested)))
+			}
+			return cl.send(websocket.BinaryMessage, generateBinary(requested))
+		}
+
+		return cl.sendJSON(newMessage("info", cl.id, map[string]interface{}{
+			"message": "发送二进制帧将原样返回，发送 {\"type\":\"request\",\"size\":N} 获取N字节二进制帧",
+		}))
+	})
+}
+
+// 聊天室模拟测试
+func handleChat(c *gin.Context, cl *client) {
+	room := c.DefaultQuery("room", "lobby")
+	name := c.Query("name")
+	if name == "" {
+		name = fmt.Sprintf("user-%d", atomic.AddInt64(&chatUserCounter, 1))
+	}
+
+	hubLock.Lock()
+	cl.room = room
+	cl.name = name
+	hubLock.Unlock()
+
+	broadcastJSON("chat", room, newMessage("join", cl.id, map[string]interface{}{
+		"user":  name,
+		"room":  room,
+		"users": chatUsers(room),
+	}))
+
+	readLoop(cl, func(messageType int, data []byte) error {
+		content := decodePayload(messageType, data)
+		if msg, ok := parseMessage(data); ok {
+			if msg.Type == "users" {
+				return cl.sendJSON(newMessage("users", cl.id, chatUsers(room)))
+			}
+			content = msg.Data
+		}
+
+		broadcastJSON("chat", room, newMessage("message", cl.id, map[string]interface{}{
+			"user":    name,
+			"room":    room,
+			"message": content,
+		}))
+		return nil
+	})
+
+	hubLock.Lock()
+	cl.room = ""
+	hubLock.Unlock()
+
+	broadcastJSON("chat", room, newMessage("leave", cl.id, map[string]interface{}{
+		"user":  name,
+		"room":  room,
+		"users": chatUsers(room),
+	}))
+}
+
+// 性能测试，支持回声模式和服务端推送(flood)模式
+func handlePerformance(c *gin.Context, cl *client) {
+	count, err := strconv.Atoi(c.Query("count"))
+	if err != nil || count < 1 || count > 100000 {
+		count = 1000
+	}
+
+	size, err := strconv.Atoi(c.Query("size"))
+	if err != nil || size < 1 || size > 65536 {
+		size = 128
+	}
+
+	if c.Query("mode") == "flood" {
+		if err := flood(cl, count, size); err != nil {
+			return
+		}
+	}
+
+	readLoop(cl, func(messageType int, data []byte) error {
+		if msg, ok := parseMessage(data); ok && msg.Type == "flood" {
+			var params struct {
+				Count int `json:"count"`
+				Size  int `json:"size"`
+			}
+			_ = json.Unmarshal(data, &params)
+			if params.Count < 1 || params.Count > 100000 {
+				params.Count = count
+			}
+			if params.Size < 1 || params.Size > 65536 {
+				params.Size = size
+			}
+			return flood(cl, params.Count, params.Size)
+		}
+		return cl.send(messageType, data)
+	})
+}
+
+// flood 尽可能快地推送消息并汇报吞吐量
+func flood(cl *client, count, size int) error {
+	payload := generateBinary(size)
+	startTime := time.Now()
+
+	for i := 0; i < count; i++ {
+		if err := cl.send(websocket.BinaryMessage, payload); err != nil {
+			return err
+		}
+	}
+
+	duration := time.Since(startTime)
+	summary := map[string]interface{}{
+		"messages":            count,
+		"message_size":        size,
+		"total_bytes":         count * size,
+		"duration_ms":         duration.Milliseconds(),
+		"messages_per_second": float64(count) / duration.Seconds(),
+		"throughput_mbps":     float64(count*size) / (1024 * 1024) / duration.Seconds(),
+	}
+	return cl.sendJSON(newMessage("summary", cl.id, summary))
+}
+
+// 连接统计
+func handleStats(c *gin.Context) {
+	active, closed := snapshot()
+
+	result := map[string]interface{}{
+		"active_count": len(active),
+		"closed_count": len(closed),
+		"active":       active,
+		"closed":       closed,
+	}
+
+	response := routes.CreateSuccessResponse("WebSocket连接统计", result)
+	c.JSON(http.StatusOK, response)
+}
+
+// closeNormally 发送正常关闭帧
+func closeNormally(cl *client, reason string) {
+	message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason)
+	_ = cl.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeTimeout))
+}
+
+// chatUsers 获取聊天室用户列表
+func chatUsers(room string) []string {
+	members := peers("chat", room)
+	users := make([]string, 0, len(members))
+	hubLock.RLock()
+	for _, member := range members {
+		users = append(users, member.name)
+	}
+	hubLock.RUnlock()
+	return users
+}
+
+// newMessage 创建服务端消息
+func newMessage(msgType, connID string, data interface{}) WSMessage {
+	return WSMessage{
+		Type:      msgType,
+		Data:      data,
+		ConnID:    connID,
+		Timestamp: time.Now().UnixMilli(),
+	}
+}
+
+// parseMessage 尝试将文本帧解析为JSON消息
+func parseMessage(data []byte) (WSMessage, bool) {
+	var msg WSMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type == "" {
+		return msg, false
+	}
+	return msg, true
+}
+
+// decodePayload 将收到的消息转换为便于JSON展示的形式
+func decodePayload(messageType int, data []byte) interface{} {
+	if messageType == websocket.BinaryMessage {
+		return map[string]interface{}{
+			"size":        len(data),
+			"first_bytes": data[:minInt(16, len(data))],
+		}
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal(data, &parsed); err == nil {
+		return parsed
+	}
+	return string(data)
+}
+
+// messageTypeName 获取消息类型名称
+func messageTypeName(messageType int) string {
+	if messageType == websocket.BinaryMessage {
+		return "binary"
+	}
+	return "text"
+}
+
+// generateBinary 生成可校验的二进制数据
+func generateBinary(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 256)
+	}
+	return data
+}
+
+// countingWriter 统计写入字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// jsonEncode 不带HTML转义地编码JSON
+func jsonEncode(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(v)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}